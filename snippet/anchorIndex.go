@@ -0,0 +1,25 @@
+package snippet
+
+import (
+	"fmt"
+	"io"
+)
+
+// WriteAnchorIndex writes an index of the snippets which can be used by a
+// code search tool to link directly to the snippet text. There is one line
+// per snippet giving the snippet name, the path of the snippet file and
+// the byte offset in that file of the snippet text, separated by tabs.
+// Snippets with no text are not included.
+func WriteAnchorIndex(w io.Writer, snippets []*S) error {
+	for _, s := range snippets {
+		offset := s.TextOffset()
+		if offset < 0 {
+			continue
+		}
+		_, err := fmt.Fprintf(w, "%s\t%s\t%d\n", s.name, s.path, offset)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package snippet
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+func TestTextOffset(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		content   string
+		expOffset int
+	}{
+		{
+			ID:        testhelper.MkID("text only"),
+			content:   "a()\nb()\n",
+			expOffset: 0,
+		},
+		{
+			ID:        testhelper.MkID("leading comments"),
+			content:   "// snippet: note: n\n// snippet: imports: fmt\na()\n",
+			expOffset: 45,
+		},
+		{
+			ID:        testhelper.MkID("CRLF line endings"),
+			content:   "// snippet: note: n\r\na()\r\n",
+			expOffset: 21,
+		},
+		{
+			ID:        testhelper.MkID("byte order mark"),
+			content:   "\xef\xbb\xbf// snippet: note: n\na()\n",
+			expOffset: 23,
+		},
+		{
+			ID:        testhelper.MkID("no text"),
+			content:   "// snippet: imports: fmt\n",
+			expOffset: -1,
+		},
+	}
+
+	for _, tc := range testCases {
+		s, err := parseSnippet([]byte(tc.content), "path", "name")
+		if err != nil {
+			t.Fatalf("%s: cannot parse the snippet: %s", tc.IDStr(), err)
+		}
+		offset := s.TextOffset()
+		testhelper.DiffInt(t, tc.IDStr(), "text offset",
+			offset, tc.expOffset)
+		if offset >= 0 &&
+			!strings.HasPrefix(tc.content[offset:], s.text[0]) {
+			t.Log(tc.IDStr())
+			t.Errorf("\t: the offset does not give the first line of text")
+		}
+	}
+}
+
+func TestWriteAnchorIndex(t *testing.T) {
+	sc := Cache{}
+	for _, name := range []string{"expects1", "complete"} {
+		if _, err := sc.Add([]string{TestSnippets}, name); err != nil {
+			t.Fatalf("cannot add %q: %s", name, err)
+		}
+	}
+
+	var b strings.Builder
+	if err := WriteAnchorIndex(&b, sc.InOrder()); err != nil {
+		t.Fatalf("cannot write the index: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 index lines, got %d: %q", len(lines), lines)
+	}
+	for i, name := range []string{"expects1", "complete"} {
+		path := filepath.Join(TestSnippets, name)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("cannot read %q: %s", path, err)
+		}
+		s, _ := sc.Get(name)
+		testhelper.DiffString(t, name, "index line", lines[i],
+			name+"\t"+path+"\t"+strconv.Itoa(s.TextOffset()))
+		if !strings.HasPrefix(string(content[s.TextOffset():]), s.text[0]) {
+			t.Errorf("%s: the offset does not give the first line of text",
+				name)
+		}
+	}
+}
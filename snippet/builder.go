@@ -0,0 +1,110 @@
+package snippet
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+)
+
+// SnippetBuilder is used to construct a snippet in code rather than by
+// parsing a snippet file. The methods may be chained, as in:
+//
+//	s, err := (&SnippetBuilder{}).
+//		WithName("hello").
+//		AddImport("fmt").
+//		WithText(`fmt.Println("Hello")`).
+//		Build()
+//
+// The zero value is an empty SnippetBuilder ready to use.
+type SnippetBuilder struct {
+	s S
+
+	trimTrailingBlankLines bool
+}
+
+// WithName sets the name of the snippet
+func (b *SnippetBuilder) WithName(name string) *SnippetBuilder {
+	b.s.name = name
+	return b
+}
+
+// WithText sets the text of the snippet, one entry per line, replacing any
+// text already given.
+func (b *SnippetBuilder) WithText(lines ...string) *SnippetBuilder {
+	b.s.text = copySlice(lines)
+	return b
+}
+
+// TrimTrailingBlankLines sets whether any blank lines at the end of the
+// text are removed when the snippet is built. Blank lines within the text
+// are kept. By default the text is left unchanged.
+func (b *SnippetBuilder) TrimTrailingBlankLines(val bool) *SnippetBuilder {
+	b.trimTrailingBlankLines = val
+	return b
+}
+
+// AddImport adds the import to the snippet
+func (b *SnippetBuilder) AddImport(imp string) *SnippetBuilder {
+	b.s.imports = append(b.s.imports, imp)
+	return b
+}
+
+// AddExpect adds the name to the snippets expected by the snippet
+func (b *SnippetBuilder) AddExpect(sName string) *SnippetBuilder {
+	b.s.expects = append(b.s.expects, sName)
+	return b
+}
+
+// AddFollow adds the name to the snippets that the snippet follows. As when
+// parsing a snippet file, a followed snippet is also expected.
+func (b *SnippetBuilder) AddFollow(sName string) *SnippetBuilder {
+	b.s.follows = append(b.s.follows, sName)
+	b.s.expects = append(b.s.expects, sName)
+	return b
+}
+
+// AddDoc adds the line to the notes of the snippet
+func (b *SnippetBuilder) AddDoc(doc string) *SnippetBuilder {
+	b.s.docs = append(b.s.docs, doc)
+	return b
+}
+
+// AddTag adds the value to the values of the named tag
+func (b *SnippetBuilder) AddTag(tag, value string) *SnippetBuilder {
+	if b.s.tags == nil {
+		b.s.tags = map[string][]string{}
+	}
+	b.s.tags[tag] = append(b.s.tags[tag], value)
+	return b
+}
+
+// Build returns the snippet constructed from the values given. The
+// imports, expects and follows are sorted and any duplicate or blank
+// entries are removed, just as when a snippet file is parsed. An error is
+// returned if the snippet has no name or if it has no text and no imports.
+// The SnippetBuilder may be used again after Build is called; later
+// changes do not affect the snippets already built.
+func (b *SnippetBuilder) Build() (*S, error) {
+	s := b.s.Clone()
+	if s.tags == nil {
+		s.tags = map[string][]string{}
+	}
+	s.textOffset = -1
+	if b.trimTrailingBlankLines {
+		s.trimTrailingBlankLines()
+	}
+
+	s.tidy()
+
+	if s.name == "" {
+		return nil, errors.New("the snippet has no name")
+	}
+	if len(s.text) == 0 &&
+		len(s.imports) == 0 {
+		return nil,
+			fmt.Errorf("snippet %q has no text and no imports", s.name)
+	}
+	s.contentHash = md5.Sum([]byte(s.canonical()))
+
+	return s, nil
+}
@@ -0,0 +1,117 @@
+package snippet
+
+import (
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+func TestSnippetBuilder(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		b          *SnippetBuilder
+		expImports []string
+		expExpects []string
+		expFollows []string
+	}{
+		{
+			ID: testhelper.MkID("good"),
+			b: (&SnippetBuilder{}).
+				WithName("hello").
+				AddDoc("says hello").
+				AddImport("os").
+				AddImport("fmt").
+				AddImport("fmt").
+				AddExpect("setup").
+				AddFollow("init").
+				AddTag("Author", "Nick Wells").
+				WithText(`fmt.Println("Hello")`),
+			expImports: []string{"fmt", "os"},
+			expExpects: []string{"init", "setup"},
+			expFollows: []string{"init"},
+		},
+		{
+			ID: testhelper.MkID("imports only"),
+			b: (&SnippetBuilder{}).
+				WithName("imports").
+				AddImport("fmt"),
+			expImports: []string{"fmt"},
+			expExpects: []string{},
+			expFollows: []string{},
+		},
+		{
+			ID: testhelper.MkID("no name"),
+			b: (&SnippetBuilder{}).
+				WithText("x()"),
+			ExpErr: testhelper.MkExpErr("the snippet has no name"),
+		},
+		{
+			ID: testhelper.MkID("no text and no imports"),
+			b: (&SnippetBuilder{}).
+				WithName("empty").
+				AddDoc("nothing here").
+				AddImport(""),
+			ExpErr: testhelper.MkExpErr(
+				`snippet "empty" has no text and no imports`),
+		},
+	}
+
+	for _, tc := range testCases {
+		s, err := tc.b.Build()
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffStringSlice(t, tc.IDStr(), "imports",
+				s.Imports(), tc.expImports)
+			testhelper.DiffStringSlice(t, tc.IDStr(), "expects",
+				s.Expects(), tc.expExpects)
+			testhelper.DiffStringSlice(t, tc.IDStr(), "follows",
+				s.Follows(), tc.expFollows)
+		}
+	}
+}
+
+func TestSnippetBuilderMatchesParsed(t *testing.T) {
+	b := &SnippetBuilder{}
+	b.WithName("name").
+		AddDoc("says hello").
+		AddImport("fmt").
+		AddTag("Author", "Nick Wells").
+		WithText(`fmt.Println("Hello")`)
+	s, err := b.Build()
+	if err != nil {
+		t.Fatal("cannot build the snippet: ", err)
+	}
+
+	parsed, err := parseSnippet([]byte(s.canonical()), "", "name")
+	if err != nil {
+		t.Fatal("cannot parse the snippet: ", err)
+	}
+	if err := s.Matches(*parsed); err != nil {
+		t.Error("the built snippet differs from the parsed snippet: ", err)
+	}
+	testhelper.DiffStringSlice(t, "built", "text", s.Text(), parsed.Text())
+
+	b.AddImport("os")
+	testhelper.DiffStringSlice(t, "built before a change", "imports",
+		s.Imports(), []string{"fmt"})
+}
+
+func TestSnippetBuilderTrimTrailingBlankLines(t *testing.T) {
+	b := (&SnippetBuilder{}).
+		WithName("name").
+		WithText("a()", "", "b()", "", " ")
+
+	s, err := b.Build()
+	if err != nil {
+		t.Fatal("cannot build the snippet: ", err)
+	}
+	testhelper.DiffStringSlice(t, "untrimmed", "text",
+		s.Text(), []string{"a()", "", "b()", "", " "})
+
+	s, err = b.TrimTrailingBlankLines(true).Build()
+	if err != nil {
+		t.Fatal("cannot build the trimmed snippet: ", err)
+	}
+	testhelper.DiffStringSlice(t, "trimmed", "text",
+		s.Text(), []string{"a()", "", "b()"})
+}
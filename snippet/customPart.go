@@ -0,0 +1,98 @@
+package snippet
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// customParts holds the names of the parts added by RegisterPart in the
+// order they were registered
+var customParts = []string{}
+
+// customPartNameRE matches the allowed names of a custom part
+var customPartNameRE = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// RegisterPart adds a new part to those recognised in a snippet file. Any
+// semantic comment starting with the name (or one of the alternative names)
+// followed by a colon will be recorded as a value of the part; the values
+// can be retrieved with the CustomPart method. The part is added to the
+// ValidParts so it can be shown when listing the snippets.
+//
+// The names must start with a lower-case letter followed by lower-case
+// letters, digits, underscores or dashes. An error is returned if any of
+// the names is already in use, either by a built-in part or by an
+// alternative name or by a previously registered part.
+//
+// It should be called before any snippets are parsed, typically from an
+// init function; it is not safe to call it while snippets are being parsed.
+func RegisterPart(name string, alts []string, description string) error {
+	names := append([]string{name}, alts...)
+	for _, n := range names {
+		if !customPartNameRE.MatchString(n) {
+			return fmt.Errorf("bad snippet part name: %q", n)
+		}
+		if partNameInUse(n) {
+			return fmt.Errorf("the snippet part name %q is already in use", n)
+		}
+	}
+	if len(names) != len(tidySlice(append([]string{}, names...))) {
+		return fmt.Errorf("the snippet part %q has repeated names", name)
+	}
+
+	customParts = append(customParts, name)
+	snippetParts = append(snippetParts, name)
+	if len(alts) > 0 {
+		altPartNames[name] = append([]string{}, alts...)
+	}
+	validParts[name] = description
+	snippetPartREs[name] = regexp.MustCompile(commentREStr +
+		`\s*` + `(?:` + name + altNames(name) + `):\s*`)
+
+	return nil
+}
+
+// partNameInUse returns true if the name is the name of a part or an
+// alternative name of a part.
+func partNameInUse(name string) bool {
+	if _, ok := validParts[name]; ok {
+		return true
+	}
+	for _, alts := range altPartNames {
+		if containsString(alts, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCustomPart returns true if the part was added by RegisterPart
+func isCustomPart(part string) bool {
+	return containsString(customParts, part)
+}
+
+// addCustomPart will look for any of the registered parts in the line and
+// if it finds one it will add the value to the snippet. It returns true if
+// the line matched one of the registered parts and false otherwise.
+func (s *S) addCustomPart(line string) bool {
+	for _, p := range customParts {
+		var vals []string
+		if addMatchToSlices(line, snippetPartREs[p], &vals) {
+			if len(vals) > 0 {
+				if s.custom == nil {
+					s.custom = map[string][]string{}
+				}
+				s.custom[p] = append(s.custom[p], vals...)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// CustomPart returns a copy of the values of the named part as added by
+// RegisterPart. The values are given in the order they appear in the
+// snippet file. If the part has no values or is not a registered part then
+// an empty slice is returned.
+func (s S) CustomPart(name string) []string {
+	return copySlice(s.custom[name])
+}
@@ -0,0 +1,152 @@
+package snippet
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+// restorePartsOnCleanup saves the current snippet parts and restores them
+// when the test completes so that registering parts in one test does not
+// affect any other test.
+func restorePartsOnCleanup(t *testing.T) {
+	t.Helper()
+
+	origCustom := append([]string{}, customParts...)
+	origParts := append([]string{}, snippetParts...)
+	origAlt := map[string][]string{}
+	for k, v := range altPartNames {
+		origAlt[k] = v
+	}
+	origValid := ValidParts()
+	origREs := map[string]*regexp.Regexp{}
+	for k, v := range snippetPartREs {
+		origREs[k] = v
+	}
+
+	t.Cleanup(func() {
+		customParts = origCustom
+		snippetParts = origParts
+		altPartNames = origAlt
+		validParts = origValid
+		snippetPartREs = origREs
+	})
+}
+
+func TestRegisterPart(t *testing.T) {
+	restorePartsOnCleanup(t)
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		name string
+		alts []string
+	}{
+		{
+			ID:   testhelper.MkID("good"),
+			name: "owner",
+			alts: []string{"owners", "maintainer"},
+		},
+		{
+			ID:   testhelper.MkID("good, no alternatives"),
+			name: "jira",
+		},
+		{
+			ID:     testhelper.MkID("built-in part"),
+			name:   ImportPart,
+			ExpErr: testhelper.MkExpErr(`"imports" is already in use`),
+		},
+		{
+			ID:     testhelper.MkID("built-in alternative name"),
+			name:   "docs",
+			ExpErr: testhelper.MkExpErr(`"docs" is already in use`),
+		},
+		{
+			ID:     testhelper.MkID("mixed case built-in part"),
+			name:   "xxx",
+			alts:   []string{"xx", "Tag"},
+			ExpErr: testhelper.MkExpErr(`bad snippet part name: "Tag"`),
+		},
+		{
+			ID:     testhelper.MkID("registered part"),
+			name:   "maintainer",
+			ExpErr: testhelper.MkExpErr(`"maintainer" is already in use`),
+		},
+		{
+			ID:     testhelper.MkID("repeated name"),
+			name:   "team",
+			alts:   []string{"team"},
+			ExpErr: testhelper.MkExpErr(`"team" has repeated names`),
+		},
+		{
+			ID:     testhelper.MkID("bad name"),
+			name:   "has space",
+			ExpErr: testhelper.MkExpErr(`bad snippet part name: "has space"`),
+		},
+	}
+
+	for _, tc := range testCases {
+		err := RegisterPart(tc.name, tc.alts, "a custom part")
+		testhelper.CheckExpErr(t, err, tc)
+	}
+
+	_, ok := ValidParts()["xxx"]
+	testhelper.DiffBool(t, "failed registration", "in ValidParts", ok, false)
+	_, ok = ValidParts()["owner"]
+	testhelper.DiffBool(t, "registration", "in ValidParts", ok, true)
+}
+
+func TestCustomPart(t *testing.T) {
+	restorePartsOnCleanup(t)
+
+	err := RegisterPart("owner", []string{"owners"}, "who to ask")
+	if err != nil {
+		t.Fatal("cannot register the part: ", err)
+	}
+
+	content := "// snippet: owner: team-a\n" +
+		"// snippet: Owners: team-b\n" +
+		"// snippet: tag: owner: not a part\n" +
+		"x()\n"
+	s, err := parseSnippet([]byte(content), "path", "name")
+	if err != nil {
+		t.Fatal("cannot parse the snippet: ", err)
+	}
+
+	testhelper.DiffStringSlice(t, "parsed", "owner",
+		s.CustomPart("owner"), []string{"team-a", "team-b"})
+	testhelper.DiffStringSlice(t, "parsed", "unknown part",
+		s.CustomPart("nonesuch"), []string{})
+	testhelper.DiffStringSlice(t, "parsed", "tag",
+		s.Tags()["owner"], []string{"not a part"})
+	testhelper.DiffInt(t, "parsed", "parse warnings",
+		len(s.ParseWarnings()), 0)
+
+	p, err := s.Project("owner")
+	if err != nil {
+		t.Fatal("cannot project the snippet: ", err)
+	}
+	testhelper.DiffStringSlice(t, "projected", "owner",
+		p.CustomPart("owner"), []string{"team-a", "team-b"})
+	testhelper.DiffInt(t, "projected", "tags", len(p.Tags()), 0)
+
+	fc := formatCfg{parts: map[string]bool{"owner": true}}
+	testhelper.DiffString(t, "shown", "owner", fc.snippetToString(s),
+		"\n        owner: team-a\n               team-b\n")
+
+	other, err := parseSnippet([]byte(s.canonical()), "path", "name")
+	if err != nil {
+		t.Fatal("cannot parse the canonical snippet: ", err)
+	}
+	if err := other.Matches(*s); err != nil {
+		t.Error("the canonical snippet differs: ", err)
+	}
+
+	other.custom["owner"] = []string{"team-a"}
+	err = other.Matches(*s)
+	if err == nil || !strings.Contains(err.Error(), "owner") {
+		t.Error("the differing owner was not reported: ", err)
+	}
+}
@@ -0,0 +1,119 @@
+package snippet
+
+import "sort"
+
+// FieldDiff records a single difference between two snippets
+type FieldDiff struct {
+	// Part is the name of the differing part of the snippet, as given by
+	// ValidParts
+	Part string
+	// Tag is the name of the tag if the Part is TagPart
+	Tag string
+	// Index is the index of the differing entry for parts which have a
+	// list of values; it is -1 for parts with a single value
+	Index int
+	// This and Other are the differing values
+	This  string
+	Other string
+	// InThis and InOther are false if the entry is missing from this or
+	// the other snippet
+	InThis  bool
+	InOther bool
+}
+
+// Diff returns the differences between the snippet and the other snippet.
+// Parts with a list of values are compared entry by entry and there is a
+// FieldDiff for each differing entry. The differences are given in the
+// order: name, path, notes, expects, imports, follows, seealso, include,
+// status, deprecated, since, any parts added by RegisterPart, tags (by tag
+// name) and text. Note that, unlike Matches, Diff also compares the text of
+// the snippets. If the snippets are the same an empty slice is returned.
+func (s S) Diff(other S) []FieldDiff {
+	diffs := []FieldDiff{}
+
+	diffs = appendValueDiff(diffs, NamePart, s.name, other.name)
+	diffs = appendValueDiff(diffs, PathPart, s.path, other.path)
+	diffs = appendSliceDiffs(diffs, DocsPart, "", s.docs, other.docs)
+	diffs = appendSliceDiffs(diffs, ExpectPart, "", s.expects, other.expects)
+	diffs = appendSliceDiffs(diffs, ImportPart, "", s.imports, other.imports)
+	diffs = appendSliceDiffs(diffs, FollowPart, "", s.follows, other.follows)
+	diffs = appendSliceDiffs(diffs, SeeAlsoPart, "", s.seeAlso, other.seeAlso)
+	diffs = appendSliceDiffs(diffs, IncludePart, "",
+		s.Includes(), other.Includes())
+	diffs = appendValueDiff(diffs, StatusPart, s.status, other.status)
+	if s.deprecated != other.deprecated ||
+		s.deprecatedMsg != other.deprecatedMsg {
+		diffs = append(diffs, FieldDiff{
+			Part:    DeprecatedPart,
+			Index:   -1,
+			This:    s.deprecatedMsg,
+			Other:   other.deprecatedMsg,
+			InThis:  s.deprecated,
+			InOther: other.deprecated,
+		})
+	}
+	diffs = appendValueDiff(diffs, SincePart, s.since, other.since)
+	for _, cp := range customParts {
+		diffs = appendSliceDiffs(diffs, cp, "", s.custom[cp], other.custom[cp])
+	}
+
+	tags := []string{}
+	for k := range s.tags {
+		tags = append(tags, k)
+	}
+	for k := range other.tags {
+		if _, ok := s.tags[k]; !ok {
+			tags = append(tags, k)
+		}
+	}
+	sort.Strings(tags)
+	for _, k := range tags {
+		diffs = appendSliceDiffs(diffs, TagPart, k, s.tags[k], other.tags[k])
+	}
+
+	diffs = appendSliceDiffs(diffs, TextPart, "", s.text, other.text)
+
+	return diffs
+}
+
+// appendValueDiff appends a FieldDiff to the diffs if the values differ
+// and returns the result.
+func appendValueDiff(diffs []FieldDiff, part, this, other string,
+) []FieldDiff {
+	if this == other {
+		return diffs
+	}
+	return append(diffs, FieldDiff{
+		Part:    part,
+		Index:   -1,
+		This:    this,
+		Other:   other,
+		InThis:  true,
+		InOther: true,
+	})
+}
+
+// appendSliceDiffs appends a FieldDiff to the diffs for each entry in the
+// slices which differs and returns the result.
+func appendSliceDiffs(diffs []FieldDiff, part, tag string, this, other []string,
+) []FieldDiff {
+	n := len(this)
+	if len(other) > n {
+		n = len(other)
+	}
+
+	for i := 0; i < n; i++ {
+		d := FieldDiff{Part: part, Tag: tag, Index: i}
+		if i < len(this) {
+			d.This, d.InThis = this[i], true
+		}
+		if i < len(other) {
+			d.Other, d.InOther = other[i], true
+		}
+		if d.InThis && d.InOther && d.This == d.Other {
+			continue
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
@@ -0,0 +1,95 @@
+package snippet
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+func TestDiff(t *testing.T) {
+	base := S{
+		name:    "name",
+		path:    "path",
+		text:    []string{"a()", "b()"},
+		docs:    []string{"docs"},
+		imports: []string{"fmt", "os"},
+		status:  StatusStable,
+		tags: map[string][]string{
+			"T1": {"v1"},
+			"T2": {"v2"},
+		},
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		other    S
+		expDiffs []FieldDiff
+	}{
+		{
+			ID:       testhelper.MkID("same"),
+			other:    base,
+			expDiffs: []FieldDiff{},
+		},
+		{
+			ID: testhelper.MkID("differences"),
+			other: S{
+				name:    "name",
+				path:    "other/path",
+				text:    []string{"a()"},
+				docs:    []string{"docs"},
+				imports: []string{"fmt", "io", "os"},
+				tags: map[string][]string{
+					"T2": {"v2", "v2a"},
+					"T3": {"v3"},
+				},
+			},
+			expDiffs: []FieldDiff{
+				{
+					Part: PathPart, Index: -1,
+					This: "path", Other: "other/path",
+					InThis: true, InOther: true,
+				},
+				{
+					Part: ImportPart, Index: 1,
+					This: "os", Other: "io",
+					InThis: true, InOther: true,
+				},
+				{
+					Part: ImportPart, Index: 2,
+					Other: "os", InOther: true,
+				},
+				{
+					Part: StatusPart, Index: -1,
+					This: StatusStable, InThis: true, InOther: true,
+				},
+				{
+					Part: TagPart, Tag: "T1", Index: 0,
+					This: "v1", InThis: true,
+				},
+				{
+					Part: TagPart, Tag: "T2", Index: 1,
+					Other: "v2a", InOther: true,
+				},
+				{
+					Part: TagPart, Tag: "T3", Index: 0,
+					Other: "v3", InOther: true,
+				},
+				{
+					Part: TextPart, Index: 1,
+					This: "b()", InThis: true,
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		diffs := base.Diff(tc.other)
+		if !reflect.DeepEqual(diffs, tc.expDiffs) {
+			t.Log(tc.IDStr())
+			t.Logf("\t: expected: %+v", tc.expDiffs)
+			t.Logf("\t:      got: %+v", diffs)
+			t.Errorf("\t: unexpected differences\n")
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
 const (
@@ -24,27 +25,81 @@ type formatCfg struct {
 	// hideIntro controls whether introductory strings are printed before the
 	// parts of the snippet
 	hideIntro bool
+
+	// annotateRef, if not nil, is used to annotate the names of the
+	// expected and followed snippets
+	annotateRef func(string) string
+
+	// indent and nameIndent give the indentation of the snippet parts and
+	// of the snippet name. They are only used if the corresponding
+	// indentSet or nameIndentSet is true, otherwise the defaults are used
+	indent        int
+	indentSet     bool
+	nameIndent    int
+	nameIndentSet bool
+
+	// wrapWidth, if greater than zero, is the width at which long values
+	// are wrapped
+	wrapWidth int
+}
+
+// partIndent returns the indentation of the snippet parts
+func (fc *formatCfg) partIndent() int {
+	if fc.indentSet {
+		return fc.indent
+	}
+	return dfltIndent
+}
+
+// nameIndentation returns the indentation of the snippet name
+func (fc *formatCfg) nameIndentation() int {
+	if fc.nameIndentSet {
+		return fc.nameIndent
+	}
+	return nameIndent
+}
+
+// annotated returns the names annotated by the annotateRef func. If there
+// is no annotateRef func the names are returned unchanged.
+func (fc *formatCfg) annotated(names []string) []string {
+	if fc.annotateRef == nil {
+		return names
+	}
+	rval := make([]string, 0, len(names))
+	for _, n := range names {
+		rval = append(rval, fc.annotateRef(n))
+	}
+	return rval
 }
 
 type partsToShow struct {
 	intro  string
-	indent int
+	isName bool
+	noWrap bool
 	values []string
 }
 
+// DeprecatedMarker is shown after the name of a deprecated snippet
+const DeprecatedMarker = "[DEPRECATED]"
+
 // initPartsToShow constructs the list of parts to show and returns it
 func (fc *formatCfg) initPartsToShow(s *S) []partsToShow { //nolint: gocyclo
 	parts := []partsToShow{}
 
 	partsAndTagsEmpty := len(fc.parts) == 0 && len(fc.tags) == 0
 
+	isDeprecated, deprecatedMsg := s.Deprecated()
+
 	if partsAndTagsEmpty || fc.parts[NamePart] {
-		indent := nameIndent
+		name := s.name
+		if isDeprecated {
+			name += " " + DeprecatedMarker
+		}
 		parts = append(parts,
 			partsToShow{
 				intro:  "",
-				indent: indent,
-				values: []string{s.name},
+				isName: true,
+				values: []string{name},
 			})
 	}
 	if fc.parts[PathPart] {
@@ -54,6 +109,13 @@ func (fc *formatCfg) initPartsToShow(s *S) []partsToShow { //nolint: gocyclo
 				values: []string{s.path},
 			})
 	}
+	if fc.parts[ModTimePart] {
+		parts = append(parts,
+			partsToShow{
+				intro:  "Modified:",
+				values: []string{modTimeString(s.modTime)},
+			})
+	}
 	if partsAndTagsEmpty || fc.parts[DocsPart] {
 		parts = append(parts,
 			partsToShow{
@@ -72,7 +134,7 @@ func (fc *formatCfg) initPartsToShow(s *S) []partsToShow { //nolint: gocyclo
 		parts = append(parts,
 			partsToShow{
 				intro:  "Follows:",
-				values: s.follows,
+				values: fc.annotated(s.follows),
 			})
 	}
 	if partsAndTagsEmpty || fc.parts[ExpectPart] {
@@ -92,10 +154,61 @@ func (fc *formatCfg) initPartsToShow(s *S) []partsToShow { //nolint: gocyclo
 		parts = append(parts,
 			partsToShow{
 				intro:  "Expects:",
-				values: expectedParts,
+				values: fc.annotated(expectedParts),
+			})
+	}
+
+	if (partsAndTagsEmpty && len(s.seeAlso) > 0) || fc.parts[SeeAlsoPart] {
+		parts = append(parts,
+			partsToShow{
+				intro:  "See also:",
+				values: s.seeAlso,
 			})
 	}
 
+	if (partsAndTagsEmpty && len(s.includes) > 0) || fc.parts[IncludePart] {
+		parts = append(parts,
+			partsToShow{
+				intro:  "Includes:",
+				values: s.Includes(),
+			})
+	}
+
+	if (partsAndTagsEmpty && s.status != "") || fc.parts[StatusPart] {
+		parts = append(parts,
+			partsToShow{
+				intro:  "Status:",
+				values: []string{s.status},
+			})
+	}
+
+	if (partsAndTagsEmpty && deprecatedMsg != "") ||
+		fc.parts[DeprecatedPart] {
+		parts = append(parts,
+			partsToShow{
+				intro:  "Deprecated:",
+				values: []string{deprecatedMsg},
+			})
+	}
+
+	if (partsAndTagsEmpty && s.since != "") || fc.parts[SincePart] {
+		parts = append(parts,
+			partsToShow{
+				intro:  "Since:",
+				values: []string{s.since},
+			})
+	}
+
+	for _, cp := range customParts {
+		if (partsAndTagsEmpty && len(s.custom[cp]) > 0) || fc.parts[cp] {
+			parts = append(parts,
+				partsToShow{
+					intro:  cp + ":",
+					values: s.custom[cp],
+				})
+		}
+	}
+
 	tagKeys := getTagKeys(s)
 
 	if fc.parts[TagPart] {
@@ -120,6 +233,7 @@ func (fc *formatCfg) initPartsToShow(s *S) []partsToShow { //nolint: gocyclo
 		parts = append(parts,
 			partsToShow{
 				intro:  "Text:",
+				noWrap: true,
 				values: s.text,
 			})
 	}
@@ -156,7 +270,7 @@ func (fc *formatCfg) snippetToString(s *S) string {
 
 	if fc.hideIntro {
 		for _, p := range parts {
-			for _, l := range p.values {
+			for _, l := range fc.wrap(p, p.values, 0) {
 				rval += l + "\n"
 			}
 		}
@@ -169,14 +283,14 @@ func (fc *formatCfg) snippetToString(s *S) string {
 		if p.intro != "" {
 			intro = fmt.Sprintf("%*s ", maxLen, p.intro)
 		}
-		indent := p.indent
-		if indent == 0 {
-			indent = dfltIndent
+		indent := fc.partIndent()
+		if p.isName {
+			indent = fc.nameIndentation()
 		}
 		intro = strings.Repeat(" ", indent) + intro
 		blanks = strings.Repeat(" ", len(intro))
 
-		for _, l := range p.values {
+		for _, l := range fc.wrap(p, p.values, len(intro)) {
 			rval += intro + l + "\n"
 			intro = blanks
 		}
@@ -184,3 +298,51 @@ func (fc *formatCfg) snippetToString(s *S) string {
 
 	return rval
 }
+
+// wrap returns the values wrapped so that, when shown after the prefix of
+// the given length, no line is longer than the wrap width. Lines are only
+// broken between words so a line with a word which is too long will still
+// exceed the width. If the wrap width is not set or the part is not to be
+// wrapped the values are returned unchanged.
+func (fc *formatCfg) wrap(p partsToShow, values []string, prefixLen int,
+) []string {
+	if fc.wrapWidth <= 0 || p.noWrap || p.isName {
+		return values
+	}
+	width := fc.wrapWidth - prefixLen
+	if width < 1 {
+		width = 1
+	}
+
+	rval := make([]string, 0, len(values))
+	for _, v := range values {
+		rval = append(rval, wrapLine(v, width)...)
+	}
+	return rval
+}
+
+// wrapLine splits the line into lines no longer than the width, breaking it
+// between words. Any leading white space is kept on the first line but the
+// words are otherwise separated by a single space.
+func wrapLine(line string, width int) []string {
+	if utf8.RuneCountInString(line) <= width {
+		return []string{line}
+	}
+
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return []string{line}
+	}
+
+	lines := []string{}
+	cur := line[:len(line)-len(strings.TrimLeft(line, " \t"))] + words[0]
+	for _, w := range words[1:] {
+		if utf8.RuneCountInString(cur)+1+utf8.RuneCountInString(w) > width {
+			lines = append(lines, cur)
+			cur = w
+			continue
+		}
+		cur += " " + w
+	}
+	return append(lines, cur)
+}
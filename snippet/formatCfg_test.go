@@ -0,0 +1,87 @@
+package snippet
+
+import (
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+func TestWrapLine(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		line     string
+		width    int
+		expLines []string
+	}{
+		{
+			ID:       testhelper.MkID("short line"),
+			line:     "a short line",
+			width:    20,
+			expLines: []string{"a short line"},
+		},
+		{
+			ID:       testhelper.MkID("exactly the width"),
+			line:     "abc def",
+			width:    7,
+			expLines: []string{"abc def"},
+		},
+		{
+			ID:       testhelper.MkID("wrapped"),
+			line:     "the quick brown fox jumps",
+			width:    10,
+			expLines: []string{"the quick", "brown fox", "jumps"},
+		},
+		{
+			ID:       testhelper.MkID("long word"),
+			line:     "a supercalifragilistic word",
+			width:    6,
+			expLines: []string{"a", "supercalifragilistic", "word"},
+		},
+		{
+			ID:       testhelper.MkID("leading space kept"),
+			line:     "  one two   three",
+			width:    9,
+			expLines: []string{"  one two", "three"},
+		},
+		{
+			ID:       testhelper.MkID("only spaces"),
+			line:     "          ",
+			width:    4,
+			expLines: []string{"          "},
+		},
+	}
+
+	for _, tc := range testCases {
+		testhelper.DiffStringSlice(t, tc.IDStr(), "lines",
+			wrapLine(tc.line, tc.width), tc.expLines)
+	}
+}
+
+func TestMarkdownFence(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		text     []string
+		expFence string
+	}{
+		{
+			ID:       testhelper.MkID("no backquotes"),
+			text:     []string{"a()"},
+			expFence: "```",
+		},
+		{
+			ID:       testhelper.MkID("short runs"),
+			text:     []string{"x := `a`", "y := ``"},
+			expFence: "```",
+		},
+		{
+			ID:       testhelper.MkID("a fence in the text"),
+			text:     []string{"s := `", "```go", "`"},
+			expFence: "````",
+		},
+	}
+
+	for _, tc := range testCases {
+		testhelper.DiffString(t, tc.IDStr(), "fence",
+			markdownFence(tc.text), tc.expFence)
+	}
+}
@@ -0,0 +1,83 @@
+package snippet
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// readFile reads the named file from the file system. If the file system
+// is nil the file is read from the operating system's file system.
+func readFile(fsys fs.FS, name string) ([]byte, error) {
+	if fsys == nil {
+		return os.ReadFile(name)
+	}
+	return fs.ReadFile(fsys, name)
+}
+
+// readDir reads the named directory from the file system. If the file
+// system is nil the directory is read from the operating system's file
+// system.
+func readDir(fsys fs.FS, name string) ([]fs.DirEntry, error) {
+	if fsys == nil {
+		return os.ReadDir(name)
+	}
+	return fs.ReadDir(fsys, name)
+}
+
+// statFile returns the FileInfo for the named file in the file system. If
+// the file system is nil the file is found in the operating system's file
+// system.
+func statFile(fsys fs.FS, name string) (fs.FileInfo, error) {
+	if fsys == nil {
+		return os.Stat(name)
+	}
+	return fs.Stat(fsys, name)
+}
+
+// fileModTime returns the modification time of the named file. This is
+// only found for the operating system's file system; if the file system is
+// not nil, or the file cannot be found, the zero time is returned.
+func fileModTime(fsys fs.FS, name string) time.Time {
+	if fsys != nil {
+		return time.Time{}
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// expandDir returns the directory name with any environment variables
+// (given as $VAR or ${VAR}) replaced by their values and, if it starts
+// with "~" followed by a path separator or nothing else, with the "~"
+// replaced by the user's home directory. Names with nothing to expand,
+// such as absolute or already expanded paths, are returned unchanged. If
+// the home directory cannot be found the "~" is left in place.
+func expandDir(dir string) string {
+	dir = os.ExpandEnv(dir)
+	if dir != "~" && !strings.HasPrefix(dir, "~"+string(filepath.Separator)) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return dir
+	}
+	return home + dir[1:]
+}
+
+// expandDirs returns a copy of the directory names, each expanded as for
+// expandDir
+func expandDirs(dirs []string) []string {
+	if dirs == nil {
+		return nil
+	}
+	rval := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		rval = append(rval, expandDir(dir))
+	}
+	return rval
+}
@@ -0,0 +1,72 @@
+package snippet
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+func TestExpandDir(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+	t.Setenv("SNIPPETS", "/opt/snippets")
+
+	testCases := []struct {
+		testhelper.ID
+		dir    string
+		expDir string
+	}{
+		{
+			ID:     testhelper.MkID("absolute"),
+			dir:    "/usr/share/snippets",
+			expDir: "/usr/share/snippets",
+		},
+		{
+			ID:     testhelper.MkID("relative"),
+			dir:    filepath.Join("testdata", "test.snippets"),
+			expDir: filepath.Join("testdata", "test.snippets"),
+		},
+		{
+			ID:     testhelper.MkID("environment variable"),
+			dir:    "$HOME/.snippets",
+			expDir: "/home/user/.snippets",
+		},
+		{
+			ID:     testhelper.MkID("braced environment variable"),
+			dir:    "${SNIPPETS}/go",
+			expDir: "/opt/snippets/go",
+		},
+		{
+			ID:     testhelper.MkID("home directory"),
+			dir:    "~",
+			expDir: "/home/user",
+		},
+		{
+			ID:     testhelper.MkID("in the home directory"),
+			dir:    "~/team-snippets",
+			expDir: "/home/user/team-snippets",
+		},
+		{
+			ID:     testhelper.MkID("another user's home directory"),
+			dir:    "~other/snippets",
+			expDir: "~other/snippets",
+		},
+	}
+
+	for _, tc := range testCases {
+		testhelper.DiffString(t, tc.IDStr(), "expanded dir",
+			expandDir(tc.dir), tc.expDir)
+	}
+}
+
+func TestCacheAddExpandsDirs(t *testing.T) {
+	t.Setenv("SNIPPET_DIR", TestSnippets)
+
+	sc := Cache{}
+	s, err := sc.Add([]string{"$SNIPPET_DIR"}, "complete")
+	if err != nil {
+		t.Fatal("cannot add the snippet: ", err)
+	}
+	testhelper.DiffString(t, "expanded dir", "path",
+		s.Path(), filepath.Join(TestSnippets, "complete"))
+}
@@ -0,0 +1,65 @@
+package snippet
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ChangedBetween returns the names of the snippets in the snippet directory
+// (repoDir) which have been added or changed between the two git refs. The
+// snippet directory must be in a git repository (though it need not be at
+// the top of the repository) and the git command must be available. Deleted
+// snippets are not reported. The GzipSuffix of any compressed snippet
+// file is removed to give the snippet name.
+//
+// Only the files which are snippets are given: the snippet directory is
+// read as when listing the snippets, with the given options, and any
+// changed file which is not found as a snippet, such as an ignore file
+// (see IgnoreFileName) or a file matching an ignore pattern, is left out.
+// Note that this means that a snippet which is not in the snippet
+// directory as it is now is also left out. The names are sorted and can
+// be passed to SetConstraints to list just the changed snippets.
+func ChangedBetween(repoDir, fromRef, toRef string,
+	opts ...ListCfgOptFunc,
+) ([]string, error) {
+	cmd := exec.Command("git", "-C", repoDir,
+		"diff", "--name-only", "--relative", "--diff-filter=d", "-z",
+		fromRef, toRef, "--")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("cannot find the snippets in %q changed"+
+			" between %q and %q: %w: %s",
+			repoDir, fromRef, toRef, err,
+			strings.TrimSpace(stderr.String()))
+	}
+
+	lc, errs := findSnippetFiles([]string{repoDir}, opts...)
+	if lc == nil {
+		return nil, errs[0]
+	}
+	isSnippet := map[string]bool{}
+	for _, sf := range lc.pending {
+		isSnippet[sf.sName] = true
+	}
+
+	names := []string{}
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name == "" {
+			continue
+		}
+		name = strings.TrimSuffix(filepath.FromSlash(name), GzipSuffix)
+		if isSnippet[name] {
+			names = append(names, name)
+		}
+	}
+	names = tidySlice(names)
+
+	return names, nil
+}
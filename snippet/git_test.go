@@ -0,0 +1,104 @@
+package snippet
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+// gitCmd runs the git command in the given directory and aborts the test if
+// it fails
+func gitCmd(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	args = append([]string{
+		"-C", dir,
+		"-c", "user.name=test", "-c", "user.email=test@example.com",
+	}, args...)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %s\n%s", args, err, out)
+	}
+}
+
+// writeFile writes the file and aborts the test if it fails
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o777); err != nil {
+		t.Fatal("cannot make the directory: ", err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o666); err != nil {
+		t.Fatal("cannot write the file: ", err)
+	}
+}
+
+func TestChangedBetween(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	repo := t.TempDir()
+	snipDir := filepath.Join(repo, "snippets")
+
+	gitCmd(t, repo, "init", "-q")
+	writeFile(t, filepath.Join(snipDir, "unchanged"), "a()\n")
+	writeFile(t, filepath.Join(snipDir, "changed"), "b()\n")
+	writeFile(t, filepath.Join(snipDir, "deleted"), "c()\n")
+	writeFile(t, filepath.Join(repo, "README"), "not a snippet\n")
+	gitCmd(t, repo, "add", ".")
+	gitCmd(t, repo, "commit", "-q", "-m", "first")
+	gitCmd(t, repo, "tag", "first")
+
+	writeFile(t, filepath.Join(snipDir, "changed"), "b(1)\n")
+	writeFile(t, filepath.Join(snipDir, "sub", "added"), "d()\n")
+	writeFile(t, filepath.Join(snipDir, "zipped"+GzipSuffix), "e()\n")
+	writeFile(t, filepath.Join(snipDir, IgnoreFileName), "ignored\n")
+	writeFile(t, filepath.Join(snipDir, "ignored"), "f()\n")
+	writeFile(t, filepath.Join(snipDir, "changed~"), "b()\n")
+	writeFile(t, filepath.Join(repo, "README"), "still not a snippet\n")
+	gitCmd(t, repo, "rm", "-q", filepath.Join(snipDir, "deleted"))
+	gitCmd(t, repo, "add", ".")
+	gitCmd(t, repo, "commit", "-q", "-m", "second")
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		fromRef  string
+		toRef    string
+		expNames []string
+	}{
+		{
+			ID:      testhelper.MkID("changed and added"),
+			fromRef: "first",
+			toRef:   "HEAD",
+			expNames: []string{
+				"changed",
+				filepath.Join("sub", "added"),
+				"zipped",
+			},
+		},
+		{
+			ID:       testhelper.MkID("no changes"),
+			fromRef:  "HEAD",
+			toRef:    "HEAD",
+			expNames: []string{},
+		},
+		{
+			ID:      testhelper.MkID("bad ref"),
+			ExpErr:  testhelper.MkExpErr("cannot find the snippets in"),
+			fromRef: "nonesuch",
+			toRef:   "HEAD",
+		},
+	}
+
+	for _, tc := range testCases {
+		names, err := ChangedBetween(snipDir, tc.fromRef, tc.toRef)
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffStringSlice(t, tc.IDStr(), "names",
+				names, tc.expNames)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package snippet
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SnippetKind describes the sort of Go code held in the text of a snippet
+// and so where it can be used.
+type SnippetKind int
+
+const (
+	// KindUnknown is used for snippet text which cannot be parsed as Go
+	// code in any of the contexts tried
+	KindUnknown SnippetKind = iota
+	// KindWholeFile is used for snippet text which is a complete Go file,
+	// starting with a package clause
+	KindWholeFile
+	// KindDeclarations is used for snippet text which can be used at the
+	// top level of a Go file
+	KindDeclarations
+	// KindExpression is used for snippet text which is a single Go
+	// expression
+	KindExpression
+	// KindStatements is used for snippet text which can be used in the body
+	// of a function
+	KindStatements
+)
+
+// String returns a description of the SnippetKind
+func (k SnippetKind) String() string {
+	switch k {
+	case KindWholeFile:
+		return "whole file"
+	case KindDeclarations:
+		return "declarations"
+	case KindExpression:
+		return "expression"
+	case KindStatements:
+		return "statements"
+	}
+	return "unknown"
+}
+
+const (
+	declPrefix = "package snippet\n"
+	stmtPrefix = declPrefix + "func _() {\n"
+	stmtSuffix = "\n}\n"
+)
+
+// Kind returns the kind of Go code held in the snippet text. It is found by
+// trying to parse the text in each of the possible contexts. Snippet text
+// can often be valid in more than one context (variable declarations are
+// valid both at the top level and in a function body, a single function
+// call is both an expression and a statement) and in that case the first
+// of the following kinds is chosen: whole file, declarations, expression,
+// statements. This is the kind which allows the text to be used most
+// widely. If the text cannot be parsed in any context then KindUnknown is
+// returned.
+func (s S) Kind() SnippetKind {
+	src := strings.Join(s.text, "\n")
+	fset := token.NewFileSet()
+
+	if _, err := parser.ParseFile(fset, "", src, 0); err == nil {
+		return KindWholeFile
+	}
+	if _, err := parser.ParseFile(fset, "", declPrefix+src, 0); err == nil {
+		return KindDeclarations
+	}
+	if _, err := parser.ParseExpr(src); err == nil {
+		return KindExpression
+	}
+	_, err := parser.ParseFile(fset, "", stmtPrefix+src+stmtSuffix, 0)
+	if err == nil {
+		return KindStatements
+	}
+	return KindUnknown
+}
+
+// parseText parses the snippet text as Go code in the context given by its
+// kind and returns the parsed file. Expressions are parsed as statements.
+func (s S) parseText() (*ast.File, error) {
+	src := strings.Join(s.text, "\n")
+	switch s.Kind() {
+	case KindWholeFile:
+	case KindDeclarations:
+		src = declPrefix + src
+	default:
+		src = stmtPrefix + src + stmtSuffix
+	}
+	return parser.ParseFile(token.NewFileSet(), "", src, 0)
+}
+
+// ShadowsBuiltins returns the names declared in the snippet text which are
+// the same as one of Go's predeclared identifiers (such as len, error or
+// string). The names found are those of top-level declarations, variables
+// declared in function bodies and the receivers, parameters and results of
+// functions. Struct field and interface method names are not included.
+// The names are sorted and given only once. If the text cannot be parsed
+// no names are returned.
+func (s S) ShadowsBuiltins() []string {
+	f, err := s.parseText()
+	if err != nil {
+		return []string{}
+	}
+
+	names := []string{}
+	addIdent := func(e ast.Expr) {
+		if id, ok := e.(*ast.Ident); ok &&
+			types.Universe.Lookup(id.Name) != nil {
+			names = append(names, id.Name)
+		}
+	}
+	addFields := func(fl *ast.FieldList) {
+		if fl == nil {
+			return
+		}
+		for _, f := range fl.List {
+			for _, id := range f.Names {
+				addIdent(id)
+			}
+		}
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Recv == nil {
+				addIdent(n.Name)
+			}
+			addFields(n.Recv)
+		case *ast.ValueSpec:
+			for _, id := range n.Names {
+				addIdent(id)
+			}
+		case *ast.TypeSpec:
+			addIdent(n.Name)
+		case *ast.FuncType:
+			addFields(n.Params)
+			addFields(n.Results)
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				for _, e := range n.Lhs {
+					addIdent(e)
+				}
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				addIdent(n.Key)
+				addIdent(n.Value)
+			}
+		}
+		return true
+	})
+
+	return tidySlice(names)
+}
+
+// IsGofmtClean reports whether the snippet text is already formatted as
+// gofmt would format it. If it is not then the formatted text is also
+// returned. Text which is not a whole file is formatted as a list of
+// declarations or statements and keeps its indentation. An error is
+// returned if the text cannot be parsed as Go code.
+func (s S) IsGofmtClean() (bool, string, error) {
+	if s.Kind() == KindUnknown {
+		return false, "", errors.New("the snippet text is not valid Go code")
+	}
+
+	src := strings.Join(s.text, "\n")
+	out, err := format.Source([]byte(src))
+	if err != nil {
+		return false, "", err
+	}
+
+	formatted := string(out)
+	if s.Kind() == KindWholeFile {
+		formatted = strings.TrimSuffix(formatted, "\n")
+	}
+	if formatted == src {
+		return true, "", nil
+	}
+	return false, formatted, nil
+}
+
+// CheckTextParses returns an error if the snippet text cannot be parsed as
+// Go code. The text is parsed as a whole file if it starts with a package
+// clause and otherwise both as top-level declarations and as the body of a
+// function. If it cannot be parsed then the error reported is the one found
+// furthest into the text, as the likeliest context for the snippet. The
+// position of the error is given as the line and column in the snippet
+// text. A snippet with no text is not reported.
+func (s S) CheckTextParses() error {
+	if len(s.text) == 0 || s.Kind() != KindUnknown {
+		return nil
+	}
+
+	src := strings.Join(s.text, "\n")
+	contexts := []struct {
+		prefix, suffix string
+	}{
+		{prefix: declPrefix},
+		{prefix: stmtPrefix, suffix: stmtSuffix},
+	}
+	if strings.HasPrefix(strings.TrimSpace(src), "package") {
+		contexts = []struct {
+			prefix, suffix string
+		}{{}}
+	}
+
+	var firstErr *scanner.Error
+	for _, c := range contexts {
+		_, err := parser.ParseFile(token.NewFileSet(), "",
+			c.prefix+src+c.suffix, 0)
+		var errList scanner.ErrorList
+		if !errors.As(err, &errList) || len(errList) == 0 {
+			continue
+		}
+		e := *errList[0]
+		e.Pos.Line -= strings.Count(c.prefix, "\n")
+		if e.Pos.Line > len(s.text) {
+			e.Pos.Line = len(s.text)
+			e.Pos.Column = len(s.text[len(s.text)-1]) + 1
+		}
+		if firstErr == nil ||
+			e.Pos.Line > firstErr.Pos.Line ||
+			(e.Pos.Line == firstErr.Pos.Line &&
+				e.Pos.Column > firstErr.Pos.Column) {
+			firstErr = &e
+		}
+	}
+	if firstErr == nil {
+		return errors.New("the snippet text is not valid Go code")
+	}
+
+	return fmt.Errorf("the snippet text is not valid Go code:"+
+		" line %d, column %d: %s",
+		firstErr.Pos.Line, firstErr.Pos.Column, firstErr.Msg)
+}
+
+// majorVersionRE matches the final element of an import path which gives
+// the major version of a module, such as v2
+var majorVersionRE = regexp.MustCompile(`^v[0-9]+$`)
+
+// importName returns the name by which the imported package is referred to
+// in the code. This is the alias if one is given and otherwise is taken
+// from the last element of the import path, ignoring any major version
+// element (as in "example.com/mod/v2") or suffix (as in "gopkg.in/yaml.v3").
+// This is the usual convention but a package can have a name which differs
+// from its path.
+func importName(imp string) string {
+	if fields := strings.Fields(imp); len(fields) > 1 {
+		return fields[0]
+	}
+
+	p := importPath(imp)
+	name := path.Base(p)
+	if majorVersionRE.MatchString(name) && path.Dir(p) != "." {
+		name = path.Base(path.Dir(p))
+	}
+	if i := strings.LastIndex(name, ".v"); i > 0 {
+		if _, err := strconv.Atoi(name[i+2:]); err == nil {
+			name = name[:i]
+		}
+	}
+	return name
+}
+
+// CheckImports compares the imports declared for the snippet with the
+// packages used in its text. The unused imports are those declared but
+// never referred to in the text; the names of the undeclared packages are
+// those which are used in the text (as the X in a selector X.Y where X is
+// not declared in the snippet) but are not imported. Imports in the text of
+// a snippet which is a whole file are counted as declared. Blank and dot
+// imports are never reported as unused. Note that the package name is
+// taken from the import path (see importName) and that a variable declared
+// in some other snippet will be reported as an undeclared package. Both
+// results are sorted. If the text cannot be parsed then all the imports are
+// reported as unused.
+func (s S) CheckImports() (unused []string, undeclared []string) {
+	declared := map[string][]string{}
+	addImport := func(imp string) {
+		name := importName(imp)
+		declared[name] = append(declared[name], importPath(imp))
+	}
+	for _, imp := range s.imports {
+		addImport(imp)
+	}
+
+	used := map[string]bool{}
+	if f, err := s.parseText(); err == nil {
+		for _, spec := range f.Imports {
+			imp := spec.Path.Value
+			if spec.Name != nil {
+				imp = spec.Name.Name + " " + imp
+			}
+			addImport(imp)
+		}
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+					used[id.Name] = true
+				}
+			}
+			return true
+		})
+	}
+
+	unused = []string{}
+	for name, paths := range declared {
+		if name == "_" || name == "." || used[name] {
+			continue
+		}
+		unused = append(unused, paths...)
+	}
+
+	undeclared = []string{}
+	for name := range used {
+		if _, ok := declared[name]; !ok {
+			undeclared = append(undeclared, name)
+		}
+	}
+
+	return tidySlice(unused), tidySlice(undeclared)
+}
@@ -0,0 +1,317 @@
+package snippet
+
+import (
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+func TestKind(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		text    []string
+		expKind SnippetKind
+	}{
+		{
+			ID:      testhelper.MkID("whole file"),
+			text:    []string{"package main", "", "func main() {}"},
+			expKind: KindWholeFile,
+		},
+		{
+			ID:      testhelper.MkID("declarations"),
+			text:    []string{"func f() {", "}", "type T int"},
+			expKind: KindDeclarations,
+		},
+		{
+			ID:      testhelper.MkID("ambiguous - declaration or statement"),
+			text:    []string{"var x = 1"},
+			expKind: KindDeclarations,
+		},
+		{
+			ID:      testhelper.MkID("ambiguous - expression or statement"),
+			text:    []string{`fmt.Println("Hello")`},
+			expKind: KindExpression,
+		},
+		{
+			ID:      testhelper.MkID("statements"),
+			text:    []string{"x := 1", `fmt.Println(x)`},
+			expKind: KindStatements,
+		},
+		{
+			ID:      testhelper.MkID("not Go"),
+			text:    []string{"contents of snip1"},
+			expKind: KindUnknown,
+		},
+	}
+
+	for _, tc := range testCases {
+		s := S{text: tc.text}
+		kind := s.Kind()
+		testhelper.DiffString(t, tc.IDStr(), "kind",
+			kind.String(), tc.expKind.String())
+	}
+}
+
+func TestShadowsBuiltins(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		text     []string
+		expNames []string
+	}{
+		{
+			ID:       testhelper.MkID("nothing shadowed"),
+			text:     []string{"x := 1", "fmt.Println(len(x))"},
+			expNames: []string{},
+		},
+		{
+			ID: testhelper.MkID("declarations"),
+			text: []string{
+				"type error struct{}",
+				"var len, x = 1, 2",
+				"const true = 0",
+				"func cap(string int) (nil bool) { return }",
+				"func (T) copy() {}",
+			},
+			expNames: []string{"cap", "error", "len", "nil", "string", "true"},
+		},
+		{
+			ID: testhelper.MkID("statements"),
+			text: []string{
+				"len := 1",
+				"for _, int := range x {",
+				"	var new = int",
+				"}",
+			},
+			expNames: []string{"int", "len", "new"},
+		},
+		{
+			ID: testhelper.MkID("struct fields and interface methods"),
+			text: []string{
+				"type T struct{ len int; cap int }",
+				"type I interface{ copy() int }",
+				"f := func(new int) {}",
+			},
+			expNames: []string{"new"},
+		},
+		{
+			ID:       testhelper.MkID("not Go"),
+			text:     []string{"contents of snip1"},
+			expNames: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		s := S{text: tc.text}
+		testhelper.DiffStringSlice(t, tc.IDStr(), "names",
+			s.ShadowsBuiltins(), tc.expNames)
+	}
+}
+
+func TestIsGofmtClean(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		text         []string
+		expClean     bool
+		expFormatted string
+	}{
+		{
+			ID:       testhelper.MkID("clean whole file"),
+			text:     []string{"package main", "", "func main() {}"},
+			expClean: true,
+		},
+		{
+			ID:           testhelper.MkID("unclean whole file"),
+			text:         []string{"package main", "func main() {  }"},
+			expFormatted: "package main\n\nfunc main() {}",
+		},
+		{
+			ID:       testhelper.MkID("clean statements"),
+			text:     []string{"x := 1", "fmt.Println(x)"},
+			expClean: true,
+		},
+		{
+			ID:           testhelper.MkID("unclean statements"),
+			text:         []string{"x:=1", "if x>0 {", "fmt.Println(x)", "}"},
+			expFormatted: "x := 1\nif x > 0 {\n\tfmt.Println(x)\n}",
+		},
+		{
+			ID:           testhelper.MkID("unclean declarations"),
+			text:         []string{"type T struct {", "A int", "BB string", "}"},
+			expFormatted: "type T struct {\n\tA  int\n\tBB string\n}",
+		},
+		{
+			ID:       testhelper.MkID("clean expression"),
+			text:     []string{"a + b"},
+			expClean: true,
+		},
+		{
+			ID:     testhelper.MkID("not Go"),
+			text:   []string{"contents of snip1"},
+			ExpErr: testhelper.MkExpErr("the snippet text is not valid Go code"),
+		},
+	}
+
+	for _, tc := range testCases {
+		s := S{text: tc.text}
+		clean, formatted, err := s.IsGofmtClean()
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffBool(t, tc.IDStr(), "clean", clean, tc.expClean)
+			testhelper.DiffString(t, tc.IDStr(), "formatted text",
+				formatted, tc.expFormatted)
+		}
+	}
+}
+
+func TestCheckTextParses(t *testing.T) {
+	const notGo = "the snippet text is not valid Go code:"
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		text []string
+	}{
+		{
+			ID: testhelper.MkID("no text"),
+		},
+		{
+			ID:   testhelper.MkID("statements"),
+			text: []string{"x := 1", "fmt.Println(x)"},
+		},
+		{
+			ID:   testhelper.MkID("declarations"),
+			text: []string{"type T struct {", "A int", "}"},
+		},
+		{
+			ID:   testhelper.MkID("bad statements"),
+			text: []string{"x := 1", "if x > 0 {", "fmt.Println(x))", "}"},
+			ExpErr: testhelper.MkExpErr(notGo,
+				"line 3, column 15", "expected statement, found ')'"),
+		},
+		{
+			ID: testhelper.MkID("bad declarations"),
+			text: []string{
+				"func f() int {", "\treturn 1 +", "}", "", "var x = 1",
+			},
+			ExpErr: testhelper.MkExpErr(notGo,
+				"line 3, column 1", "expected operand, found '}'"),
+		},
+		{
+			ID:   testhelper.MkID("bad whole file"),
+			text: []string{"package main", "", "func main() {", "x :="},
+			ExpErr: testhelper.MkExpErr(notGo,
+				"line 4, column 5", "expected operand, found 'EOF'"),
+		},
+		{
+			ID:   testhelper.MkID("unterminated block"),
+			text: []string{"if x {", "y()"},
+			ExpErr: testhelper.MkExpErr(notGo,
+				"line 2, column 4", "expected '}', found 'EOF'"),
+		},
+	}
+
+	for _, tc := range testCases {
+		s := S{text: tc.text}
+		err := s.CheckTextParses()
+		testhelper.CheckExpErr(t, err, tc)
+	}
+}
+
+func TestImportName(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		imp     string
+		expName string
+	}{
+		{ID: testhelper.MkID("simple"), imp: "fmt", expName: "fmt"},
+		{ID: testhelper.MkID("path"), imp: "net/http", expName: "http"},
+		{ID: testhelper.MkID("quoted"), imp: `"net/http"`, expName: "http"},
+		{ID: testhelper.MkID("alias"), imp: `h "net/http"`, expName: "h"},
+		{
+			ID:      testhelper.MkID("major version"),
+			imp:     "github.com/nickwells/testhelper.mod/v2/testhelper",
+			expName: "testhelper",
+		},
+		{
+			ID:      testhelper.MkID("major version at the end"),
+			imp:     "example.com/mod/v2",
+			expName: "mod",
+		},
+		{
+			ID:      testhelper.MkID("version suffix"),
+			imp:     "gopkg.in/yaml.v3",
+			expName: "yaml",
+		},
+	}
+
+	for _, tc := range testCases {
+		testhelper.DiffString(t, tc.IDStr(), "name",
+			importName(tc.imp), tc.expName)
+	}
+}
+
+func TestCheckImports(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		imports       []string
+		text          []string
+		expUnused     []string
+		expUndeclared []string
+	}{
+		{
+			ID:      testhelper.MkID("all used"),
+			imports: []string{"fmt", "os"},
+			text:    []string{`fmt.Fprintln(os.Stderr, "hello")`},
+		},
+		{
+			ID:            testhelper.MkID("unused and undeclared"),
+			imports:       []string{"fmt", "os", "strings"},
+			text:          []string{`fmt.Println(filepath.Join("a", "b"))`},
+			expUnused:     []string{"os", "strings"},
+			expUndeclared: []string{"filepath"},
+		},
+		{
+			ID:      testhelper.MkID("aliased, blank and dot imports"),
+			imports: []string{`str "strings"`, `_ "embed"`, `. "math"`},
+			text:    []string{`x := str.ToUpper("a")`, "y := Pi"},
+		},
+		{
+			ID:      testhelper.MkID("local variables are not packages"),
+			imports: []string{"net/http"},
+			text: []string{
+				"var c http.Client",
+				"func f(r *http.Request) string { return r.Method + c.Jar }",
+			},
+		},
+		{
+			ID: testhelper.MkID("whole file"),
+			text: []string{
+				"package main",
+				`import "fmt"`,
+				`import "os"`,
+				`func main() { fmt.Println("hello") }`,
+			},
+			expUnused: []string{"os"},
+		},
+		{
+			ID:        testhelper.MkID("not Go"),
+			imports:   []string{"fmt"},
+			text:      []string{"contents of snip1"},
+			expUnused: []string{"fmt"},
+		},
+	}
+
+	for _, tc := range testCases {
+		s := S{imports: tc.imports, text: tc.text}
+		unused, undeclared := s.CheckImports()
+		if tc.expUnused == nil {
+			tc.expUnused = []string{}
+		}
+		if tc.expUndeclared == nil {
+			tc.expUndeclared = []string{}
+		}
+		testhelper.DiffStringSlice(t, tc.IDStr(), "unused",
+			unused, tc.expUnused)
+		testhelper.DiffStringSlice(t, tc.IDStr(), "undeclared",
+			undeclared, tc.expUndeclared)
+	}
+}
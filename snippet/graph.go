@@ -0,0 +1,727 @@
+package snippet
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/nickwells/errutil.mod/errutil"
+)
+
+// Expand adds the named snippet to the cache together with every snippet
+// it expects, and every snippet they expect in turn, searching for them in
+// the snippet directories. It returns the snippets ordered so that each
+// snippet comes after any snippet it follows (see followsOrder). Any
+// problems found are recorded in the returned error map; if the named
+// snippet cannot be added no snippets are returned.
+func (c *Cache) Expand(snippetDirs []string, sName string) (
+	[]*S, *errutil.ErrMap,
+) {
+	em := errutil.NewErrMap()
+
+	if _, err := c.Add(snippetDirs, sName); err != nil {
+		em.AddError(fmt.Sprintf("Missing snippet %q", sName), err)
+		return nil, em
+	}
+
+	names := []string{sName}
+	seen := map[string]bool{sName: true}
+	for i := 0; i < len(names); i++ {
+		s := c.snippets[names[i]]
+		for _, exp := range s.expects {
+			if seen[exp] {
+				continue
+			}
+			seen[exp] = true
+			if _, err := c.Add(snippetDirs, exp); err != nil {
+				em.AddError(fmt.Sprintf("Missing snippet %q", exp),
+					fmt.Errorf("expected by %q: %w", s.name, err))
+				continue
+			}
+			names = append(names, exp)
+		}
+	}
+
+	ordered, err := c.followsOrder(names)
+	if err != nil {
+		em.AddError("Bad snippet order", err)
+	}
+	return ordered, em
+}
+
+// OrderByFollows returns the named snippets ordered so that each snippet
+// comes after any of the other named snippets which it follows. Snippets
+// with no ordering relationship between them are ordered by name. An error
+// is returned if any of the snippets is not in the cache or if the follows
+// relationships form a cycle; the error names the snippets which could not
+// be ordered.
+func (c Cache) OrderByFollows(names []string) ([]*S, error) {
+	for _, n := range names {
+		if _, ok := c.snippets[n]; !ok {
+			return nil, fmt.Errorf("%q is not in the snippet cache", n)
+		}
+	}
+
+	ordered, err := c.followsOrder(names)
+	if err != nil {
+		return nil, err
+	}
+	return ordered, nil
+}
+
+// OrderWithFollowed behaves as OrderByFollows except that the snippets
+// which the named snippets follow, and those which they follow in turn,
+// are also included if they are in the cache.
+func (c Cache) OrderWithFollowed(names []string) ([]*S, error) {
+	all := []string{}
+	seen := map[string]bool{}
+	for _, n := range names {
+		if !seen[n] {
+			seen[n] = true
+			all = append(all, n)
+		}
+	}
+	for i := 0; i < len(all); i++ {
+		s, ok := c.snippets[all[i]]
+		if !ok {
+			continue
+		}
+		for _, f := range s.follows {
+			if _, ok := c.snippets[f]; ok && !seen[f] {
+				seen[f] = true
+				all = append(all, f)
+			}
+		}
+	}
+	return c.OrderByFollows(all)
+}
+
+// Compose gathers the named snippets and every snippet they expect, and
+// every snippet those expect in turn, and combines them into a single
+// fragment of code. It returns the imports of all the snippets, sorted and
+// without duplicates, and the text of the snippets, ordered as for
+// OrderByFollows and with a blank line between the text of each snippet.
+// An error is returned if any of the snippets is not in the cache or if
+// they cannot be ordered.
+func (c Cache) Compose(names []string) ([]string, []string, error) {
+	all := []string{}
+	seen := map[string]bool{}
+	for _, n := range names {
+		if !seen[n] {
+			seen[n] = true
+			all = append(all, n)
+		}
+	}
+	for i := 0; i < len(all); i++ {
+		s, ok := c.snippets[all[i]]
+		if !ok {
+			return nil, nil,
+				fmt.Errorf("%q is not in the snippet cache", all[i])
+		}
+		for _, exp := range s.expects {
+			if !seen[exp] {
+				seen[exp] = true
+				all = append(all, exp)
+			}
+		}
+	}
+
+	ordered, err := c.followsOrder(all)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	imports := []string{}
+	text := []string{}
+	for _, s := range ordered {
+		imports = append(imports, s.imports...)
+		if len(s.text) > 0 && len(text) > 0 {
+			text = append(text, "")
+		}
+		text = append(text, s.text...)
+	}
+	return tidySlice(imports), text, nil
+}
+
+// followsOrder returns the named snippets, which must all be in the cache,
+// ordered so that each snippet comes after any of the other named snippets
+// which it follows. Snippets with no ordering relationship between them are
+// ordered by name. If the snippets cannot be ordered because the follows
+// relationships form a cycle an error is returned together with the
+// snippets which could be ordered.
+func (c Cache) followsOrder(names []string) ([]*S, error) {
+	inSet := map[string]bool{}
+	for _, n := range names {
+		inSet[n] = true
+	}
+
+	// count, for each snippet, the number of snippets it must come after
+	// and record which snippets must come after it
+	before := map[string]int{}
+	after := map[string][]string{}
+	for n := range inSet {
+		for _, f := range c.snippets[n].follows {
+			if inSet[f] && f != n {
+				before[n]++
+				after[f] = append(after[f], n)
+			}
+		}
+	}
+
+	ready := []string{}
+	for n := range inSet {
+		if before[n] == 0 {
+			ready = append(ready, n)
+		}
+	}
+
+	ordered := make([]*S, 0, len(inSet))
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		n := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, c.snippets[n])
+		for _, a := range after[n] {
+			before[a]--
+			if before[a] == 0 {
+				ready = append(ready, a)
+			}
+		}
+	}
+
+	if len(ordered) != len(inSet) {
+		unordered := []string{}
+		for n := range inSet {
+			if before[n] > 0 {
+				unordered = append(unordered, n)
+			}
+		}
+		sort.Strings(unordered)
+		return ordered, fmt.Errorf(
+			"the follows relationships between these snippets"+
+				" form a cycle: %s",
+			strings.Join(unordered, ", "))
+	}
+	return ordered, nil
+}
+
+// cycleError returns an error describing the cycle of snippets. The cycle
+// is given as the chain of snippets from the start of the cycle and back
+// to it.
+func cycleError(relation string, cycle []string) error {
+	return fmt.Errorf("the %s relationships form a cycle: %s",
+		relation, strings.Join(cycle, " -> "))
+}
+
+// LongestChain returns the names of the snippets along the longest chain of
+// expected snippets in the cache: the first snippet expects the second,
+// which expects the third and so on. Only snippets in the cache are
+// considered. Where there is more than one longest chain the one which
+// comes first alphabetically is returned. If the expects relationships
+// form a cycle an error is returned.
+func (c Cache) LongestChain() ([]string, error) {
+	longest := map[string][]string{}
+	onStack := map[string]bool{}
+	stack := []string{}
+
+	var chainFrom func(name string) ([]string, error)
+	chainFrom = func(name string) ([]string, error) {
+		if chain, ok := longest[name]; ok {
+			return chain, nil
+		}
+		if onStack[name] {
+			for i, n := range stack {
+				if n == name {
+					return nil, cycleError(ExpectPart,
+						append(append([]string{}, stack[i:]...), name))
+				}
+			}
+		}
+
+		onStack[name] = true
+		stack = append(stack, name)
+		defer func() {
+			onStack[name] = false
+			stack = stack[:len(stack)-1]
+		}()
+
+		var best []string
+		for _, exp := range c.snippets[name].expects {
+			if _, ok := c.snippets[exp]; !ok {
+				continue
+			}
+			chain, err := chainFrom(exp)
+			if err != nil {
+				return nil, err
+			}
+			if len(chain) > len(best) {
+				best = chain
+			}
+		}
+
+		chain := append([]string{name}, best...)
+		longest[name] = chain
+		return chain, nil
+	}
+
+	var best []string
+	for _, name := range c.Names() {
+		chain, err := chainFrom(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(chain) > len(best) {
+			best = chain
+		}
+	}
+	return best, nil
+}
+
+// dependencies returns the names of the snippets which the named snippet
+// expects or follows and which are in the cache, in alphabetical order.
+func (c Cache) dependencies(name string) []string {
+	s, ok := c.snippets[name]
+	if !ok {
+		return []string{}
+	}
+	deps := []string{}
+	for _, d := range append(s.Expects(), s.follows...) {
+		if _, ok := c.snippets[d]; ok {
+			deps = append(deps, d)
+		}
+	}
+	return tidySlice(deps)
+}
+
+// Reachable returns true if the snippet named by to can be reached from
+// the snippet named by from through the expects and follows relationships
+// of the snippets in the cache. If it can then the shortest chain of
+// snippets leading from one to the other is also returned. A snippet is
+// always reachable from itself.
+func (c Cache) Reachable(from, to string) (bool, []string) {
+	if _, ok := c.snippets[from]; !ok {
+		return false, nil
+	}
+	if _, ok := c.snippets[to]; !ok {
+		return false, nil
+	}
+
+	reachedFrom := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		if name == to {
+			path := []string{}
+			for n := to; n != ""; n = reachedFrom[n] {
+				path = append([]string{n}, path...)
+			}
+			return true, path
+		}
+
+		for _, dep := range c.dependencies(name) {
+			if _, seen := reachedFrom[dep]; !seen {
+				reachedFrom[dep] = name
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return false, nil
+}
+
+// CheckFollowCycles records an error in the error map for each cycle in
+// the follows relationships between the snippets in the cache. A snippet
+// which follows itself is reported as a cycle. Each group of snippets which
+// are all reachable from one another through the follows relationships is
+// reported once, giving the shortest cycle from the first snippet in the
+// group, in alphabetical order, back to itself.
+func (c Cache) CheckFollowCycles(em *errutil.ErrMap) {
+	for _, cycle := range c.cycles(func(s *S) []string { return s.follows }) {
+		em.AddError("Follows cycle", cycleError(FollowPart, cycle))
+	}
+}
+
+// ResolveExpects returns the names of the named snippet and of every
+// snippet it expects, directly or through the snippets it expects, in
+// alphabetical order. These are all the snippets needed to use the named
+// snippet. An error is returned if the named snippet, or any snippet it
+// needs, is not in the cache; the error gives the chain of expects
+// relationships which led to the missing snippet.
+func (c Cache) ResolveExpects(sName string) ([]string, error) {
+	start, err := c.Get(sName)
+	if err != nil {
+		return nil, err
+	}
+
+	needed := map[string]bool{}
+	var visit func(s *S, chain []string) error
+	visit = func(s *S, chain []string) error {
+		needed[s.name] = true
+		for _, e := range tidySlice(copySlice(s.expects)) {
+			if needed[e] {
+				continue
+			}
+			eChain := append(copySlice(chain), e)
+			es, ok := c.snippets[e]
+			if !ok {
+				return fmt.Errorf(
+					"%q is not in the snippet cache but is needed by: %s",
+					e, strings.Join(eChain, " -> "))
+			}
+			if err := visit(es, eChain); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := visit(start, []string{start.name}); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(needed))
+	for n := range needed {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ExpandIncludes returns a copy of the named snippet with the text of each
+// snippet it includes inserted in place of the include comment. The
+// included snippets may include others in turn and these are expanded
+// too. The imports of all the included snippets are added to those of the
+// returned snippet, which has no includes. An error is returned if any of
+// the snippets is not in the cache or if the includes form a cycle.
+func (c Cache) ExpandIncludes(sName string) (*S, error) {
+	s, err := c.Get(sName)
+	if err != nil {
+		return nil, err
+	}
+	return c.expandIncludes(s, []string{s.name})
+}
+
+// expandIncludes returns a copy of the snippet with its includes expanded.
+// The chain holds the names of the snippets being expanded, from the
+// snippet first asked for down to this one, and is used to detect cycles.
+func (c Cache) expandIncludes(s *S, chain []string) (*S, error) {
+	x := s.Clone()
+	x.text = nil
+	x.includes = nil
+
+	next := 0
+	for _, inc := range s.includes {
+		x.text = append(x.text, s.text[next:inc.line]...)
+		next = inc.line
+
+		incChain := append(copySlice(chain), inc.name)
+		if containsString(chain, inc.name) {
+			return nil, cycleError(IncludePart, incChain)
+		}
+		is, ok := c.snippets[inc.name]
+		if !ok {
+			return nil, fmt.Errorf(
+				"%q is not in the snippet cache but is included by: %s",
+				inc.name, strings.Join(incChain, " -> "))
+		}
+		expanded, err := c.expandIncludes(is, incChain)
+		if err != nil {
+			return nil, err
+		}
+		x.text = append(x.text, expanded.text...)
+		x.imports = append(x.imports, expanded.imports...)
+	}
+	x.text = append(x.text, s.text[next:]...)
+	x.imports = tidySlice(x.imports)
+
+	return x, nil
+}
+
+// CheckOrderingConsistency records an error in the error map for each
+// snippet which follows another snippet that must, through the combined
+// expects and follows relationships, come after it. The ordering rules
+// are:
+//
+//   - if A follows B then A must come after B
+//   - if A expects B but does not follow it then A must come before B
+//     (this is why expects has the alternative name "comesbefore")
+//
+// A contradiction is reported where A follows B but there is also a chain
+// of these rules, using at least one expects which is not a follows,
+// requiring B to come after A. For instance, if A follows B, C expects B
+// and C follows A then A must come after B, B after C and C after A. A
+// chain made only of follows relationships is a cycle and is reported by
+// CheckFollowCycles instead; a contradiction is reported for each follows
+// relationship which is part of it. Only snippets in the cache are
+// considered.
+func (c Cache) CheckOrderingConsistency(em *errutil.ErrMap) {
+	// expectedBy maps a snippet to those which expect but don't follow it
+	expectedBy := map[string][]string{}
+	for _, name := range c.Names() {
+		s := c.snippets[name]
+		for _, e := range tidySlice(copySlice(s.expects)) {
+			if !containsString(s.follows, e) {
+				expectedBy[e] = append(expectedBy[e], name)
+			}
+		}
+	}
+
+	for _, name := range c.Names() {
+		for _, f := range tidySlice(copySlice(c.snippets[name].follows)) {
+			if _, ok := c.snippets[f]; !ok || f == name {
+				continue
+			}
+			if c.followsReachable(f, name) {
+				continue
+			}
+			if chain := c.comesAfterChain(f, name, expectedBy); chain != "" {
+				em.AddError("Contradictory ordering",
+					fmt.Errorf("%q follows %q but %q must come after %q: %s",
+						name, f, f, name, chain))
+			}
+		}
+	}
+}
+
+// followsReachable returns true if the snippet named to can be reached
+// from the snippet named from through the follows relationships.
+func (c Cache) followsReachable(from, to string) bool {
+	seen := map[string]bool{from: true}
+	queue := []string{from}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if name == to {
+			return true
+		}
+		for _, f := range c.snippets[name].follows {
+			if _, ok := c.snippets[f]; ok && !seen[f] {
+				seen[f] = true
+				queue = append(queue, f)
+			}
+		}
+	}
+	return false
+}
+
+// comesAfterChain returns a description of the shortest chain of ordering
+// rules (see CheckOrderingConsistency) requiring the snippet named from to
+// come after the snippet named to. If there is no such chain an empty
+// string is returned.
+func (c Cache) comesAfterChain(from, to string,
+	expectedBy map[string][]string,
+) string {
+	type step struct {
+		prev   string
+		clause string
+	}
+	reached := map[string]step{from: {}}
+	queue := []string{from}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		if name == to {
+			clauses := []string{}
+			for n := to; n != from; n = reached[n].prev {
+				clauses = append([]string{reached[n].clause}, clauses...)
+			}
+			return strings.Join(clauses, ", ")
+		}
+
+		for _, f := range tidySlice(copySlice(c.snippets[name].follows)) {
+			if _, ok := c.snippets[f]; ok {
+				if _, seen := reached[f]; !seen {
+					reached[f] = step{
+						prev:   name,
+						clause: fmt.Sprintf("%q follows %q", name, f),
+					}
+					queue = append(queue, f)
+				}
+			}
+		}
+		for _, e := range expectedBy[name] {
+			if _, seen := reached[e]; !seen {
+				reached[e] = step{
+					prev:   name,
+					clause: fmt.Sprintf("%q expects %q", e, name),
+				}
+				queue = append(queue, e)
+			}
+		}
+	}
+	return ""
+}
+
+// CheckExpectCycles records an error in the error map for each cycle in
+// the expects relationships between the snippets in the cache. As a
+// snippet which follows another also expects it, any cycle in the follows
+// relationships is also reported here. The cycles are found and reported
+// in the same way as for CheckFollowCycles.
+func (c Cache) CheckExpectCycles(em *errutil.ErrMap) {
+	for _, cycle := range c.cycles(func(s *S) []string { return s.expects }) {
+		em.AddError("Expects cycle", cycleError(ExpectPart, cycle))
+	}
+}
+
+// cycles returns a cycle for each group of snippets in the cache which are
+// all reachable from one another through the relationships given by the
+// next func. Only snippets in the cache are considered. Each cycle starts
+// and ends with the first snippet in the group in alphabetical order and
+// the cycles are sorted by that snippet.
+func (c Cache) cycles(next func(*S) []string) [][]string {
+	neighbours := func(name string) []string {
+		ns := []string{}
+		for _, n := range next(c.snippets[name]) {
+			if _, ok := c.snippets[n]; ok {
+				ns = append(ns, n)
+			}
+		}
+		return tidySlice(ns)
+	}
+
+	// Tarjan's algorithm for finding strongly connected components
+	index := map[string]int{}
+	lowLink := map[string]int{}
+	onStack := map[string]bool{}
+	stack := []string{}
+	groups := [][]string{}
+
+	var connect func(name string)
+	connect = func(name string) {
+		index[name] = len(index)
+		lowLink[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, n := range neighbours(name) {
+			if _, visited := index[n]; !visited {
+				connect(n)
+				if lowLink[n] < lowLink[name] {
+					lowLink[name] = lowLink[n]
+				}
+			} else if onStack[n] && index[n] < lowLink[name] {
+				lowLink[name] = index[n]
+			}
+		}
+
+		if lowLink[name] == index[name] {
+			group := []string{}
+			for {
+				n := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[n] = false
+				group = append(group, n)
+				if n == name {
+					break
+				}
+			}
+			groups = append(groups, tidySlice(group))
+		}
+	}
+
+	for _, name := range c.Names() {
+		if _, visited := index[name]; !visited {
+			connect(name)
+		}
+	}
+
+	cycles := [][]string{}
+	for _, group := range groups {
+		start := group[0]
+		if len(group) == 1 && !containsString(neighbours(start), start) {
+			continue
+		}
+		cycles = append(cycles, c.shortestCycle(start, group, neighbours))
+	}
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i][0] < cycles[j][0]
+	})
+	return cycles
+}
+
+// shortestCycle returns the shortest chain of snippets, within the group,
+// from the start snippet back to itself. The start snippet must be in a
+// cycle within the group.
+func (c Cache) shortestCycle(start string, group []string,
+	neighbours func(string) []string,
+) []string {
+	inGroup := map[string]bool{}
+	for _, n := range group {
+		inGroup[n] = true
+	}
+
+	reachedFrom := map[string]string{start: ""}
+	queue := []string{start}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, n := range neighbours(name) {
+			if n == start {
+				cycle := []string{start}
+				for p := name; p != ""; p = reachedFrom[p] {
+					cycle = append([]string{p}, cycle...)
+				}
+				return cycle
+			}
+			if _, seen := reachedFrom[n]; !seen && inGroup[n] {
+				reachedFrom[n] = name
+				queue = append(queue, n)
+			}
+		}
+	}
+	return []string{start, start}
+}
+
+// WriteDOT writes the graph of the relationships between the snippets in
+// the cache in the Graphviz DOT language. There is a node for each snippet
+// and an edge, labelled "expects" or "follows", from each snippet to each
+// snippet it expects or follows; a snippet which is followed is also
+// expected but only the follows edge is shown. Any snippet which is
+// expected but is not in the cache is shown as a dashed, red node. The
+// output can be turned into a diagram with, for instance:
+//
+//	dot -Tsvg -o snippets.svg
+func (c Cache) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("digraph snippets {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box];\n")
+
+	names := c.Names()
+	missing := map[string]bool{}
+	for _, name := range names {
+		fmt.Fprintf(&b, "\t%q;\n", name)
+		for _, e := range c.snippets[name].expects {
+			if _, ok := c.snippets[e]; !ok {
+				missing[e] = true
+			}
+		}
+	}
+	missingNames := make([]string, 0, len(missing))
+	for name := range missing {
+		missingNames = append(missingNames, name)
+	}
+	sort.Strings(missingNames)
+	for _, name := range missingNames {
+		fmt.Fprintf(&b,
+			"\t%q [style=dashed, color=red, fontcolor=red];\n", name)
+	}
+
+	for _, name := range names {
+		s := c.snippets[name]
+		for _, e := range s.expects {
+			label := ExpectPart
+			if containsString(s.follows, e) {
+				label = FollowPart
+			}
+			fmt.Fprintf(&b, "\t%q -> %q [label=%q];\n", name, e, label)
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
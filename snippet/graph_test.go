@@ -0,0 +1,686 @@
+package snippet
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickwells/errutil.mod/errutil"
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+// snippetNames returns the names of the snippets
+func snippetNames(snippets []*S) []string {
+	names := []string{}
+	for _, s := range snippets {
+		names = append(names, s.Name())
+	}
+	return names
+}
+
+func TestExpand(t *testing.T) {
+	snippetDirs := []string{GraphSnippets}
+
+	testCases := []struct {
+		testhelper.ID
+		sName    string
+		expNames []string
+		expErrs  errutil.ErrMap
+	}{
+		{
+			ID:       testhelper.MkID("no dependencies"),
+			sName:    "setup",
+			expNames: []string{"setup"},
+		},
+		{
+			ID:       testhelper.MkID("ordered dependencies"),
+			sName:    "app",
+			expNames: []string{"helper", "setup", "run", "app"},
+		},
+		{
+			ID:       testhelper.MkID("missing dependency"),
+			sName:    "broken",
+			expNames: []string{"broken", "setup"},
+			expErrs: errutil.ErrMap{
+				`Missing snippet "nonesuch"`: []error{
+					errors.New(`expected by "broken": snippet "nonesuch"` +
+						` is not in the snippet directory:` +
+						` "` + GraphSnippets + `"`),
+				},
+			},
+		},
+		{
+			ID:       testhelper.MkID("missing snippet"),
+			sName:    "nonesuch",
+			expNames: []string{},
+			expErrs: errutil.ErrMap{
+				`Missing snippet "nonesuch"`: []error{
+					errors.New(`snippet "nonesuch"` +
+						` is not in the snippet directory:` +
+						` "` + GraphSnippets + `"`),
+				},
+			},
+		},
+		{
+			ID:       testhelper.MkID("cycle"),
+			sName:    "useCyc",
+			expNames: []string{"useCyc"},
+			expErrs: errutil.ErrMap{
+				"Bad snippet order": []error{
+					errors.New("the follows relationships between these" +
+						" snippets form a cycle: cycA, cycB"),
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		c := Cache{}
+		snippets, em := c.Expand(snippetDirs, tc.sName)
+		testhelper.DiffStringSlice(t, tc.IDStr(), "snippets",
+			snippetNames(snippets), tc.expNames)
+		if err := em.Matches(tc.expErrs); err != nil {
+			t.Log(tc.IDStr())
+			t.Errorf("\t: unexpected errors: %s", err)
+		}
+	}
+}
+
+func TestLongestChain(t *testing.T) {
+	snippetDirs := []string{GraphSnippets}
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		names    []string
+		expChain []string
+	}{
+		{
+			ID:       testhelper.MkID("empty cache"),
+			expChain: []string{},
+		},
+		{
+			ID:       testhelper.MkID("no expectations"),
+			names:    []string{"setup", "helper"},
+			expChain: []string{"helper"},
+		},
+		{
+			ID:       testhelper.MkID("chain"),
+			names:    []string{"app", "run", "setup", "helper", "broken"},
+			expChain: []string{"app", "run", "setup"},
+		},
+		{
+			ID: testhelper.MkID("cycle"),
+			ExpErr: testhelper.MkExpErr("the expects relationships" +
+				" form a cycle: cycA -> cycB -> cycA"),
+			names: []string{"cycA", "cycB"},
+		},
+	}
+
+	for _, tc := range testCases {
+		c := Cache{}
+		for _, name := range tc.names {
+			if _, err := c.Add(snippetDirs, name); err != nil {
+				t.Fatalf("%s: cannot add %q: %s", tc.IDStr(), name, err)
+			}
+		}
+		chain, err := c.LongestChain()
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffStringSlice(t, tc.IDStr(), "chain",
+				chain, tc.expChain)
+		}
+	}
+}
+
+func TestReachable(t *testing.T) {
+	snippetDirs := []string{GraphSnippets}
+	c := Cache{}
+	for _, name := range []string{
+		"app", "run", "setup", "helper", "cycA", "cycB",
+	} {
+		if _, err := c.Add(snippetDirs, name); err != nil {
+			t.Fatalf("cannot add %q: %s", name, err)
+		}
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		from, to     string
+		expReachable bool
+		expPath      []string
+	}{
+		{
+			ID:           testhelper.MkID("self"),
+			from:         "app",
+			to:           "app",
+			expReachable: true,
+			expPath:      []string{"app"},
+		},
+		{
+			ID:           testhelper.MkID("direct"),
+			from:         "app",
+			to:           "run",
+			expReachable: true,
+			expPath:      []string{"app", "run"},
+		},
+		{
+			ID:           testhelper.MkID("indirect"),
+			from:         "app",
+			to:           "setup",
+			expReachable: true,
+			expPath:      []string{"app", "run", "setup"},
+		},
+		{
+			ID:   testhelper.MkID("wrong direction"),
+			from: "setup",
+			to:   "app",
+		},
+		{
+			ID:   testhelper.MkID("not in the cache"),
+			from: "app",
+			to:   "nonesuch",
+		},
+		{
+			ID:           testhelper.MkID("through a cycle"),
+			from:         "cycA",
+			to:           "cycB",
+			expReachable: true,
+			expPath:      []string{"cycA", "cycB"},
+		},
+		{
+			ID:   testhelper.MkID("unreachable from a cycle"),
+			from: "cycB",
+			to:   "app",
+		},
+	}
+
+	for _, tc := range testCases {
+		reachable, path := c.Reachable(tc.from, tc.to)
+		testhelper.DiffBool(t, tc.IDStr(), "reachable",
+			reachable, tc.expReachable)
+		testhelper.DiffStringSlice(t, tc.IDStr(), "path", path, tc.expPath)
+	}
+}
+
+func TestCheckFollowCycles(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		snippets []*S
+		expErrs  errutil.ErrMap
+	}{
+		{
+			ID: testhelper.MkID("empty cache"),
+		},
+		{
+			ID: testhelper.MkID("no cycles"),
+			snippets: []*S{
+				{name: "a", follows: []string{"b", "c"}},
+				{name: "b", follows: []string{"c", "nonesuch"}},
+				{name: "c"},
+			},
+		},
+		{
+			ID: testhelper.MkID("cycles"),
+			snippets: []*S{
+				{name: "self", follows: []string{"self"}},
+				{name: "x", follows: []string{"y"}},
+				{name: "y", follows: []string{"z", "a"}},
+				{name: "z", follows: []string{"x"}},
+				{name: "a", follows: []string{"b"}},
+				{name: "b", follows: []string{"a", "c"}},
+				{name: "c"},
+			},
+			expErrs: errutil.ErrMap{
+				"Follows cycle": []error{
+					errors.New("the follows relationships form a cycle:" +
+						" a -> b -> a"),
+					errors.New("the follows relationships form a cycle:" +
+						" self -> self"),
+					errors.New("the follows relationships form a cycle:" +
+						" x -> y -> z -> x"),
+				},
+			},
+		},
+		{
+			ID: testhelper.MkID("shortest cycle"),
+			snippets: []*S{
+				{name: "a", follows: []string{"b", "c"}},
+				{name: "b", follows: []string{"d"}},
+				{name: "c", follows: []string{"a"}},
+				{name: "d", follows: []string{"a"}},
+			},
+			expErrs: errutil.ErrMap{
+				"Follows cycle": []error{
+					errors.New("the follows relationships form a cycle:" +
+						" a -> c -> a"),
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		c := Cache{}
+		for _, s := range tc.snippets {
+			c.store(s.name, s)
+		}
+		em := errutil.NewErrMap()
+		c.CheckFollowCycles(em)
+		if err := em.Matches(tc.expErrs); err != nil {
+			t.Log(tc.IDStr())
+			t.Errorf("\t: unexpected errors: %s", err)
+		}
+	}
+}
+
+func TestCheckExpectCycles(t *testing.T) {
+	dirs := []string{filepath.Join("testdata", "expectCycle.snippets")}
+	c := Cache{}
+	for _, name := range []string{"user", "first", "sub/second", "third",
+		"self"} {
+		if _, err := c.Add(dirs, name); err != nil {
+			t.Fatalf("cannot add %q: %s", name, err)
+		}
+	}
+
+	em := errutil.NewErrMap()
+	c.CheckExpectCycles(em)
+	err := em.Matches(errutil.ErrMap{
+		"Expects cycle": []error{
+			errors.New("the expects relationships form a cycle:" +
+				" first -> sub/second -> third -> first"),
+			errors.New("the expects relationships form a cycle:" +
+				" self -> self"),
+		},
+	})
+	if err != nil {
+		t.Error("unexpected errors: ", err)
+	}
+
+	c = Cache{}
+	for _, s := range []*S{
+		{name: "a", expects: []string{"b", "c"}, follows: []string{"b"}},
+		{name: "b", expects: []string{"c"}},
+		{name: "c", expects: []string{"nonesuch"}},
+	} {
+		c.store(s.name, s)
+	}
+	em = errutil.NewErrMap()
+	c.CheckExpectCycles(em)
+	if err := em.Matches(errutil.ErrMap{}); err != nil {
+		t.Error("unexpected errors for an acyclic graph: ", err)
+	}
+}
+
+func TestCheckFollowCyclesFromFiles(t *testing.T) {
+	c := Cache{}
+	for _, name := range []string{"cycA", "cycB", "app", "run", "setup"} {
+		if _, err := c.Add([]string{GraphSnippets}, name); err != nil {
+			t.Fatalf("cannot add %q: %s", name, err)
+		}
+	}
+
+	em := errutil.NewErrMap()
+	c.CheckFollowCycles(em)
+	err := em.Matches(errutil.ErrMap{
+		"Follows cycle": []error{
+			errors.New("the follows relationships form a cycle:" +
+				" cycA -> cycB -> cycA"),
+		},
+	})
+	if err != nil {
+		t.Error("unexpected errors: ", err)
+	}
+}
+
+func TestOrderByFollows(t *testing.T) {
+	c := Cache{}
+	for _, s := range []*S{
+		{name: "a", follows: []string{"b"}},
+		{name: "b", follows: []string{"c"}},
+		{name: "c"},
+		{name: "d"},
+		{name: "e", follows: []string{"a"}},
+		{name: "x", follows: []string{"y"}},
+		{name: "y", follows: []string{"x"}},
+	} {
+		c.store(s.name, s)
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		names        []string
+		withFollowed bool
+		expNames     []string
+	}{
+		{
+			ID:       testhelper.MkID("no names"),
+			expNames: []string{},
+		},
+		{
+			ID:       testhelper.MkID("ordered, ties by name"),
+			names:    []string{"e", "d", "a", "c"},
+			expNames: []string{"a", "c", "d", "e"},
+		},
+		{
+			ID:           testhelper.MkID("with followed snippets"),
+			names:        []string{"e", "d"},
+			withFollowed: true,
+			expNames:     []string{"c", "b", "a", "d", "e"},
+		},
+		{
+			ID:    testhelper.MkID("cycle"),
+			names: []string{"x", "y", "d"},
+			ExpErr: testhelper.MkExpErr("the follows relationships" +
+				" between these snippets form a cycle: x, y"),
+		},
+		{
+			ID:           testhelper.MkID("missing snippet"),
+			names:        []string{"a", "nonesuch"},
+			withFollowed: true,
+			ExpErr: testhelper.MkExpErr(
+				`"nonesuch" is not in the snippet cache`),
+		},
+	}
+
+	for _, tc := range testCases {
+		var ordered []*S
+		var err error
+		if tc.withFollowed {
+			ordered, err = c.OrderWithFollowed(tc.names)
+		} else {
+			ordered, err = c.OrderByFollows(tc.names)
+		}
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffStringSlice(t, tc.IDStr(), "order",
+				snippetNames(ordered), tc.expNames)
+		}
+	}
+}
+
+func TestCompose(t *testing.T) {
+	c := Cache{}
+	for _, s := range []*S{
+		{
+			name:    "main",
+			imports: []string{"fmt", "os"},
+			expects: []string{"setup", "helper"},
+			follows: []string{"setup"},
+			text:    []string{"run()", "os.Exit(0)"},
+		},
+		{
+			name:    "setup",
+			imports: []string{"fmt"},
+			expects: []string{"conf"},
+			follows: []string{"conf"},
+			text:    []string{"setup()"},
+		},
+		{name: "conf", imports: []string{"flag"}},
+		{name: "helper", text: []string{"helper()"}},
+		{name: "broken", expects: []string{"nonesuch"}, text: []string{"x"}},
+	} {
+		c.store(s.name, s)
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		names      []string
+		expImports []string
+		expText    []string
+	}{
+		{
+			ID:         testhelper.MkID("nothing"),
+			expImports: []string{},
+			expText:    []string{},
+		},
+		{
+			ID:         testhelper.MkID("expected snippets"),
+			names:      []string{"main"},
+			expImports: []string{"flag", "fmt", "os"},
+			expText: []string{
+				"helper()",
+				"",
+				"setup()",
+				"",
+				"run()",
+				"os.Exit(0)",
+			},
+		},
+		{
+			ID:    testhelper.MkID("missing expected snippet"),
+			names: []string{"helper", "broken"},
+			ExpErr: testhelper.MkExpErr(
+				`"nonesuch" is not in the snippet cache`),
+		},
+	}
+
+	for _, tc := range testCases {
+		imports, text, err := c.Compose(tc.names)
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffStringSlice(t, tc.IDStr(), "imports",
+				imports, tc.expImports)
+			testhelper.DiffStringSlice(t, tc.IDStr(), "text",
+				text, tc.expText)
+		}
+	}
+}
+
+func TestResolveExpects(t *testing.T) {
+	c := Cache{}
+	for _, s := range []*S{
+		{name: "app", expects: []string{"run", "setup"}},
+		{name: "run", expects: []string{"helper", "setup"}},
+		{name: "setup"},
+		{name: "helper", expects: []string{"setup"}},
+		{name: "x", expects: []string{"y"}},
+		{name: "y", expects: []string{"x"}},
+		{name: "broken", expects: []string{"app", "partial"}},
+		{name: "partial", expects: []string{"nonesuch"}},
+	} {
+		c.store(s.name, s)
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		sName    string
+		expNames []string
+	}{
+		{
+			ID:       testhelper.MkID("no expectations"),
+			sName:    "setup",
+			expNames: []string{"setup"},
+		},
+		{
+			ID:       testhelper.MkID("transitive expectations"),
+			sName:    "app",
+			expNames: []string{"app", "helper", "run", "setup"},
+		},
+		{
+			ID:       testhelper.MkID("cycle"),
+			sName:    "x",
+			expNames: []string{"x", "y"},
+		},
+		{
+			ID:    testhelper.MkID("missing expected snippet"),
+			sName: "broken",
+			ExpErr: testhelper.MkExpErr(`"nonesuch" is not in the` +
+				` snippet cache but is needed by:` +
+				` broken -> partial -> nonesuch`),
+		},
+		{
+			ID:     testhelper.MkID("missing snippet"),
+			sName:  "nonesuch",
+			ExpErr: testhelper.MkExpErr(`"nonesuch" is not in the snippet cache`),
+		},
+	}
+
+	for _, tc := range testCases {
+		names, err := c.ResolveExpects(tc.sName)
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffStringSlice(t, tc.IDStr(), "names",
+				names, tc.expNames)
+		}
+	}
+}
+
+func TestExpandIncludes(t *testing.T) {
+	c := Cache{}
+	for name, content := range map[string]string{
+		"app": "// snippet: imports: os\n" +
+			"func main() {\n" +
+			"// snippet: include: check\n" +
+			"\tos.Exit(0)\n" +
+			"}\n" +
+			"// snippet: include: done\n",
+		"check": "// snippet: imports: fmt\n" +
+			"// snippet: include: done\n" +
+			"\tfmt.Println(\"checked\")\n",
+		"done":    "// snippet: imports: log\nlog.Print(\"done\")\n",
+		"x":       "// snippet: include: y\n",
+		"y":       "// snippet: include: x\n",
+		"broken":  "// snippet: include: check, nonesuch\n",
+		"partial": "// snippet: include: broken\n",
+	} {
+		s, err := parseSnippet([]byte(content), name, name)
+		if err != nil {
+			t.Fatal("cannot parse snippet: ", err)
+		}
+		c.store(name, s)
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		sName      string
+		expText    []string
+		expImports []string
+	}{
+		{
+			ID:         testhelper.MkID("no includes"),
+			sName:      "done",
+			expText:    []string{`log.Print("done")`},
+			expImports: []string{"log"},
+		},
+		{
+			ID:    testhelper.MkID("nested includes"),
+			sName: "app",
+			expText: []string{
+				"func main() {",
+				`log.Print("done")`,
+				`	fmt.Println("checked")`,
+				"	os.Exit(0)",
+				"}",
+				`log.Print("done")`,
+			},
+			expImports: []string{"fmt", "log", "os"},
+		},
+		{
+			ID:    testhelper.MkID("cycle"),
+			sName: "x",
+			ExpErr: testhelper.MkExpErr(
+				"the include relationships form a cycle: x -> y -> x"),
+		},
+		{
+			ID:    testhelper.MkID("missing included snippet"),
+			sName: "partial",
+			ExpErr: testhelper.MkExpErr(`"nonesuch" is not in the` +
+				` snippet cache but is included by:` +
+				` partial -> broken -> nonesuch`),
+		},
+		{
+			ID:     testhelper.MkID("missing snippet"),
+			sName:  "nonesuch",
+			ExpErr: testhelper.MkExpErr(`"nonesuch" is not in the snippet cache`),
+		},
+	}
+
+	for _, tc := range testCases {
+		s, err := c.ExpandIncludes(tc.sName)
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffStringSlice(t, tc.IDStr(), "text",
+				s.Text(), tc.expText)
+			testhelper.DiffStringSlice(t, tc.IDStr(), "imports",
+				s.Imports(), tc.expImports)
+			testhelper.DiffInt(t, tc.IDStr(), "includes",
+				len(s.Includes()), 0)
+		}
+	}
+
+	orig, _ := c.Get("app")
+	testhelper.DiffStringSlice(t, "app", "original includes",
+		orig.Includes(), []string{"check", "done"})
+	testhelper.DiffStringSlice(t, "app", "original text",
+		orig.Text(), []string{"func main() {", "\tos.Exit(0)", "}"})
+}
+
+func TestWriteDOT(t *testing.T) {
+	c := Cache{}
+	for _, s := range []*S{
+		{name: "app", expects: []string{"run", "setup"},
+			follows: []string{"setup"}},
+		{name: "run", expects: []string{"nonesuch"}},
+		{name: "setup"},
+	} {
+		c.store(s.name, s)
+	}
+
+	var b strings.Builder
+	if err := c.WriteDOT(&b); err != nil {
+		t.Fatal("cannot write the graph: ", err)
+	}
+	testhelper.DiffString(t, "WriteDOT", "graph", b.String(),
+		"digraph snippets {\n"+
+			"\trankdir=LR;\n"+
+			"\tnode [shape=box];\n"+
+			"\t\"app\";\n"+
+			"\t\"run\";\n"+
+			"\t\"setup\";\n"+
+			"\t\"nonesuch\" [style=dashed, color=red, fontcolor=red];\n"+
+			"\t\"app\" -> \"run\" [label=\"expects\"];\n"+
+			"\t\"app\" -> \"setup\" [label=\"follows\"];\n"+
+			"\t\"run\" -> \"nonesuch\" [label=\"expects\"];\n"+
+			"}\n")
+
+	err := c.WriteDOT(errWriter{})
+	if err == nil {
+		t.Error("a failed write was not reported")
+	}
+}
+
+func TestCheckOrderingConsistency(t *testing.T) {
+	c := Cache{}
+	for _, s := range []*S{
+		// a follows b, c expects b (so comes before it), c follows a
+		{name: "a", expects: []string{"b"}, follows: []string{"b"}},
+		{name: "b"},
+		{name: "c", expects: []string{"a", "b"}, follows: []string{"a"}},
+		// consistent: e follows d, d expects e
+		{name: "d", expects: []string{"e"}},
+		{name: "e", expects: []string{"d"}, follows: []string{"d"}},
+		// a pure follows cycle, reported elsewhere
+		{name: "x", expects: []string{"y"}, follows: []string{"y"}},
+		{name: "y", expects: []string{"x"}, follows: []string{"x"}},
+		// follows a missing snippet
+		{name: "z", expects: []string{"nonesuch"},
+			follows: []string{"nonesuch"}},
+	} {
+		c.store(s.name, s)
+	}
+
+	em := errutil.NewErrMap()
+	c.CheckOrderingConsistency(em)
+	err := em.Matches(errutil.ErrMap{
+		"Contradictory ordering": []error{
+			errors.New(`"a" follows "b" but "b" must come after "a":` +
+				` "c" expects "b", "c" follows "a"`),
+			errors.New(`"c" follows "a" but "a" must come after "c":` +
+				` "a" follows "b", "c" expects "b"`),
+		},
+	})
+	if err != nil {
+		t.Error("unexpected errors: ", err)
+	}
+}
@@ -0,0 +1,142 @@
+package snippet
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFileName is the name of the file, in a snippet directory or any of
+// its sub-directories, giving the patterns of the names of the entries to
+// be ignored when listing the snippets.
+const IgnoreFileName = ".snippetignore"
+
+// ignoreRule records a pattern read from an ignore file
+type ignoreRule struct {
+	// pattern is the pattern as for filepath.Match
+	pattern string
+	// dirOnly is set if the rule only applies to directories
+	dirOnly bool
+	// anchored is set if the pattern is to be matched against the path
+	// relative to the directory holding the ignore file rather than
+	// against the name of the entry
+	anchored bool
+}
+
+// parseIgnoreFile returns the rules given by the content of an ignore
+// file. The format is similar to that of a .gitignore file: there is one
+// pattern per line and blank lines and lines starting with a '#' are
+// ignored. A pattern ending with a '/' only matches directories. A pattern
+// with a '/' at the start or in the middle is matched against the path
+// relative to the directory holding the ignore file, otherwise it is
+// matched against the name of the file or directory at any level below
+// it. Unlike a .gitignore file, negated patterns (starting with '!') are
+// not supported. An error is returned for each malformed pattern.
+func parseIgnoreFile(content []byte, fName string) ([]ignoreRule, []error) {
+	var rules []ignoreRule
+	var errs []error
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Split(scanLines)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "!") {
+			errs = append(errs,
+				fmt.Errorf("%s:%d: negated patterns are not supported: %q",
+					fName, lineNum, line))
+			continue
+		}
+
+		r := ignoreRule{pattern: line}
+		if strings.HasSuffix(r.pattern, "/") {
+			r.dirOnly = true
+			r.pattern = strings.TrimRight(r.pattern, "/")
+		}
+		if strings.Contains(r.pattern, "/") {
+			r.anchored = true
+			r.pattern = strings.TrimPrefix(r.pattern, "/")
+		}
+		r.pattern = filepath.FromSlash(r.pattern)
+
+		if _, err := filepath.Match(r.pattern, ""); err != nil ||
+			r.pattern == "" {
+			errs = append(errs,
+				fmt.Errorf("%s:%d: bad pattern: %q", fName, lineNum, line))
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules, errs
+}
+
+// matches returns true if the rule matches the entry. The relPath is the
+// path of the entry relative to the directory holding the ignore file.
+func (r ignoreRule) matches(relPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	name := filepath.Base(relPath)
+	if r.anchored {
+		name = relPath
+	}
+	matched, _ := filepath.Match(r.pattern, name)
+	return matched
+}
+
+// loadIgnoreFile reads the ignore file, if any, in the directory and
+// records its rules. Any problems are recorded as errors.
+func (lc *ListCfg) loadIgnoreFile(dirPath string) {
+	fName := filepath.Join(dirPath, IgnoreFileName)
+	content, err := readFile(lc.fsys, fName)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			lc.errs.AddError("Bad ignore file", err)
+		}
+		return
+	}
+
+	rules, errs := parseIgnoreFile(content, fName)
+	for _, err := range errs {
+		lc.errs.AddError("Bad ignore file", err)
+	}
+	if len(rules) > 0 {
+		lc.ignoreRules[dirPath] = rules
+	}
+}
+
+// ignoredByFile returns true if the entry in the sub-directory of the
+// snippet directory is matched by the rules from the ignore file in that
+// sub-directory or in any directory above it, up to the snippet directory.
+func (lc *ListCfg) ignoredByFile(dir, subDir string, de fs.DirEntry) bool {
+	if len(lc.ignoreRules) == 0 {
+		return false
+	}
+
+	relPath := filepath.Join(subDir, de.Name())
+	for d := subDir; ; d = filepath.Dir(d) {
+		if d == "." {
+			d = ""
+		}
+		rel := relPath
+		if d != "" {
+			rel = strings.TrimPrefix(relPath, d+string(filepath.Separator))
+		}
+		for _, r := range lc.ignoreRules[filepath.Join(dir, d)] {
+			if r.matches(rel, de.IsDir()) {
+				return true
+			}
+		}
+		if d == "" {
+			return false
+		}
+	}
+}
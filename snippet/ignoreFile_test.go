@@ -0,0 +1,91 @@
+package snippet
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+func TestParseIgnoreFile(t *testing.T) {
+	content := "# a comment\n" +
+		"\n" +
+		"*.md\n" +
+		"  drafts/  \n" +
+		"/top\n" +
+		"sub/*.bak\n" +
+		"!keep\n" +
+		"[\n" +
+		"/\n"
+
+	rules, errs := parseIgnoreFile([]byte(content), "ignore")
+
+	expRules := []ignoreRule{
+		{pattern: "*.md"},
+		{pattern: "drafts", dirOnly: true},
+		{pattern: "top", anchored: true},
+		{pattern: filepath.Join("sub", "*.bak"), anchored: true},
+	}
+	testhelper.DiffInt(t, "parsed", "rules", len(rules), len(expRules))
+	for i := 0; i < len(rules) && i < len(expRules); i++ {
+		if rules[i] != expRules[i] {
+			t.Errorf("rule %d: got %+v, want %+v", i, rules[i], expRules[i])
+		}
+	}
+
+	expErrs := []error{
+		errors.New(`ignore:7: negated patterns are not supported: "!keep"`),
+		errors.New(`ignore:8: bad pattern: "["`),
+		errors.New(`ignore:9: bad pattern: "/"`),
+	}
+	testhelper.DiffInt(t, "parsed", "errors", len(errs), len(expErrs))
+	for i := 0; i < len(errs) && i < len(expErrs); i++ {
+		testhelper.DiffErr(t, "parsed", "error", errs[i], expErrs[i])
+	}
+}
+
+func TestIgnoreRuleMatches(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		rule     ignoreRule
+		relPath  string
+		isDir    bool
+		expMatch bool
+	}{
+		{
+			ID:       testhelper.MkID("name, at any level"),
+			rule:     ignoreRule{pattern: "*.md"},
+			relPath:  filepath.Join("a", "b", "README.md"),
+			expMatch: true,
+		},
+		{
+			ID:      testhelper.MkID("directory only, file"),
+			rule:    ignoreRule{pattern: "drafts", dirOnly: true},
+			relPath: "drafts",
+		},
+		{
+			ID:       testhelper.MkID("directory only, directory"),
+			rule:     ignoreRule{pattern: "drafts", dirOnly: true},
+			relPath:  "drafts",
+			isDir:    true,
+			expMatch: true,
+		},
+		{
+			ID:      testhelper.MkID("anchored, lower level"),
+			rule:    ignoreRule{pattern: "top", anchored: true},
+			relPath: filepath.Join("a", "top"),
+		},
+		{
+			ID:       testhelper.MkID("anchored, path"),
+			rule:     ignoreRule{pattern: filepath.Join("a", "*"), anchored: true},
+			relPath:  filepath.Join("a", "top"),
+			expMatch: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		testhelper.DiffBool(t, tc.IDStr(), "matches",
+			tc.rule.matches(tc.relPath, tc.isDir), tc.expMatch)
+	}
+}
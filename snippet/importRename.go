@@ -0,0 +1,157 @@
+package snippet
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RenamePlan records the change to be made to a snippet when an imported
+// package is renamed.
+type RenamePlan struct {
+	// Name is the name of the snippet
+	Name string
+	// Path is the name of the snippet file
+	Path string
+	// Before is the list of imports before the rename
+	Before []string
+	// After is the list of imports after the rename
+	After []string
+
+	oldPath string
+	newPath string
+}
+
+// renamedImport returns the import path with the old path replaced by the
+// new. Packages below the old path are also renamed. The bool result is
+// false if the path is not affected by the rename.
+func renamedImport(path, oldPath, newPath string) (string, bool) {
+	if path == oldPath {
+		return newPath, true
+	}
+	if strings.HasPrefix(path, oldPath+"/") {
+		return newPath + strings.TrimPrefix(path, oldPath), true
+	}
+	return path, false
+}
+
+// renameImports returns the imports with any affected by the rename
+// changed. The bool result is false if no imports are affected.
+func renameImports(imports []string, oldPath, newPath string) (
+	[]string, bool,
+) {
+	changed := false
+	rval := make([]string, 0, len(imports))
+	for _, imp := range imports {
+		path := importPath(imp)
+		if newImp, ok := renamedImport(path, oldPath, newPath); ok {
+			imp = strings.Replace(imp, path, newImp, 1)
+			changed = true
+		}
+		rval = append(rval, imp)
+	}
+	return tidySlice(rval), changed
+}
+
+// PlanImportRename reads every snippet file in the snippet directories and
+// returns a plan for each snippet that imports the old path (or any
+// package below it) showing the imports before and after the path is
+// changed to the new path. The snippet files are found exactly as when
+// listing the snippets, with the same options, so ignored files are left
+// out and snippet directories which do not exist are ignored unless
+// RequireDirsExist is set. Note that eclipsed snippets are included. Any
+// problems finding, reading or parsing the snippets are returned as
+// errors. No files are changed; to make the change call Apply on the
+// plans.
+func PlanImportRename(dirs []string, oldPath, newPath string,
+	opts ...ListCfgOptFunc,
+) ([]RenamePlan, []error) {
+	plans := []RenamePlan{}
+
+	lc, errs := findSnippetFiles(dirs, opts...)
+	if lc == nil {
+		return plans, errs
+	}
+
+	for _, sf := range lc.pending {
+		content, err := readSnippetContent(lc.fsys, sf.fName)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		s, err := parseSnippet(content, sf.fName, sf.sName)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		after, changed := renameImports(s.Imports(), oldPath, newPath)
+		if changed {
+			plans = append(plans, RenamePlan{
+				Name:    sf.sName,
+				Path:    sf.fName,
+				Before:  s.Imports(),
+				After:   after,
+				oldPath: oldPath,
+				newPath: newPath,
+			})
+		}
+	}
+
+	return plans, errs
+}
+
+// Apply rewrites the snippet file, changing the imports as given by the
+// plan. Only the import comments are changed; the rest of the file is
+// left as it is. Where several imports are given on one line, separated
+// by commas, each of them is renamed. An error is returned if no import
+// is renamed. Compressed snippet files cannot be rewritten and an error
+// is returned for them.
+func (rp RenamePlan) Apply() error {
+	info, err := os.Stat(rp.Path)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(rp.Path)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(content, gzipMagic) {
+		return fmt.Errorf("cannot rename the imports of snippet %q:"+
+			" the snippet file is compressed", rp.Name)
+	}
+
+	applied := false
+	lines := strings.SplitAfter(string(content), "\n")
+	for i, l := range lines {
+		loc := snippetPartREs[ImportPart].FindStringIndex(l)
+		if loc == nil {
+			continue
+		}
+		entries := strings.Split(l[loc[1]:], ",")
+		for j, e := range entries {
+			path := importPath(e)
+			if path == "" {
+				continue
+			}
+			if newImp, ok := renamedImport(path,
+				rp.oldPath, rp.newPath); ok {
+				entries[j] = strings.Replace(e, path, newImp, 1)
+				applied = true
+			}
+		}
+		lines[i] = l[:loc[1]] + strings.Join(entries, ",")
+	}
+	if !applied {
+		return fmt.Errorf("cannot rename the imports of snippet %q:"+
+			" no import of %q was found", rp.Name, rp.oldPath)
+	}
+
+	err = os.WriteFile(rp.Path, []byte(strings.Join(lines, "")), info.Mode())
+	if err != nil {
+		return fmt.Errorf("cannot rename the imports of snippet %q: %w",
+			rp.Name, err)
+	}
+	return nil
+}
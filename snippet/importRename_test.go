@@ -0,0 +1,137 @@
+package snippet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+func TestPlanImportRename(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "uses"),
+		"// snippet: imports: example.com/old\n"+
+			"// snippet: imports: fmt\n"+
+			"x()\n")
+	writeFile(t, filepath.Join(dir, "sub", "usesSubPkg"),
+		"// snippet: imports: o example.com/old/sub\n"+
+			"y()\n")
+	writeFile(t, filepath.Join(dir, "unaffected"),
+		"// snippet: imports: example.com/older\n"+
+			"z()\n")
+	writeFile(t, filepath.Join(dir, "bad"), "// snippet: note: no text\n")
+
+	plans, errs := PlanImportRename([]string{dir},
+		"example.com/old", "example.org/new")
+
+	if len(errs) != 1 {
+		t.Errorf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+
+	expPlans := []RenamePlan{
+		{
+			Name:   filepath.Join("sub", "usesSubPkg"),
+			Before: []string{"o example.com/old/sub"},
+			After:  []string{"o example.org/new/sub"},
+		},
+		{
+			Name:   "uses",
+			Before: []string{"example.com/old", "fmt"},
+			After:  []string{"example.org/new", "fmt"},
+		},
+	}
+	if len(plans) != len(expPlans) {
+		t.Fatalf("expected %d plans, got %d: %v",
+			len(expPlans), len(plans), plans)
+	}
+	for i, p := range plans {
+		id := "plan: " + p.Name
+		testhelper.DiffString(t, id, "name", p.Name, expPlans[i].Name)
+		testhelper.DiffStringSlice(t, id, "before", p.Before, expPlans[i].Before)
+		testhelper.DiffStringSlice(t, id, "after", p.After, expPlans[i].After)
+	}
+
+	if err := plans[1].Apply(); err != nil {
+		t.Fatal("unexpected error applying the plan: ", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "uses"))
+	if err != nil {
+		t.Fatal("cannot read the renamed file: ", err)
+	}
+	testhelper.DiffString(t, "apply", "content", string(content),
+		"// snippet: imports: example.org/new\n"+
+			"// snippet: imports: fmt\n"+
+			"x()\n")
+}
+
+func TestRenamePlanApplyCommaSeparated(t *testing.T) {
+	dir := t.TempDir()
+	fName := filepath.Join(dir, "uses")
+	writeFile(t, fName,
+		"// snippet: imports: example.com/old/a, fmt, o example.com/old\n"+
+			"x()\n")
+
+	plans, errs := PlanImportRename([]string{dir},
+		"example.com/old", "example.org/new")
+	if len(errs) != 0 {
+		t.Fatal("unexpected errors: ", errs)
+	}
+	if len(plans) != 1 {
+		t.Fatalf("expected 1 plan, got %d: %v", len(plans), plans)
+	}
+	if err := plans[0].Apply(); err != nil {
+		t.Fatal("unexpected error applying the plan: ", err)
+	}
+	content, err := os.ReadFile(fName)
+	if err != nil {
+		t.Fatal("cannot read the renamed file: ", err)
+	}
+	testhelper.DiffString(t, "apply", "content", string(content),
+		"// snippet: imports: example.org/new/a, fmt, o example.org/new\n"+
+			"x()\n")
+
+	plans, errs = PlanImportRename([]string{dir},
+		"example.com/old", "example.org/new")
+	testhelper.DiffInt(t, "replan", "errors", len(errs), 0)
+	testhelper.DiffInt(t, "replan", "plans", len(plans), 0)
+
+	writeFile(t, fName, "// snippet: imports: fmt\nx()\n")
+	err = RenamePlan{
+		Name:    "uses",
+		Path:    fName,
+		oldPath: "example.com/old",
+		newPath: "example.org/new",
+	}.Apply()
+	if err == nil {
+		t.Error("expected an error when no import is renamed")
+	}
+}
+
+func TestPlanImportRenameMatchesListing(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, IgnoreFileName), "ignored\n")
+	writeFile(t, filepath.Join(dir, "ignored"),
+		"// snippet: imports: example.com/old\nx()\n")
+	writeFile(t, filepath.Join(dir, "uses~"),
+		"// snippet: imports: example.com/old\nx()\n")
+	writeFile(t, filepath.Join(dir, "uses"),
+		"// snippet: imports: example.com/old\nx()\n")
+	missing := filepath.Join(dir, "missing")
+
+	plans, errs := PlanImportRename([]string{dir, missing},
+		"example.com/old", "example.org/new")
+	if len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	names := []string{}
+	for _, p := range plans {
+		names = append(names, p.Name)
+	}
+	testhelper.DiffStringSlice(t, "ignored files", "names",
+		names, []string{"uses"})
+
+	_, errs = PlanImportRename([]string{missing},
+		"example.com/old", "example.org/new", RequireDirsExist(true))
+	testhelper.DiffInt(t, "required missing dir", "errors", len(errs), 1)
+}
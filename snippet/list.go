@@ -1,14 +1,26 @@
 package snippet
 
 import (
+	"bytes"
+	"context"
 	"crypto/md5"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/nickwells/errutil.mod/errutil"
 	"github.com/nickwells/pager.mod/pager"
@@ -36,6 +48,109 @@ func SetConstraints(vals ...string) ListCfgOptFunc {
 	}
 }
 
+// SetExcludeConstraints returns a ListCfgOptFunc which will set on a
+// ListCfg value the snippets and directories to be left out of the
+// listing. A snippet is left out if its name is given or if it is in a
+// directory (or sub-directory) which is given. Excludes take precedence
+// over constraints so a snippet which matches both is not shown. As with
+// constraints, the checks which need all the snippets to be read (such as
+// checking that expected snippets exist) are not made if there are
+// excludes.
+func SetExcludeConstraints(vals ...string) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		for _, v := range vals {
+			lc.excludes[filepath.Clean(v)] = true
+		}
+		return nil
+	}
+}
+
+// dfltIgnorePatterns holds the patterns of the names of directory entries
+// which are ignored by default: hidden files and directories and the backup
+// and auto-save files left by editors.
+var dfltIgnorePatterns = []string{".*", "*~", "#*#"}
+
+// DfltIgnorePatterns returns a copy of the patterns of the names of the
+// directory entries which are ignored by default when listing snippets.
+// See SetIgnorePatterns.
+func DfltIgnorePatterns() []string {
+	return copySlice(dfltIgnorePatterns)
+}
+
+// SetIgnorePatterns returns a ListCfgOptFunc which will set the patterns
+// of the names of directory entries to be ignored when reading the snippet
+// directories. The patterns are as for filepath.Match and are matched
+// against the name of each file or directory, not the whole pathname. An
+// ignored file is not read and an ignored directory is not searched; they
+// are never reported. The patterns replace any set before, including the
+// default patterns (see DfltIgnorePatterns); to ignore nothing give no
+// patterns. An error is returned if any pattern is malformed. Further
+// patterns can be given in an ignore file in any of the snippet
+// directories (see IgnoreFileName).
+func SetIgnorePatterns(globs ...string) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		for _, g := range globs {
+			if _, err := filepath.Match(g, ""); err != nil {
+				return fmt.Errorf("bad ignore pattern %q: %w", g, err)
+			}
+		}
+		lc.ignorePatterns = copySlice(globs)
+		return nil
+	}
+}
+
+// SetFS returns a ListCfgOptFunc which will set the file system that the
+// snippet directories and files are read from. By default, or if the file
+// system is nil, they are read from the operating system's file system.
+// This allows, for instance, snippets embedded in the program to be
+// listed. Note that absolute pathnames cannot be used with an fs.FS.
+func SetFS(fsys fs.FS) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.fsys = fsys
+		return nil
+	}
+}
+
+// SetWatchInterval returns a ListCfgOptFunc which will set the time
+// between the checks for changes made by Watch. By default, or if the
+// interval is not positive, the snippet directories are checked every
+// DfltWatchInterval.
+func SetWatchInterval(interval time.Duration) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.watchInterval = interval
+		return nil
+	}
+}
+
+// SetParallelism returns a ListCfgOptFunc which will set the number of
+// snippet files to read and parse at the same time. A value of zero means
+// that as many files are read as there are CPUs. By default the files are
+// read one at a time. The results do not depend on the parallelism.
+func SetParallelism(n int) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		if n < 0 {
+			return fmt.Errorf(
+				"the parallelism (%d) must not be negative", n)
+		}
+		if n == 0 {
+			n = runtime.NumCPU()
+		}
+		lc.parallelism = n
+		return nil
+	}
+}
+
+// SetRegistry returns a ListCfgOptFunc which will set the Registry used to
+// resolve the constraints. Any constraint which is an alias in the
+// Registry is replaced by the name of the snippet it refers to. An error is
+// reported if the snippet does not exist.
+func SetRegistry(r Registry) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.registry = r
+		return nil
+	}
+}
+
 // SetParts returns a ListCfgOptFunc which will set on a ListCfg value the
 // parts of the snippets to be shown.
 func SetParts(vals ...string) ListCfgOptFunc {
@@ -62,6 +177,205 @@ func SetTags(vals ...string) ListCfgOptFunc {
 	}
 }
 
+// SetTagValues returns a ListCfgOptFunc which will set the ListCfg to show
+// only those snippets having a tag with a given value. Each pair must be
+// given as the tag name and the value separated by a colon, as in a tag
+// comment, for instance "Author: John Doe". A snippet is shown if any of
+// its values for the named tag equals the value given. If more than one
+// pair is given the snippet is shown if it matches any of them.
+func SetTagValues(pairs ...string) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		for _, p := range pairs {
+			parts := strings.SplitN(p, ":", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+				return fmt.Errorf(
+					"bad tag value filter: %q (it should be tag: value)", p)
+			}
+			tag := strings.TrimSpace(parts[0])
+			lc.tagValueFilter[tag] = append(lc.tagValueFilter[tag],
+				strings.TrimSpace(parts[1]))
+		}
+		return nil
+	}
+}
+
+// SetTextMatch returns a ListCfgOptFunc which will set the ListCfg to show
+// only those snippets having a line of text matching the regular
+// expression. This is in addition to any other constraints so that a
+// snippet must satisfy them all to be shown. An error is returned if the
+// pattern is not a valid regular expression.
+func SetTextMatch(pattern string) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("bad text match pattern %q: %w", pattern, err)
+		}
+		lc.textMatch = re
+		return nil
+	}
+}
+
+// HideDeprecated returns a ListCfgOptFunc which will set the ListCfg to
+// not show any deprecated snippets. Otherwise deprecated snippets are shown
+// with a marker after the name. Note that deprecated snippets are still
+// checked.
+func HideDeprecated(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.hideDeprecated = val
+		return nil
+	}
+}
+
+// TrimTrailingBlankLines returns a ListCfgOptFunc which will set the
+// ListCfg to remove any blank lines from the end of the snippet text. Blank
+// lines within the text are kept. By default the text is left unchanged.
+func TrimTrailingBlankLines(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.trimTrailingBlankLines = val
+		return nil
+	}
+}
+
+// SetStatusFilter returns a ListCfgOptFunc which will set the ListCfg to
+// show only those snippets having one of the given statuses. Each status
+// must be one of the values given by ValidStatuses. Snippets with no status
+// are not shown if a filter is set.
+func SetStatusFilter(statuses ...string) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		for _, st := range statuses {
+			if _, ok := validStatuses[st]; !ok {
+				return fmt.Errorf("bad status filter: %q", st)
+			}
+			lc.statusFilter[st] = true
+		}
+		return nil
+	}
+}
+
+// RequireDirsExist returns a ListCfgOptFunc which will set the ListCfg to
+// report any snippet directory which does not exist. By default missing
+// snippet directories are silently ignored; setting this can help to find
+// a mistyped directory name. Note that directories which exist but cannot
+// be read are always reported.
+func RequireDirsExist(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.requireDirsExist = val
+		return nil
+	}
+}
+
+// AnnotateReferences returns a ListCfgOptFunc which will set the ListCfg to
+// mark each expected and followed snippet shown with whether or not it
+// exists in the snippet directories: a tick (✓) if it does and a cross
+// (✗) if it does not.
+func AnnotateReferences(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.annotateReferences = val
+		return nil
+	}
+}
+
+// AllowDuplicates returns a ListCfgOptFunc which will set the ListCfg to not
+// report snippets having the same content as another snippet. This can be
+// useful where such snippets are intended, for instance as aliases. The
+// content of each snippet is still hashed. By default duplicate snippets
+// are reported as errors.
+func AllowDuplicates(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.allowDuplicates = val
+		return nil
+	}
+}
+
+// SetDuplicateNormalization returns a ListCfgOptFunc which will set the
+// changes made to the content of each snippet file before it is compared
+// with the others when looking for duplicate snippets. See the
+// DuplicateNormalization values for details of what each one changes. By
+// default the content is compared exactly as it is read.
+func SetDuplicateNormalization(dn DuplicateNormalization) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		if !dn.isValid() {
+			return fmt.Errorf("bad duplicate normalization: %s", dn)
+		}
+		lc.dupNormalization = dn
+		return nil
+	}
+}
+
+// SetHashFunc returns a ListCfgOptFunc which will set the hash used to find
+// snippets with the same content. By default an MD5 hash is used; this can
+// be used to choose a different hash, for instance sha256.New.
+func SetHashFunc(h func() hash.Hash) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		if h == nil {
+			return errors.New("the hash func must not be nil")
+		}
+		lc.hashFunc = h
+		return nil
+	}
+}
+
+// SetIndent returns a ListCfgOptFunc which will set the number of spaces
+// by which the parts of each snippet are indented when listed. The value
+// must not be negative. The default is 8.
+func SetIndent(n int) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		if n < 0 {
+			return fmt.Errorf("the indent (%d) must not be negative", n)
+		}
+		lc.formatCfg.indent = n
+		lc.formatCfg.indentSet = true
+		return nil
+	}
+}
+
+// SetNameIndent returns a ListCfgOptFunc which will set the number of
+// spaces by which the name of each snippet is indented when listed. The
+// value must not be negative. The default is 4.
+func SetNameIndent(n int) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		if n < 0 {
+			return fmt.Errorf("the name indent (%d) must not be negative", n)
+		}
+		lc.formatCfg.nameIndent = n
+		lc.formatCfg.nameIndentSet = true
+		return nil
+	}
+}
+
+// SetWrapWidth returns a ListCfgOptFunc which will set the width at which
+// long values are wrapped when the snippets are listed. Lines are broken
+// between words and the continuation lines are indented to line up with the
+// start of the value. The snippet text is never wrapped. The value must not
+// be negative; the default of zero means that values are not wrapped.
+func SetWrapWidth(n int) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		if n < 0 {
+			return fmt.Errorf("the wrap width (%d) must not be negative", n)
+		}
+		lc.formatCfg.wrapWidth = n
+		return nil
+	}
+}
+
+// SetOutputFormat returns a ListCfgOptFunc which will set the form in which
+// the snippets are listed. The default is FormatText. With FormatJSON the
+// snippets are written as a JSON array, each snippet being an object with
+// the parts chosen (see SetParts and SetTags); any errors are still
+// recorded in the error map rather than being written. Note that the
+// maximum number of output lines is not applied to the JSON output as it
+// would no longer be valid. With FormatMarkdown the snippets are written as
+// a Markdown document with a section for each snippet directory.
+func SetOutputFormat(f OutputFormat) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		if !f.isValid() {
+			return fmt.Errorf("bad output format: %s", f)
+		}
+		lc.outputFormat = f
+		return nil
+	}
+}
+
 // HideIntro returns a ListCfgOptFunc which will set up the ListCfg value to
 // the given value. Setting it to true will suppress the printing of the
 // snippet part names before the values.
@@ -72,6 +386,237 @@ func HideIntro(val bool) ListCfgOptFunc {
 	}
 }
 
+// DenyImports returns a ListCfgOptFunc which will add the given import
+// paths to the list of denied imports. Any snippet importing a denied
+// package will be reported as an error. A path ending in "/..." will deny
+// the path itself and any package below it.
+func DenyImports(paths ...string) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.deniedImports = append(lc.deniedImports, paths...)
+		return nil
+	}
+}
+
+// SetWarnings returns a ListCfgOptFunc which will set the map where
+// warnings are recorded. Warnings are problems which are less serious than
+// those recorded as errors; by default they are recorded in a map of their
+// own which can be retrieved with the Warnings method.
+func SetWarnings(warns *errutil.ErrMap) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		if warns == nil {
+			return errors.New("the warnings map must not be nil")
+		}
+		lc.warns = warns
+		return nil
+	}
+}
+
+// CheckDocLinks returns a ListCfgOptFunc which will set the ListCfg to check
+// that snippets referred to in the notes of a snippet exist. See
+// docLinkRE for the (heuristic) rules for finding such references. A
+// reference to a snippet which does not exist is recorded as a warning.
+func CheckDocLinks(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.checkDocLinks = val
+		return nil
+	}
+}
+
+// SetOverrideOrder returns a ListCfgOptFunc which will set the precedence
+// of the snippet directories. By default a snippet in an earlier directory
+// eclipses any snippet of the same name in a later directory and the later
+// snippets are reported as errors. If later is true this is reversed: a
+// snippet in a later directory overrides any of the same name in an earlier
+// directory and the overridden snippets are only reported as warnings.
+// Note that the directories are then read, and so listed, starting with
+// the last.
+func SetOverrideOrder(later bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.overrideLater = later
+		return nil
+	}
+}
+
+// MergeEclipsedTags returns a ListCfgOptFunc which will set the ListCfg to
+// merge the tags of any eclipsed snippets into the snippet which eclipses
+// them. Where both snippets have the same tag the values from the
+// eclipsing snippet are kept. Eclipsed snippets are not reported as errors
+// when this is set.
+func MergeEclipsedTags(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.mergeEclipsedTags = val
+		return nil
+	}
+}
+
+// SetStrict returns a ListCfgOptFunc which will set the ListCfg to parse
+// snippets strictly. In strict mode any semantic comment which does not
+// start with one of the snippet parts (or one of its alternative names) is
+// reported as an error and the snippet is not shown. Otherwise such
+// comments are ignored and reported as warnings.
+func SetStrict(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.strict = val
+		return nil
+	}
+}
+
+const (
+	// SortByName is the sort order which sorts snippets by name
+	SortByName = "name"
+	// SortByTagPrefix, followed by a tag name, gives the sort order which
+	// sorts snippets by the value of the named tag
+	SortByTagPrefix = "tag:"
+)
+
+// SetSortBy returns a ListCfgOptFunc which will set the order in which the
+// snippets found in each directory are shown. By default they are shown in
+// the order they are found. The order can be either SortByName or
+// SortByTagPrefix followed by the name of a tag. When sorting by tag, the
+// snippets are sorted on the first value of the tag; numeric values are
+// sorted numerically and come before any non-numeric values, which are
+// sorted alphabetically, and snippets without the tag come last. Snippets
+// with the same tag value are sorted by name.
+func SetSortBy(order string) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		if order != SortByName &&
+			(!strings.HasPrefix(order, SortByTagPrefix) ||
+				order == SortByTagPrefix) {
+			return fmt.Errorf("bad sort order: %q, it should be %q or %q"+
+				" followed by a tag name",
+				order, SortByName, SortByTagPrefix)
+		}
+		lc.sortBy = order
+		return nil
+	}
+}
+
+// WarnEclipsedReferences returns a ListCfgOptFunc which will set the
+// ListCfg to report, as a warning, any snippet which is expected by another
+// snippet and which has another copy, in a later snippet directory, that
+// it eclipses. Such a reference may not resolve to the snippet the author
+// intended.
+func WarnEclipsedReferences(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.warnEclipsedRefs = val
+		return nil
+	}
+}
+
+// SetMaxOutputLines returns a ListCfgOptFunc which will set the maximum
+// number of lines of output to be written when listing snippets. If the
+// listing would be longer it is cut short and a message is written to say
+// that the output has been truncated. A value of zero means that there is
+// no limit.
+func SetMaxOutputLines(n int) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		if n < 0 {
+			return fmt.Errorf(
+				"the maximum number of output lines (%d) must not be negative",
+				n)
+		}
+		lc.maxOutputLines = n
+		return nil
+	}
+}
+
+// SetLimit returns a ListCfgOptFunc which will set the maximum number of
+// snippets to be shown when listing snippets. If more snippets are found
+// only the first n are shown, followed by a notice saying so. The limit is
+// applied after any constraints and after the snippets have been sorted.
+// All the snippets are still read and checked so that any errors are
+// reported. A value of zero means that there is no limit.
+func SetLimit(n int) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		if n < 0 {
+			return fmt.Errorf(
+				"the maximum number of snippets to show (%d)"+
+					" must not be negative",
+				n)
+		}
+		lc.limit = n
+		return nil
+	}
+}
+
+// WarnShadowedBuiltins returns a ListCfgOptFunc which will set the ListCfg
+// to report, as a warning, any snippet which declares a name which is the
+// same as one of Go's predeclared identifiers. See S.ShadowsBuiltins for
+// details.
+func WarnShadowedBuiltins(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.warnShadowedBuiltins = val
+		return nil
+	}
+}
+
+// SetCheckGo returns a ListCfgOptFunc which will set the ListCfg to report,
+// as an error, any snippet whose text cannot be parsed as Go code. See
+// S.CheckTextParses for details.
+func SetCheckGo(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.checkGo = val
+		return nil
+	}
+}
+
+// WarnUnformatted returns a ListCfgOptFunc which will set the ListCfg to
+// report, as a warning, any snippet whose text is not formatted as gofmt
+// would format it. Snippets whose text is not Go code are not reported. See
+// S.IsGofmtClean for details.
+func WarnUnformatted(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.warnUnformatted = val
+		return nil
+	}
+}
+
+// WarnSingletonTags returns a ListCfgOptFunc which will set the ListCfg to
+// report, as a warning, any tag name which is used by only one of the
+// snippets listed. Such a tag is often a mistyped version of a tag used
+// elsewhere. This check is not made if there are constraints or excludes
+// on the snippets to be listed.
+func WarnSingletonTags(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.warnSingletonTags = val
+		return nil
+	}
+}
+
+// CheckEncoding returns a ListCfgOptFunc which will set the ListCfg to
+// report any snippet file which starts with a byte order mark or which is
+// not valid UTF-8.
+func CheckEncoding(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.checkEncoding = val
+		return nil
+	}
+}
+
+// RequireUniqueBaseNames returns a ListCfgOptFunc which will set the
+// ListCfg to report any snippet file name which is used in more than one
+// sub-directory of a snippet directory. For instance, "net/client" and
+// "fs/client" would be reported as they share the name "client".
+func RequireUniqueBaseNames(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.requireUniqueBaseNames = val
+		return nil
+	}
+}
+
+// ConfineSnippetNames returns a ListCfgOptFunc which will set the ListCfg
+// to reject any snippet name which is not an absolute pathname and which,
+// once cleaned, would refer to a file outside the snippet directory, such
+// as "../../etc/passwd". Such snippets given as constraints are reported
+// as errors and such expected or followed snippets are taken not to
+// exist. By default these names are allowed.
+func ConfineSnippetNames(val bool) ListCfgOptFunc {
+	return func(lc *ListCfg) error {
+		lc.confineNames = val
+		return nil
+	}
+}
+
 // ListCfg holds the configuration for controlling the listing of snippets
 type ListCfg struct {
 	formatCfg
@@ -80,10 +625,52 @@ type ListCfg struct {
 	dirs []string
 	// errs is where to record any errors found while listing
 	errs *errutil.ErrMap
+	// warns is where to record any warnings found while listing
+	warns *errutil.ErrMap
 
 	// constraints (if non-empty) will constrain the snippets to show. If this
 	// is empty than all snippets will be shown.
 	constraints map[string]bool
+	// excludes (if non-empty) gives the snippets and directories which
+	// will not be shown
+	excludes map[string]bool
+
+	// deniedImports holds the import paths which snippets should not use.
+	deniedImports []string
+
+	// checkDocLinks controls whether references to snippets in the notes
+	// are checked.
+	checkDocLinks bool
+
+	// checkEncoding controls whether the encoding of snippet files is
+	// checked
+	checkEncoding bool
+
+	// requireUniqueBaseNames controls whether snippet file names used in
+	// more than one sub-directory are reported
+	requireUniqueBaseNames bool
+
+	// confineNames controls whether relative snippet names which refer to
+	// files outside the snippet directory are rejected
+	confineNames bool
+
+	// baseNames maps a snippet directory to a map of snippet file names
+	// to the names of the snippets having that file name.
+	baseNames map[string]map[string][]string
+
+	// warnShadowedBuiltins controls whether declarations of predeclared
+	// identifiers are reported
+	warnShadowedBuiltins bool
+	// checkGo controls whether snippets whose text is not valid Go code
+	// are reported
+	checkGo bool
+
+	// warnUnformatted controls whether snippets which are not gofmt-clean
+	// are reported
+	warnUnformatted bool
+	// warnSingletonTags controls whether tags used by only one snippet are
+	// reported
+	warnSingletonTags bool
 
 	// loc records where snippets are first declared. It is used to report
 	// snippets in one directory which cannot be used because they are hidden
@@ -96,34 +683,177 @@ type ListCfg struct {
 	// fatal error for there to be duplicate snippets as they can still be
 	// used but it is reported as an error to allow redundant snippets to be
 	// found.
-	contentHash map[[md5.Size]byte]string
+	contentHash map[string]string
+	// hashFunc returns the hash used to find duplicate snippets
+	hashFunc func() hash.Hash
+	// allowDuplicates controls whether duplicate snippets are reported
+	allowDuplicates bool
+	// dupNormalization gives the changes made to the content of a snippet
+	// file before it is hashed
+	dupNormalization DuplicateNormalization
 
 	// expectedBy maps the name of a snippet to the name of the snippet
 	// expecting it. It is used to report missing snippets which are expected
 	// by other snippets.
 	expectedBy map[string][]string
 
-	// intro is the string to be printed before the first snippet. It will be
-	// the name of the current snippet directory and then cleared by
-	// printIntroOnce so as to ensure we only print this intro for
-	// directories having some snippets in them.
-	intro string
+	// seeAlsoBy maps the name of a snippet to the names of the snippets
+	// referring to it as a related snippet. It is used to report related
+	// snippets which do not exist.
+	seeAlsoBy map[string][]string
+
+	// docLinksBy maps the name of a snippet to the names of the snippets
+	// referring to it in their notes. It is used to report references in
+	// the notes to snippets which do not exist.
+	docLinksBy map[string][]string
+
+	// strict controls whether semantic comments which do not start with a
+	// known snippet part are reported as errors rather than warnings
+	strict bool
+
+	// ignorePatterns holds the patterns of the names of directory entries
+	// which are not read
+	ignorePatterns []string
+	// ignoreRules maps a directory to the rules read from the ignore file
+	// in that directory
+	ignoreRules map[string][]ignoreRule
+
+	// overrideLater controls whether snippets in later directories take
+	// precedence over those in earlier ones
+	overrideLater bool
+
+	// mergeEclipsedTags controls whether the tags of an eclipsed snippet
+	// are merged into the snippet eclipsing it rather than the eclipsed
+	// snippet being reported as an error.
+	mergeEclipsedTags bool
+
+	// warnEclipsedRefs controls whether references to eclipsed snippets are
+	// reported
+	warnEclipsedRefs bool
+
+	// eclipsedIn maps the name of a snippet to the directories where it is
+	// eclipsed by a snippet of the same name in an earlier directory.
+	eclipsedIn map[string][]string
+
+	// sortBy gives the order in which to show the snippets in each group.
+	// If it is empty the snippets are shown in the order they are found.
+	sortBy string
+
+	// maxOutputLines is the maximum number of lines of output to write. If
+	// it is zero there is no limit.
+	maxOutputLines int
+	// linesWritten counts the lines of output written
+	linesWritten int
+
+	// ctx is the context of the current listing
+	ctx context.Context
+	// cancelRecorded records whether the cancellation of the listing has
+	// been reported
+	cancelRecorded bool
+
+	// fsys is the file system to read the snippets from. If it is nil
+	// the operating system's file system is used
+	fsys fs.FS
+
+	// registry is used to resolve aliases given as constraints
+	registry Registry
+	// givenConstraints records the constraints as given, before any
+	// aliases were resolved, so that they are resolved afresh each time
+	// the snippets are listed
+	givenConstraints map[string]bool
+
+	// requireDirsExist controls whether missing snippet directories are
+	// reported
+	requireDirsExist bool
+
+	// annotateReferences controls whether the expected and followed
+	// snippets are marked with whether or not they exist
+	annotateReferences bool
+
+	// tagValueFilter, if non-empty, maps tag names to the values that a
+	// snippet must have for that tag to be shown
+	tagValueFilter map[string][]string
+
+	// hideDeprecated controls whether deprecated snippets are shown
+	hideDeprecated bool
+
+	// textMatch, if not nil, must match a line of the text of a snippet
+	// for it to be shown
+	textMatch *regexp.Regexp
+
+	// outputFormat gives the form in which the snippets are listed
+	outputFormat OutputFormat
+
+	// trimTrailingBlankLines controls whether blank lines at the end of
+	// the snippet text are removed
+	trimTrailingBlankLines bool
+
+	// statusFilter, if non-empty, gives the statuses of the snippets to
+	// show
+	statusFilter map[string]bool
+
+	// limit is the maximum number of snippets to show. If it is zero there
+	// is no limit.
+	limit int
+
+	// watchInterval is the time between the checks made by Watch
+	watchInterval time.Duration
+
+	// pending holds the snippet files found while reading the snippet
+	// directories. They are read and parsed once all the directories have
+	// been read.
+	pending []*snippetFile
+	// parallelism is the number of snippet files to read and parse at the
+	// same time
+	parallelism int
+
+	// groups holds the snippets to be shown, grouped by the directory
+	// listing in which they were found. The snippets are gathered while
+	// the directories are read and shown once they have all been read.
+	groups []snippetGroup
+
+	// shown maps the name of a snippet to the snippet to be shown. It is
+	// used to find the snippet eclipsing another.
+	shown map[string]*S
+}
+
+// snippetGroup records the snippets found while listing a directory.
+type snippetGroup struct {
+	dir      string
+	snippets []*S
 }
 
 // NewListCfg returns a new ListCfg holding the configuration for snippet
-// listing.
+// listing. Any environment variables in the snippet directory names, and
+// a leading "~" meaning the home directory, are expanded once, here;
+// absolute and already expanded names are used unchanged. Note that only
+// the directory names are expanded, not any snippet names.
 func NewListCfg(w io.Writer, dirs []string,
 	errs *errutil.ErrMap, opts ...ListCfgOptFunc,
 ) (*ListCfg, error) {
 	lc := &ListCfg{
 		Writers:     pager.W(),
-		dirs:        dirs,
+		dirs:        expandDirs(dirs),
 		errs:        errs,
+		warns:       errutil.NewErrMap(),
 		constraints: map[string]bool{},
+		excludes:    map[string]bool{},
+
+		ignorePatterns: copySlice(dfltIgnorePatterns),
+
+		statusFilter:   map[string]bool{},
+		tagValueFilter: map[string][]string{},
 
 		loc:         map[string]string{},
-		contentHash: map[[md5.Size]byte]string{},
+		eclipsedIn:  map[string][]string{},
+		baseNames:   map[string]map[string][]string{},
+		contentHash: map[string]string{},
+		hashFunc:    md5.New,
+		parallelism: 1,
 		expectedBy:  map[string][]string{},
+		seeAlsoBy:   map[string][]string{},
+		docLinksBy:  map[string][]string{},
+		shown:       map[string]*S{},
 	}
 	lc.SetStdW(w)
 	lc.SetErrW(w)
@@ -138,86 +868,428 @@ func NewListCfg(w io.Writer, dirs []string,
 		}
 	}
 
-	return lc, nil
+	return lc, nil
+}
+
+// Warnings returns the map where warnings found while listing are recorded.
+func (lc *ListCfg) Warnings() *errutil.ErrMap {
+	return lc.warns
+}
+
+// tidy will clear out any map entries set to false and will reset the
+// state recorded while listing so that the snippets can be listed again
+func (lc *ListCfg) tidy() {
+	for k, v := range lc.constraints {
+		if !v {
+			delete(lc.constraints, k)
+		}
+	}
+	for k, v := range lc.parts {
+		if !v {
+			delete(lc.parts, k)
+		}
+	}
+	for k, v := range lc.tags {
+		if !v {
+			delete(lc.tags, k)
+		}
+	}
+	lc.loc = map[string]string{}
+	lc.eclipsedIn = map[string][]string{}
+	lc.ignoreRules = map[string][]ignoreRule{}
+	lc.baseNames = map[string]map[string][]string{}
+	lc.contentHash = map[string]string{}
+	lc.expectedBy = map[string][]string{}
+	lc.seeAlsoBy = map[string][]string{}
+	lc.docLinksBy = map[string][]string{}
+	lc.linesWritten = 0
+	lc.cancelRecorded = false
+	lc.groups = nil
+	lc.pending = nil
+	lc.shown = map[string]*S{}
+}
+
+// listDir reads the given directory and reports on any snippets it find
+// subject to any constraints given by the ListCfg.
+func (lc *ListCfg) listDir(dir string, ck constraintCk) {
+	dirEntries, err := readDir(lc.fsys, dir)
+	if err != nil {
+		if info, statErr := statFile(lc.fsys, dir); statErr == nil &&
+			!info.IsDir() {
+			err = fmt.Errorf("snippet directory %q is not a directory", dir)
+		}
+		if !os.IsNotExist(err) || lc.requireDirsExist {
+			lc.errs.AddError(
+				fmt.Sprintf("Bad snippets directory: %q", dir),
+				err)
+		}
+		return
+	}
+
+	lc.loadIgnoreFile(dir)
+	lc.startGroup(dir)
+	for _, de := range dirEntries {
+		if lc.cancelled() {
+			return
+		}
+		lc.display(dir, "", de, ck)
+	}
+}
+
+// List reads the given snippet directories (or specified files and
+// directories) and reports them recording errors as it goes.
+func (lc *ListCfg) List() {
+	lc.ListContext(context.Background())
+}
+
+// ListContext behaves as List but stops reading the snippet directories if
+// the context is cancelled. In that case an error is recorded and nothing
+// is shown.
+func (lc *ListCfg) ListContext(ctx context.Context) {
+	if _, err := lc.CollectContext(ctx); err != nil {
+		return
+	}
+
+	lc.formatCfg.annotateRef = nil
+	if lc.annotateReferences {
+		lc.formatCfg.annotateRef = lc.annotateRef
+	}
+
+	pgr := pager.Start(lc)
+	switch lc.outputFormat {
+	case FormatJSON:
+		lc.showJSON()
+	case FormatMarkdown:
+		lc.showMarkdown()
+	default:
+		lc.showGroups()
+	}
+	pgr.Done()
+}
+
+// Collect reads the given snippet directories (or specified files and
+// directories) as for List, making the same checks and recording any
+// problems in the ErrMap, but rather than writing the snippets it returns
+// them. The snippets are given in the order in which they would be listed
+// and no more than the limit (see SetLimit) are returned. The parts and
+// tags chosen to be shown and the output format are ignored.
+func (lc *ListCfg) Collect() ([]*S, error) {
+	return lc.CollectContext(context.Background())
+}
+
+// CollectContext behaves as Collect but stops reading the snippet
+// directories if the context is cancelled. In that case an error is
+// recorded, no snippets are returned and the context's error is returned.
+func (lc *ListCfg) CollectContext(ctx context.Context) ([]*S, error) {
+	lc.tidy()
+	lc.ctx = ctx
+	lc.resolveAliases()
+
+	if !lc.readSnippets() {
+		return nil, ctx.Err()
+	}
+
+	lc.checkExpectedSnippetsExist()
+	lc.checkSeeAlsoSnippetsExist()
+	lc.checkDocLinkSnippetsExist()
+	lc.checkEclipsedReferences()
+	lc.checkUniqueBaseNames()
+	lc.checkSingletonTags()
+
+	lc.sortGroups()
+
+	snippets := []*S{}
+	for _, g := range lc.groups {
+		for _, s := range g.snippets {
+			if lc.limit > 0 && len(snippets) == lc.limit {
+				return snippets, nil
+			}
+			snippets = append(snippets, s)
+		}
+	}
+	return snippets, nil
+}
+
+// readSnippets reads the snippet directories (or specified files and
+// directories), parses the snippets found and adds them to the groups of
+// snippets to be shown. It returns false if the listing was cancelled.
+func (lc *ListCfg) readSnippets() bool {
+	if !lc.findSnippets() {
+		return false
+	}
+
+	lc.loadSnippets()
+	for _, sf := range lc.pending {
+		if lc.cancelled() {
+			return false
+		}
+		lc.displaySnippet(sf)
+	}
+	lc.pending = nil
+
+	return true
+}
+
+// findSnippets reads the snippet directories (or specified files and
+// directories) and records the snippet files found, in the order that they
+// are found, as the pending snippet files. The files are not read. It
+// returns false if the listing was cancelled.
+func (lc *ListCfg) findSnippets() bool {
+	lc.startGroup("")
+	for sName := range lc.constraints {
+		if lc.cancelled() {
+			return false
+		}
+		if lc.confineNames {
+			if err := checkNameIsConfined(sName); err != nil {
+				lc.errs.AddError("Bad specific snippet", err)
+				continue
+			}
+		}
+		if filepath.IsAbs(sName) {
+			if lc.isExcluded(sName) {
+				continue
+			}
+			f, err := statFile(lc.fsys, sName)
+			if err != nil {
+				lc.errs.AddError("Bad specific snippet",
+					fmt.Errorf("snippet %q: %w", sName, err))
+				continue
+			}
+
+			if f.IsDir() {
+				lc.listDir(sName, dontCheckConstraints)
+			} else {
+				lc.queueSnippet("", sName, sName)
+			}
+		}
+	}
+
+	for i := range lc.dirs {
+		dir := lc.dirs[i]
+		if lc.overrideLater {
+			dir = lc.dirs[len(lc.dirs)-1-i]
+		}
+		lc.listDir(dir, checkConstraints)
+	}
+
+	return !lc.cancelled()
+}
+
+// findSnippetFiles finds the snippet files in the snippet directories
+// just as they would be found when listing them with the given options. It
+// returns the ListCfg, with the snippet files found as its pending files
+// in the order in which they were found, together with any problems
+// found. Note that eclipsed snippet files are included.
+func findSnippetFiles(dirs []string, opts ...ListCfgOptFunc,
+) (*ListCfg, []error) {
+	em := errutil.NewErrMap()
+	lc, err := NewListCfg(io.Discard, dirs, em, opts...)
+	if err != nil {
+		return nil, []error{err}
+	}
+	lc.tidy()
+	lc.ctx = context.Background()
+	lc.findSnippets()
+
+	errs := []error{}
+	for _, k := range em.Keys() {
+		errs = append(errs, (*em)[k]...)
+	}
+	return lc, errs
 }
 
-// tidy will clear out any map entries set to false and will clear the loc
-// map
-func (lc *ListCfg) tidy() {
-	for k, v := range lc.constraints {
-		if !v {
-			delete(lc.constraints, k)
-		}
+// cancelled returns true if the listing context has been cancelled. The
+// first time that it finds this it records an error.
+func (lc *ListCfg) cancelled() bool {
+	err := lc.ctx.Err()
+	if err == nil {
+		return false
 	}
-	for k, v := range lc.parts {
-		if !v {
-			delete(lc.parts, k)
-		}
+	if !lc.cancelRecorded {
+		lc.errs.AddError("Listing cancelled", err)
+		lc.cancelRecorded = true
 	}
-	for k, v := range lc.tags {
-		if !v {
-			delete(lc.tags, k)
+	return true
+}
+
+// resolveAliases replaces any constraint which is an alias in the registry
+// with the name of the snippet it refers to, recording an error if that
+// snippet does not exist. Aliases are resolved by one level only, as they
+// are by Registry.Resolve.
+func (lc *ListCfg) resolveAliases() {
+	if lc.givenConstraints == nil {
+		lc.givenConstraints = lc.constraints
+	}
+	constraints := make(map[string]bool, len(lc.givenConstraints))
+	for k := range lc.givenConstraints {
+		if !lc.registry.IsAlias(k) {
+			constraints[k] = true
+			continue
+		}
+
+		sName := lc.registry.Resolve(k)
+		if !filepath.IsAbs(sName) && !lc.snippetExists(sName) {
+			lc.errs.AddError("Bad snippet alias",
+				fmt.Errorf("%q refers to %q which does not exist", k, sName))
+			continue
 		}
+		constraints[sName] = true
 	}
-	lc.loc = map[string]string{}
+	lc.constraints = constraints
 }
 
-// listDir reads the given directory and reports on any snippets it find
-// subject to any constraints given by the ListCfg.
-func (lc *ListCfg) listDir(dir string, ck constraintCk) {
-	dirEntries, err := os.ReadDir(dir)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			lc.errs.AddError(
-				fmt.Sprintf("Bad snippets directory: %q", dir),
-				err)
-		}
+// startGroup starts a new group of snippets to be shown. The directory is
+// used to introduce the snippets in the group; no introduction is shown if
+// it is empty.
+func (lc *ListCfg) startGroup(dir string) {
+	lc.groups = append(lc.groups, snippetGroup{dir: dir})
+}
+
+// addToGroup adds the snippet to the given group of snippets to be shown
+func (lc *ListCfg) addToGroup(group int, s *S) {
+	g := &lc.groups[group]
+	g.snippets = append(g.snippets, s)
+	lc.shown[s.name] = s
+}
+
+// sortGroups sorts the snippets in each group according to the sortBy
+// value.
+func (lc *ListCfg) sortGroups() {
+	if lc.sortBy == "" {
 		return
 	}
 
-	if !lc.hideIntro {
-		lc.intro = "in: " + dir + "\n"
+	for _, g := range lc.groups {
+		snippets := g.snippets
+		sort.SliceStable(snippets, func(i, j int) bool {
+			if lc.sortBy == SortByName {
+				return snippets[i].name < snippets[j].name
+			}
+			tag := strings.TrimPrefix(lc.sortBy, SortByTagPrefix)
+			return tagValueLess(snippets[i], snippets[j], tag)
+		})
 	}
-	for _, de := range dirEntries {
-		lc.display(dir, "", de, ck)
+}
+
+// tagValueLess returns true if snippet a should come before snippet b when
+// they are sorted by the value of the named tag. See SetSortBy for details
+// of the ordering.
+func tagValueLess(a, b *S, tag string) bool {
+	aRank, aNum, aStr := tagSortKey(a, tag)
+	bRank, bNum, bStr := tagSortKey(b, tag)
+
+	if aRank != bRank {
+		return aRank < bRank
 	}
+	if aNum != bNum {
+		return aNum < bNum
+	}
+	if aStr != bStr {
+		return aStr < bStr
+	}
+	return a.name < b.name
 }
 
-// List reads the given snippet directories (or specified files and
-// directories) and reports them recording errors as it goes.
-func (lc *ListCfg) List() {
-	lc.tidy()
+// tagSortKey returns the values used to sort the snippet by the named tag:
+// a rank (0 for numeric values, 1 for non-numeric and 2 for a missing
+// tag), the numeric value and the string value. Values such as NaN or Inf
+// are not treated as numeric as they cannot be sensibly ordered.
+func tagSortKey(s *S, tag string) (int, float64, string) {
+	vals, ok := s.tags[tag]
+	if !ok || len(vals) == 0 {
+		return 2, 0, ""
+	}
+	if f, err := strconv.ParseFloat(vals[0], 64); err == nil &&
+		!math.IsNaN(f) && !math.IsInf(f, 0) {
+		return 0, f, ""
+	}
+	return 1, 0, vals[0]
+}
 
-	pgr := pager.Start(lc)
-	for sName := range lc.constraints {
-		if filepath.IsAbs(sName) {
-			f, err := os.Stat(sName)
-			if err != nil {
-				lc.errs.AddError("Bad specific snippet",
-					fmt.Errorf("snippet %q: %w", sName, err))
-				continue
+// showGroups prints the snippets in each group, introducing each non-empty
+// group with the directory it was found in.
+func (lc *ListCfg) showGroups() {
+	count := 0
+	for _, g := range lc.groups {
+		for i, s := range g.snippets {
+			if lc.limit > 0 && count == lc.limit {
+				lc.writeLines(
+					fmt.Sprintf("(showing first %d matches)\n", lc.limit))
+				return
 			}
+			count++
 
-			if f.IsDir() {
-				lc.listDir(sName, dontCheckConstraints)
-			} else {
-				lc.displaySnippet("", sName, sName)
+			text := lc.formatCfg.snippetToString(s)
+			if i == 0 && g.dir != "" && !lc.hideIntro {
+				text = "in: " + g.dir + "\n" + text
+			}
+			if !lc.writeLines(text) {
+				fmt.Fprint(lc.StdW(), "... (output truncated)\n")
+				return
 			}
 		}
 	}
+}
+
+// annotateRef returns the name of the referenced snippet followed by a
+// mark showing whether or not it exists.
+func (lc *ListCfg) annotateRef(sName string) string {
+	if lc.snippetExists(sName) {
+		return sName + " ✓"
+	}
+	return sName + " ✗"
+}
 
+// snippetExists returns true if the named snippet was found while listing
+// the snippets or, as not all the snippets are read if there are
+// constraints or excludes, if it is in one of the snippet directories.
+func (lc *ListCfg) snippetExists(sName string) bool {
+	if _, ok := lc.loc[sName]; ok {
+		return true
+	}
+	if lc.confineNames && checkNameIsConfined(sName) != nil {
+		return false
+	}
 	for _, dir := range lc.dirs {
-		lc.listDir(dir, checkConstraints)
+		fName := filepath.Join(dir, sName)
+		for _, f := range []string{fName, fName + GzipSuffix} {
+			info, err := statFile(lc.fsys, f)
+			if err == nil && info.Mode().IsRegular() {
+				return true
+			}
+		}
 	}
+	return false
+}
 
-	lc.checkExpectedSnippetsExist()
-	pgr.Done()
+// writeLines writes the text to the standard writer, counting the lines
+// written. If there is a limit on the number of lines to write then only
+// as many lines as the limit allows will be written. It returns false if
+// any lines were not written.
+func (lc *ListCfg) writeLines(text string) bool {
+	if lc.maxOutputLines == 0 {
+		fmt.Fprint(lc.StdW(), text)
+		return true
+	}
+
+	for _, l := range strings.SplitAfter(text, "\n") {
+		if l == "" {
+			continue
+		}
+		if lc.linesWritten >= lc.maxOutputLines {
+			return false
+		}
+		fmt.Fprint(lc.StdW(), l)
+		lc.linesWritten++
+	}
+	return true
 }
 
 // checkExpectedSnippetsExist checks that all the snippets which are expected
 // by some snippet are defined somewhere.
 func (lc *ListCfg) checkExpectedSnippetsExist() {
-	if len(lc.constraints) > 0 {
+	if lc.partialListing() {
 		return
 	}
 
@@ -235,15 +1307,165 @@ func (lc *ListCfg) checkExpectedSnippetsExist() {
 	}
 }
 
+// checkSeeAlsoSnippetsExist checks that all the snippets which are given as
+// related snippets are defined somewhere. Any which are not are recorded as
+// warnings rather than errors as they are not needed by the referring
+// snippet.
+func (lc *ListCfg) checkSeeAlsoSnippetsExist() {
+	if lc.partialListing() {
+		return
+	}
+
+	var keys []string
+	for k := range lc.seeAlsoBy {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, ok := lc.loc[k]; !ok {
+			lc.warns.AddError("Missing related snippet",
+				fmt.Errorf("snippet %q does not exist but is 'seealso' by %q",
+					k, strings.Join(lc.seeAlsoBy[k], ", ")))
+		}
+	}
+}
+
+// checkDocLinkSnippetsExist checks that all the snippets which are
+// referred to in the notes of some snippet are defined somewhere. Any which
+// are not are recorded as warnings since the references are found
+// heuristically.
+func (lc *ListCfg) checkDocLinkSnippetsExist() {
+	if lc.partialListing() {
+		return
+	}
+
+	var keys []string
+	for k := range lc.docLinksBy {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, ok := lc.loc[k]; !ok {
+			lc.warns.AddError("Missing snippet in notes",
+				fmt.Errorf("snippet %q does not exist but is noted by %q",
+					k, strings.Join(lc.docLinksBy[k], ", ")))
+		}
+	}
+}
+
+// checkEclipsedReferences checks that none of the snippets which are
+// expected by some snippet are eclipsed. Any that are are recorded as
+// warnings giving the directory the snippet is taken from and those where
+// it is eclipsed.
+func (lc *ListCfg) checkEclipsedReferences() {
+	if !lc.warnEclipsedRefs || lc.partialListing() {
+		return
+	}
+
+	var ebKeys []string
+	for k := range lc.expectedBy {
+		ebKeys = append(ebKeys, k)
+	}
+	sort.Strings(ebKeys)
+	for _, k := range ebKeys {
+		if dirs, ok := lc.eclipsedIn[k]; ok {
+			lc.warns.AddError("Reference to eclipsed snippet",
+				fmt.Errorf("snippet %q, 'expected' by %q, is in %q"+
+					" but is eclipsed in \"%s\"",
+					k, strings.Join(lc.expectedBy[k], ", "),
+					lc.loc[k], strings.Join(dirs, `", "`)))
+		}
+	}
+}
+
+// recordBaseName records the name of the snippet against its file name in
+// the snippet directory.
+func (lc *ListCfg) recordBaseName(dir, sName string) {
+	if !lc.requireUniqueBaseNames || dir == "" {
+		return
+	}
+	names, ok := lc.baseNames[dir]
+	if !ok {
+		names = map[string][]string{}
+		lc.baseNames[dir] = names
+	}
+	base := filepath.Base(sName)
+	names[base] = append(names[base], sName)
+}
+
+// checkUniqueBaseNames records an error for each snippet file name which
+// is used in more than one sub-directory of a snippet directory.
+func (lc *ListCfg) checkUniqueBaseNames() {
+	var dirs []string
+	for dir := range lc.baseNames {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		names := lc.baseNames[dir]
+		var bases []string
+		for base := range names {
+			bases = append(bases, base)
+		}
+		sort.Strings(bases)
+
+		for _, base := range bases {
+			if len(names[base]) > 1 {
+				lc.errs.AddError("Duplicate snippet file name",
+					fmt.Errorf("%q in %q is used by: %s",
+						base, dir, strings.Join(names[base], ", ")))
+			}
+		}
+	}
+}
+
+// checkSingletonTags records a warning for each tag name which is used by
+// only one of the snippets to be shown.
+func (lc *ListCfg) checkSingletonTags() {
+	if !lc.warnSingletonTags || lc.partialListing() {
+		return
+	}
+
+	usedBy := map[string][]string{}
+	for _, g := range lc.groups {
+		for _, s := range g.snippets {
+			for tag := range s.tags {
+				usedBy[tag] = append(usedBy[tag], s.name)
+			}
+		}
+	}
+
+	var tags []string
+	for tag, names := range usedBy {
+		if len(names) == 1 {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		lc.warns.AddError("Singleton tag",
+			fmt.Errorf("tag %q is only used by %q", tag, usedBy[tag][0]))
+	}
+}
+
 // snippetIsEclipsed records the location that the snippet is found. It records
 // an error and returns it if the snippet is already in the snipLoc
 func (lc *ListCfg) snippetIsEclipsed(sName, dir string) bool {
 	otherSD, eclipsed := (lc.loc)[sName]
 
 	if eclipsed && otherSD != dir {
-		lc.errs.AddError("Eclipsed snippet",
-			fmt.Errorf("%q in %q is eclipsed by the entry in %q",
-				sName, dir, otherSD))
+		lc.eclipsedIn[sName] = append(lc.eclipsedIn[sName], dir)
+		if lc.overrideLater && !lc.mergeEclipsedTags {
+			lc.warns.AddError("Overridden snippet",
+				fmt.Errorf("%q in %q is overridden by the entry in %q",
+					sName, dir, otherSD))
+		} else if !lc.mergeEclipsedTags {
+			lc.errs.AddError("Eclipsed snippet",
+				fmt.Errorf("%q in %q is eclipsed by the entry in %q",
+					sName, dir, otherSD))
+		}
 		return true
 	}
 	(lc.loc)[sName] = dir
@@ -251,15 +1473,36 @@ func (lc *ListCfg) snippetIsEclipsed(sName, dir string) bool {
 	return false
 }
 
+// ContentHash returns the hash of the content of a snippet file as a string
+// of hexadecimal digits. This is the hash used, by default, when listing
+// snippets to find snippets having the same content and it is the same as
+// the value given by the ContentHash method of a snippet parsed from the
+// content. It can be used to tell if a snippet file has changed.
+func ContentHash(content []byte) string {
+	return hashContent(md5.New, content)
+}
+
+// hashContent returns the hash of the content, as calculated by a hash
+// from the hash func, as a string of hexadecimal digits.
+func hashContent(hashFunc func() hash.Hash, content []byte) string {
+	h := hashFunc()
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // recordSnippetContentHash records all the snippets having the same
 // content. These could be simple aliases or else redundant copies. They will
 // be recorded as errors though the duplicate snippets are still reported and
 // can be used.
 func (lc *ListCfg) recordSnippetContentHash(content []byte, fName string) {
-	hash := md5.Sum(content)
+	hash := hashContent(lc.hashFunc,
+		lc.dupNormalization.normalize(content))
 	otherFile, isDup := (lc.contentHash)[hash]
 
 	if isDup {
+		if lc.allowDuplicates {
+			return
+		}
 		lc.errs.AddError("Duplicate snippet",
 			fmt.Errorf("snippet %q is a duplicate of %q", fName, otherFile))
 		return
@@ -268,13 +1511,103 @@ func (lc *ListCfg) recordSnippetContentHash(content []byte, fName string) {
 	(lc.contentHash)[hash] = fName
 }
 
-// recordExpectedBy cross references all the snippets expected by a snippet
-// back to the snippet that expects them. The full set of expected snippets
-// is checked for existence once all the snippets have been read.
+// recordExpectedBy cross references all the snippets expected (or given as
+// related) by a snippet back to the snippet that refers to them. The full
+// set of referenced snippets is checked for existence once all the snippets
+// have been read.
 func (lc *ListCfg) recordExpectedBy(s *S, sName string) {
 	for _, exp := range s.expects {
 		lc.expectedBy[exp] = append(lc.expectedBy[exp], sName)
 	}
+	for _, sa := range s.seeAlso {
+		lc.seeAlsoBy[sa] = append(lc.seeAlsoBy[sa], sName)
+	}
+	if lc.checkDocLinks {
+		for _, dl := range s.docLinks() {
+			lc.docLinksBy[dl] = append(lc.docLinksBy[dl], sName)
+		}
+	}
+}
+
+// checkDeniedImports records an error for each import of the snippet which
+// is on the list of denied imports.
+func (lc *ListCfg) checkDeniedImports(s *S) {
+	for _, imp := range s.imports {
+		denied := importIsDenied(importPath(imp), lc.deniedImports)
+		if denied != "" {
+			lc.errs.AddError("Denied import",
+				fmt.Errorf("snippet %q imports %q (denied by %q)",
+					s.name, imp, denied))
+		}
+	}
+}
+
+// checkContentEncoding records an error if the snippet content starts with
+// a byte order mark or is not valid UTF-8.
+func (lc *ListCfg) checkContentEncoding(content []byte, sName string) {
+	if !lc.checkEncoding {
+		return
+	}
+	if bytes.HasPrefix(content, utf8BOM) {
+		lc.errs.AddError("Bad encoding",
+			fmt.Errorf("snippet %q starts with a byte order mark", sName))
+	}
+	if !utf8.Valid(content) {
+		lc.errs.AddError("Bad encoding",
+			fmt.Errorf("snippet %q is not valid UTF-8", sName))
+	}
+}
+
+// checkShadowedBuiltins records a warning if the snippet declares any
+// predeclared identifiers.
+func (lc *ListCfg) checkShadowedBuiltins(s *S) {
+	if !lc.warnShadowedBuiltins {
+		return
+	}
+	if names := s.ShadowsBuiltins(); len(names) > 0 {
+		lc.warns.AddError("Shadowed builtin",
+			fmt.Errorf("snippet %q declares: %s",
+				s.name, strings.Join(names, ", ")))
+	}
+}
+
+// checkTextParses records an error if the snippet text cannot be parsed as
+// Go code.
+func (lc *ListCfg) checkTextParses(s *S) {
+	if !lc.checkGo {
+		return
+	}
+	if err := s.CheckTextParses(); err != nil {
+		lc.errs.AddError("Invalid Go code",
+			fmt.Errorf("snippet %q (%s): %w", s.name, s.path, err))
+	}
+}
+
+// checkGofmtClean records a warning if the snippet text is Go code which
+// is not gofmt-clean.
+func (lc *ListCfg) checkGofmtClean(s *S) {
+	if !lc.warnUnformatted {
+		return
+	}
+	if clean, _, err := s.IsGofmtClean(); err == nil && !clean {
+		lc.warns.AddError("Unformatted snippet",
+			fmt.Errorf("snippet %q is not formatted as gofmt would", s.name))
+	}
+}
+
+// importIsDenied returns the entry in the denied list which matches the
+// import path or the empty string if there is no match.
+func importIsDenied(path string, denied []string) string {
+	for _, d := range denied {
+		if prefix := strings.TrimSuffix(d, "/..."); prefix != d {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return d
+			}
+		} else if path == d {
+			return d
+		}
+	}
+	return ""
 }
 
 // List will read all of the snippet directories and show the
@@ -284,51 +1617,187 @@ func List(w io.Writer, dirs []string, errs *errutil.ErrMap) {
 	lc.List()
 }
 
-// displaySnippet reads the named snippet, records its location, parses it
-// and prints it. Any errors detected are recorded and the snippet will not
-// be displayed.
-func (lc *ListCfg) displaySnippet(dir, fName, sName string) {
-	content, err := os.ReadFile(fName)
-	if err != nil {
+// snippetFile records a snippet file found while reading the snippet
+// directories together with the results of reading and parsing it.
+type snippetFile struct {
+	// group is the index of the group of snippets the snippet belongs to
+	group int
+	dir   string
+	fName string
+	sName string
+
+	content  []byte
+	readErr  error
+	s        *S
+	parseErr error
+}
+
+// queueSnippet records the snippet file to be read, parsed and shown once
+// all the snippet directories have been read.
+func (lc *ListCfg) queueSnippet(dir, fName, sName string) {
+	lc.pending = append(lc.pending, &snippetFile{
+		group: len(lc.groups) - 1,
+		dir:   dir,
+		fName: fName,
+		sName: sName,
+	})
+}
+
+// loadSnippets reads and parses all the snippet files found. The files are
+// shared out between as many goroutines as the parallelism allows.
+func (lc *ListCfg) loadSnippets() {
+	work := make(chan *snippetFile)
+	var wg sync.WaitGroup
+	for w := 0; w < lc.parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sf := range work {
+				sf.content, sf.readErr = readSnippetContent(lc.fsys, sf.fName)
+				if sf.readErr == nil {
+					sf.s, sf.parseErr = parseSnippet(
+						sf.content, sf.fName, sf.sName)
+				}
+			}
+		}()
+	}
+
+	for _, sf := range lc.pending {
+		if lc.ctx.Err() != nil {
+			break
+		}
+		work <- sf
+	}
+	close(work)
+	wg.Wait()
+}
+
+// displaySnippet records the location of the snippet file, checks it and
+// adds it to the snippets to be shown. Any errors detected are recorded and
+// the snippet will not be displayed. The snippet files are displayed in the
+// order that they were found so that the results do not depend on the
+// order in which they were read.
+func (lc *ListCfg) displaySnippet(sf *snippetFile) {
+	dir, fName, sName := sf.dir, sf.fName, sf.sName
+	lc.recordBaseName(dir, sName)
+
+	if sf.readErr != nil {
 		lc.errs.AddError(
 			"Bad snippet",
-			fmt.Errorf("snippet %q: %w", sName, err))
+			fmt.Errorf("snippet %q: %w", sName, sf.readErr))
 		return
 	}
 
 	if lc.snippetIsEclipsed(sName, dir) {
+		if lc.mergeEclipsedTags {
+			lc.mergeTagsFromEclipsed(sf.content, fName, sName)
+		}
 		return
 	}
-	lc.recordSnippetContentHash(content, fName)
+	lc.recordSnippetContentHash(sf.content, fName)
+	lc.checkContentEncoding(sf.content, sName)
 
-	s, err := parseSnippet(content, fName, sName)
-	if err != nil {
-		lc.errs.AddError("Bad snippet", err)
+	if sf.parseErr != nil {
+		lc.errs.AddError("Bad snippet", sf.parseErr)
+		return
+	}
+	s := sf.s
+	s.dir = dir
+	if s.dir == "" {
+		s.dir = filepath.Dir(fName)
+	}
+	s.modTime = fileModTime(lc.fsys, fName)
+	if lc.trimTrailingBlankLines {
+		s.trimTrailingBlankLines()
+	}
+
+	if lc.strict && len(s.parseWarnings) > 0 {
+		for _, w := range s.parseWarnings {
+			lc.errs.AddError("Unknown snippet comment", w)
+		}
 		return
 	}
+	for _, w := range s.parseWarnings {
+		lc.warns.AddError("Unknown snippet comment", w)
+	}
 
 	lc.recordExpectedBy(s, sName)
+	lc.checkDeniedImports(s)
+	lc.checkShadowedBuiltins(s)
+	lc.checkTextParses(s)
+	lc.checkGofmtClean(s)
 
-	text := lc.formatCfg.snippetToString(s)
-	if text != "" {
-		lc.printIntroOnce()
-		fmt.Fprint(lc.StdW(), text)
+	if len(lc.statusFilter) > 0 && !lc.statusFilter[s.status] {
+		return
+	}
+	if len(lc.tagValueFilter) > 0 && !lc.hasTagValue(s) {
+		return
+	}
+	if isDeprecated, _ := s.Deprecated(); isDeprecated && lc.hideDeprecated {
+		return
+	}
+	if lc.textMatch != nil && !lc.hasTextMatch(s) {
+		return
+	}
+	lc.addToGroup(sf.group, s)
+}
+
+// hasTagValue returns true if the snippet has any of the tag values in the
+// tag value filter.
+func (lc *ListCfg) hasTagValue(s *S) bool {
+	for tag, vals := range lc.tagValueFilter {
+		for _, v := range s.tags[tag] {
+			if containsString(vals, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasTextMatch returns true if any line of the snippet text matches the
+// text match pattern.
+func (lc *ListCfg) hasTextMatch(s *S) bool {
+	for _, l := range s.text {
+		if lc.textMatch.MatchString(l) {
+			return true
+		}
 	}
+	return false
 }
 
-// printIntroOnce prints the intro on the ListCfg writer and sets it to
-// "". The next call with the same string will have no effect.
-func (lc *ListCfg) printIntroOnce() {
-	if lc.intro == "" {
+// mergeTagsFromEclipsed parses the content of the eclipsed snippet and
+// merges its tags into the snippet eclipsing it. Any tag already on the
+// eclipsing snippet is left unchanged; only tags which it does not have are
+// taken from the eclipsed snippet.
+func (lc *ListCfg) mergeTagsFromEclipsed(content []byte, fName, sName string) {
+	s, ok := lc.shown[sName]
+	if !ok {
+		return
+	}
+
+	eclipsed, err := parseSnippet(content, fName, sName)
+	if err != nil {
+		lc.errs.AddError("Bad snippet", err)
 		return
 	}
-	fmt.Fprint(lc.StdW(), lc.intro)
-	lc.intro = ""
+
+	for k, v := range eclipsed.tags {
+		if _, ok := s.tags[k]; !ok {
+			s.tags[k] = v
+		}
+	}
 }
 
 // display reports the file if it is a regular file, descends into the sub
 // directory if it is a directory and reports it as a problem otherwise
 func (lc *ListCfg) display(dir, subDir string, de fs.DirEntry, ck constraintCk) {
+	if de.Name() == IgnoreFileName ||
+		lc.isIgnored(de.Name()) ||
+		lc.ignoredByFile(dir, subDir, de) {
+		return
+	}
+
 	sName := de.Name()
 	if subDir != "" {
 		sName = filepath.Join(subDir, sName)
@@ -337,12 +1806,19 @@ func (lc *ListCfg) display(dir, subDir string, de fs.DirEntry, ck constraintCk)
 
 	if de.Type().IsRegular() ||
 		de.Type()&os.ModeSymlink == os.ModeSymlink {
+		sName = strings.TrimSuffix(sName, GzipSuffix)
+		if lc.isExcluded(sName) {
+			return
+		}
 		if ck == checkConstraints &&
 			!lc.specificFileMatch(sName) {
 			return
 		}
-		lc.displaySnippet(dir, fName, sName)
+		lc.queueSnippet(dir, fName, sName)
 	} else if de.IsDir() {
+		if lc.isExcluded(sName) {
+			return
+		}
 		if ck == checkConstraints {
 			if !lc.specificDirMatch(sName) {
 				return
@@ -362,12 +1838,16 @@ func (lc *ListCfg) display(dir, subDir string, de fs.DirEntry, ck constraintCk)
 // descend displays the contents of the sub directory
 func (lc *ListCfg) descend(dir, subDir string, ck constraintCk) {
 	name := filepath.Join(dir, subDir)
-	dirEntries, err := os.ReadDir(name)
+	dirEntries, err := readDir(lc.fsys, name)
 	if err != nil {
 		lc.errs.AddError(fmt.Sprintf("Bad sub-directory: %q", subDir), err)
 		return
 	}
+	lc.loadIgnoreFile(name)
 	for _, de := range dirEntries {
+		if lc.cancelled() {
+			return
+		}
 		lc.display(dir, subDir, de, ck)
 	}
 }
@@ -385,6 +1865,33 @@ func (lc *ListCfg) specificFileMatch(sName string) bool {
 	return false
 }
 
+// isIgnored returns true if the name matches any of the ignore patterns.
+func (lc *ListCfg) isIgnored(name string) bool {
+	for _, p := range lc.ignorePatterns {
+		if matched, _ := filepath.Match(p, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcluded returns true if the name or some leading part of it is in the
+// excludes map.
+func (lc *ListCfg) isExcluded(name string) bool {
+	for ; name != "." && name != "/" && name != ""; name = filepath.Dir(name) {
+		if lc.excludes[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// partialListing returns true if not all of the snippets will be read,
+// either because there are constraints or because some are excluded.
+func (lc *ListCfg) partialListing() bool {
+	return len(lc.constraints) > 0 || len(lc.excludes) > 0
+}
+
 // specificDirMatch returns true if:
 //
 // - there are no specific snippets to be matched
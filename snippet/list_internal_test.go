@@ -2,6 +2,12 @@ package snippet
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/nickwells/errutil.mod/errutil"
@@ -91,3 +97,323 @@ func TestConstraintsDirMatch(t *testing.T) {
 		testhelper.DiffBool(t, tc.IDStr(), "match result", val, tc.expVal)
 	}
 }
+
+func TestImportIsDenied(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		path   string
+		denied []string
+		expVal string
+	}{
+		{
+			ID:   testhelper.MkID("nothing denied"),
+			path: "io/ioutil",
+		},
+		{
+			ID:     testhelper.MkID("exact match"),
+			path:   "io/ioutil",
+			denied: []string{"os", "io/ioutil"},
+			expVal: "io/ioutil",
+		},
+		{
+			ID:     testhelper.MkID("exact entry, no prefix match"),
+			path:   "io/ioutil/sub",
+			denied: []string{"io/ioutil"},
+		},
+		{
+			ID:     testhelper.MkID("prefix match"),
+			path:   "golang.org/x/net/context",
+			denied: []string{"golang.org/x/net/..."},
+			expVal: "golang.org/x/net/...",
+		},
+		{
+			ID:     testhelper.MkID("prefix entry matches the path itself"),
+			path:   "golang.org/x/net",
+			denied: []string{"golang.org/x/net/..."},
+			expVal: "golang.org/x/net/...",
+		},
+		{
+			ID:     testhelper.MkID("prefix entry, partial name"),
+			path:   "golang.org/x/network",
+			denied: []string{"golang.org/x/net/..."},
+		},
+	}
+
+	for _, tc := range testCases {
+		val := importIsDenied(tc.path, tc.denied)
+		testhelper.DiffString(t, tc.IDStr(), "denied by", val, tc.expVal)
+	}
+}
+
+func TestListSnippetDir(t *testing.T) {
+	absFile, err := filepath.Abs(filepath.Join(TestSnippets, "complete"))
+	if err != nil {
+		t.Fatal("cannot get the absolute pathname: ", err)
+	}
+
+	lc, err := NewListCfg(io.Discard,
+		[]string{GoodSnippets, TestSnippets}, errutil.NewErrMap(),
+		SetConstraints("hw", "expects1", absFile))
+	if err != nil {
+		t.Fatal("cannot construct the ListCfg: ", err)
+	}
+	lc.List()
+
+	expDirs := map[string]string{
+		absFile:    filepath.Dir(absFile),
+		"hw":       GoodSnippets,
+		"expects1": TestSnippets,
+	}
+	for _, g := range lc.groups {
+		for _, s := range g.snippets {
+			testhelper.DiffString(t, s.Name(), "dir",
+				s.Dir(), expDirs[s.Name()])
+			delete(expDirs, s.Name())
+		}
+	}
+	for name := range expDirs {
+		t.Errorf("snippet %q was not listed", name)
+	}
+}
+
+func TestListContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buf bytes.Buffer
+	errs := errutil.NewErrMap()
+	lc, err := NewListCfg(&buf, []string{GoodSnippets}, errs)
+	if err != nil {
+		t.Fatal("cannot construct the ListCfg: ", err)
+	}
+	lc.ListContext(ctx)
+
+	err = errs.Matches(errutil.ErrMap{
+		"Listing cancelled": []error{context.Canceled},
+	})
+	if err != nil {
+		t.Error("unexpected errors: ", err)
+	}
+	testhelper.DiffString(t, "cancelled", "output", buf.String(), "")
+
+	errs = errutil.NewErrMap()
+	lc, err = NewListCfg(&buf, []string{GoodSnippets}, errs)
+	if err != nil {
+		t.Fatal("cannot construct the ListCfg: ", err)
+	}
+	lc.ListContext(context.Background())
+	if err = errs.Matches(errutil.ErrMap{}); err != nil {
+		t.Error("unexpected errors: ", err)
+	}
+	if buf.Len() == 0 {
+		t.Error("the snippets should have been listed")
+	}
+}
+
+func TestCollect(t *testing.T) {
+	dirs := []string{
+		filepath.Join("testdata", "deprecated.snippets"),
+		filepath.Join("testdata", "wrap.snippets"),
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		opts     []ListCfgOptFunc
+		expNames []string
+	}{
+		{
+			ID:       testhelper.MkID("all"),
+			expNames: []string{"current", "old", "retired", "long"},
+		},
+		{
+			ID: testhelper.MkID("hide deprecated, JSON format"),
+			opts: []ListCfgOptFunc{
+				HideDeprecated(true),
+				SetOutputFormat(FormatJSON),
+			},
+			expNames: []string{"current", "long"},
+		},
+		{
+			ID:       testhelper.MkID("limit"),
+			opts:     []ListCfgOptFunc{SetLimit(2)},
+			expNames: []string{"current", "old"},
+		},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		errs := errutil.NewErrMap()
+		lc, err := NewListCfg(&buf, dirs, errs, tc.opts...)
+		if err != nil {
+			t.Fatal("cannot construct the ListCfg: ", err)
+		}
+		snippets, err := lc.Collect()
+		if err != nil {
+			t.Log(tc.IDStr())
+			t.Error("\t: unexpected error: ", err)
+			continue
+		}
+		testhelper.DiffStringSlice(t, tc.IDStr(), "names",
+			snippetNames(snippets), tc.expNames)
+		testhelper.DiffString(t, tc.IDStr(), "output", buf.String(), "")
+		if err = errs.Matches(errutil.ErrMap{}); err != nil {
+			t.Log(tc.IDStr())
+			t.Error("\t: unexpected errors: ", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errs := errutil.NewErrMap()
+	lc, err := NewListCfg(io.Discard, dirs, errs)
+	if err != nil {
+		t.Fatal("cannot construct the ListCfg: ", err)
+	}
+	snippets, err := lc.CollectContext(ctx)
+	if err != context.Canceled {
+		t.Error("expected the context error, got: ", err)
+	}
+	testhelper.DiffInt(t, "cancelled", "snippets", len(snippets), 0)
+}
+
+func TestListConfineSnippetNames(t *testing.T) {
+	errs := errutil.NewErrMap()
+	lc, err := NewListCfg(io.Discard, []string{GoodSnippets}, errs,
+		SetConstraints("../x", "hw"), ConfineSnippetNames(true))
+	if err != nil {
+		t.Fatal("cannot construct the ListCfg: ", err)
+	}
+	lc.List()
+
+	err = errs.Matches(errutil.ErrMap{
+		"Bad specific snippet": []error{
+			errors.New(`snippet name "../x"` +
+				` is outside the snippet directory`),
+		},
+	})
+	if err != nil {
+		t.Error("unexpected errors: ", err)
+	}
+	if lc.snippetExists("../" + filepath.Base(GoodSnippets) + "/hw") {
+		t.Error("a snippet outside the snippet directory should not exist")
+	}
+}
+
+func TestListTwice(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a": "a()\n",
+		"b": "// snippet: expects: a\n// snippet: seealso: a\nb()\n",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+		if err != nil {
+			t.Fatal("cannot write the snippet file: ", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	errs := errutil.NewErrMap()
+	lc, err := NewListCfg(&buf, []string{dir}, errs)
+	if err != nil {
+		t.Fatal("cannot construct the ListCfg: ", err)
+	}
+	lc.List()
+	first := buf.String()
+	buf.Reset()
+	lc.List()
+
+	testhelper.DiffString(t, "listed twice", "output", buf.String(), first)
+	testhelper.DiffStringSlice(t, "listed twice", "expected by",
+		lc.expectedBy["a"], []string{"b"})
+	testhelper.DiffStringSlice(t, "listed twice", "seealso by",
+		lc.seeAlsoBy["a"], []string{"b"})
+	if err = errs.Matches(errutil.ErrMap{}); err != nil {
+		t.Error("unexpected errors: ", err)
+	}
+}
+
+func TestResolveChainedAliases(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b", "c"} {
+		err := os.WriteFile(filepath.Join(dir, name),
+			[]byte(name+"()\n"), 0o644)
+		if err != nil {
+			t.Fatal("cannot write the snippet file: ", err)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		errs := errutil.NewErrMap()
+		lc, err := NewListCfg(io.Discard, []string{dir}, errs,
+			SetConstraints("a"),
+			SetRegistry(Registry{
+				aliases: map[string]string{"a": "b", "b": "c"},
+			}))
+		if err != nil {
+			t.Fatal("cannot construct the ListCfg: ", err)
+		}
+		lc.resolveAliases()
+		lc.resolveAliases()
+
+		constraints := []string{}
+		for k := range lc.constraints {
+			constraints = append(constraints, k)
+		}
+		id := fmt.Sprintf("chained aliases: %d", i)
+		testhelper.DiffStringSlice(t, id, "constraints",
+			constraints, []string{"b"})
+		if err = errs.Matches(errutil.ErrMap{}); err != nil {
+			t.Error("unexpected errors: ", err)
+		}
+	}
+}
+
+func TestTagSortKey(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		tags    map[string][]string
+		expRank int
+		expNum  float64
+		expStr  string
+	}{
+		{
+			ID:      testhelper.MkID("numeric"),
+			tags:    map[string][]string{"Order": {"1.5"}},
+			expRank: 0,
+			expNum:  1.5,
+		},
+		{
+			ID:      testhelper.MkID("non-numeric"),
+			tags:    map[string][]string{"Order": {"first"}},
+			expRank: 1,
+			expStr:  "first",
+		},
+		{
+			ID:      testhelper.MkID("NaN"),
+			tags:    map[string][]string{"Order": {"NaN"}},
+			expRank: 1,
+			expStr:  "NaN",
+		},
+		{
+			ID:      testhelper.MkID("Inf"),
+			tags:    map[string][]string{"Order": {"-infinity"}},
+			expRank: 1,
+			expStr:  "-infinity",
+		},
+		{
+			ID:      testhelper.MkID("missing"),
+			tags:    map[string][]string{},
+			expRank: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		rank, num, str := tagSortKey(&S{tags: tc.tags}, "Order")
+		testhelper.DiffInt(t, tc.IDStr(), "rank", rank, tc.expRank)
+		if num != tc.expNum {
+			t.Log(tc.IDStr())
+			t.Errorf("\t: number: got %g, want %g", num, tc.expNum)
+		}
+		testhelper.DiffString(t, tc.IDStr(), "string", str, tc.expStr)
+	}
+}
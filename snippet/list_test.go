@@ -4,9 +4,16 @@ package snippet_test
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/nickwells/errutil.mod/errutil"
 	"github.com/nickwells/snippet.mod/snippet"
@@ -32,236 +39,1493 @@ func init() {
 
 func TestConfigList(t *testing.T) {
 	testListCfgDir := filepath.Join("testdata", "testListConfig")
+	registry, err := snippet.LoadRegistry(
+		filepath.Join("testdata", "aliases.registry"))
+	if err != nil {
+		t.Fatal("Couldn't load the registry:", err)
+	}
+	lintDir := filepath.Join("testdata", "lint.snippets")
+	badGoDir := filepath.Join("testdata", "badGo.snippets")
+	trailingBlankDir := filepath.Join("testdata", "trailingBlank.snippets")
+	wrapDir := filepath.Join("testdata", "wrap.snippets")
+	layeredDirs := []string{
+		filepath.Join("testdata", "layered", "override"),
+		filepath.Join("testdata", "layered", "base"),
+		filepath.Join("testdata", "layered", "deepest"),
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		dirs     []string
+		expErrs  errutil.ErrMap
+		expWarns errutil.ErrMap
+		opts     []snippet.ListCfgOptFunc
+	}{
+		{
+			ID:   testhelper.MkID("configList.dflt"),
+			dirs: []string{snippet.GoodSnippets},
+		},
+		{
+			ID:   testhelper.MkID("configList.seeAlso"),
+			dirs: []string{testListCfgDir},
+			expWarns: errutil.ErrMap{
+				"Missing related snippet": []error{
+					errors.New(`snippet "noSuchSnippet" does not exist` +
+						` but is 'seealso' by "snip3"`),
+				},
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.layered"),
+			dirs: layeredDirs,
+			expErrs: errutil.ErrMap{
+				"Eclipsed snippet": []error{
+					errors.New(`"tagged" in "` + layeredDirs[1] + `"` +
+						` is eclipsed by the entry in "` + layeredDirs[0] + `"`),
+					errors.New(`"tagged" in "` + layeredDirs[2] + `"` +
+						` is eclipsed by the entry in "` + layeredDirs[0] + `"`),
+				},
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.layered.overrideLater"),
+			dirs: layeredDirs,
+			expWarns: errutil.ErrMap{
+				"Overridden snippet": []error{
+					errors.New(`"tagged" in "` + layeredDirs[1] + `"` +
+						` is overridden by the entry in "` +
+						layeredDirs[2] + `"`),
+					errors.New(`"tagged" in "` + layeredDirs[0] + `"` +
+						` is overridden by the entry in "` +
+						layeredDirs[2] + `"`),
+				},
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetOverrideOrder(true),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.layered.eclipsedRefs"),
+			dirs: layeredDirs,
+			expWarns: errutil.ErrMap{
+				"Reference to eclipsed snippet": []error{
+					errors.New(`snippet "tagged", 'expected' by "user",` +
+						` is in "` + layeredDirs[0] + `" but is eclipsed` +
+						` in "` + layeredDirs[1] + `", "` + layeredDirs[2] + `"`),
+				},
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.MergeEclipsedTags(true),
+				snippet.WarnEclipsedReferences(true),
+				snippet.SetParts(snippet.NamePart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.layered.mergeTags"),
+			dirs: layeredDirs,
+			opts: []snippet.ListCfgOptFunc{snippet.MergeEclipsedTags(true)},
+		},
+		{
+			ID:   testhelper.MkID("configList.sortByName"),
+			dirs: []string{filepath.Join("testdata", "sorted")},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetSortBy(snippet.SortByName),
+				snippet.SetParts(snippet.NamePart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.sortByTag"),
+			dirs: []string{filepath.Join("testdata", "sorted")},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetSortBy(snippet.SortByTagPrefix + "Order"),
+				snippet.SetParts(snippet.NamePart),
+				snippet.SetTags("Order"),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.maxOutputLines"),
+			dirs: []string{testListCfgDir},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetMaxOutputLines(6),
+				snippet.SetConstraints("snip1", "snip3"),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.maxOutputLines.exact"),
+			dirs: []string{testListCfgDir},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetMaxOutputLines(4),
+				snippet.SetConstraints("snip1"),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.limit"),
+			dirs: []string{filepath.Join("testdata", "sorted")},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetLimit(2),
+				snippet.SetSortBy(snippet.SortByName),
+				snippet.SetParts(snippet.NamePart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.limit.notReached"),
+			dirs: []string{testListCfgDir},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetLimit(2),
+				snippet.SetConstraints("snip1"),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.lint.shadowedBuiltins"),
+			dirs: []string{lintDir},
+			expWarns: errutil.ErrMap{
+				"Shadowed builtin": []error{
+					errors.New(`snippet "shadow" declares: len`),
+				},
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.WarnShadowedBuiltins(true),
+				snippet.SetParts(snippet.NamePart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.lint.unformatted"),
+			dirs: []string{lintDir},
+			expWarns: errutil.ErrMap{
+				"Unformatted snippet": []error{
+					errors.New(`snippet "unformatted"` +
+						` is not formatted as gofmt would`),
+				},
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.WarnUnformatted(true),
+				snippet.SetParts(snippet.NamePart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.singletonTags"),
+			dirs: []string{testListCfgDir},
+			expWarns: errutil.ErrMap{
+				"Missing related snippet": []error{
+					errors.New(`snippet "noSuchSnippet" does not exist` +
+						` but is 'seealso' by "snip3"`),
+				},
+				"Singleton tag": []error{
+					errors.New(`tag "Author" is only used by "snip3"`),
+					errors.New(`tag "XXX" is only used by "snip3"`),
+				},
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.WarnSingletonTags(true),
+				snippet.SetParts(snippet.NamePart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.status"),
+			dirs: []string{filepath.Join("testdata", "status.snippets")},
+		},
+		{
+			ID:   testhelper.MkID("configList.statusFilter"),
+			dirs: []string{filepath.Join("testdata", "status.snippets")},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetStatusFilter(
+					snippet.StatusStable, snippet.StatusDeprecated),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.annotateReferences"),
+			dirs: []string{filepath.Join("testdata", "graph.snippets")},
+			expErrs: errutil.ErrMap{
+				"Missing expected snippet": []error{
+					errors.New(`snippet "nonesuch" does not exist` +
+						` but is 'expected' by "broken"`),
+				},
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.AnnotateReferences(true),
+				snippet.SetParts(snippet.NamePart,
+					snippet.ExpectPart, snippet.FollowPart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.annotateReferences.constrained"),
+			dirs: []string{filepath.Join("testdata", "graph.snippets")},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.AnnotateReferences(true),
+				snippet.SetConstraints("app", "broken"),
+				snippet.SetParts(snippet.NamePart,
+					snippet.ExpectPart, snippet.FollowPart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.gzipped"),
+			dirs: []string{filepath.Join("testdata", "gz.snippets")},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetParts(snippet.NamePart, snippet.PathPart,
+					snippet.DocsPart, snippet.ExpectPart, snippet.TextPart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.unknownComment"),
+			dirs: []string{filepath.Join("testdata", "unknown.snippets")},
+			expWarns: errutil.ErrMap{
+				"Unknown snippet comment": []error{
+					errors.New(filepath.Join(
+						"testdata", "unknown.snippets", "misspelt") +
+						`:2: unknown snippet comment:` +
+						` "// snippet: imprt: fmt"`),
+				},
+			},
+		},
+		{
+			ID: testhelper.MkID("configList.unknownComment.strict"),
+			dirs: []string{
+				filepath.Join("testdata", "unknown.snippets"),
+				snippet.GoodSnippets,
+			},
+			expErrs: errutil.ErrMap{
+				"Unknown snippet comment": []error{
+					errors.New(filepath.Join(
+						"testdata", "unknown.snippets", "misspelt") +
+						`:2: unknown snippet comment:` +
+						` "// snippet: imprt: fmt"`),
+				},
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetStrict(true),
+				snippet.SetParts(snippet.NamePart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.deprecated"),
+			dirs: []string{filepath.Join("testdata", "deprecated.snippets")},
+		},
+		{
+			ID:   testhelper.MkID("configList.hideDeprecated"),
+			dirs: []string{filepath.Join("testdata", "deprecated.snippets")},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.HideDeprecated(true),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.checkGo"),
+			dirs: []string{badGoDir},
+			expErrs: errutil.ErrMap{
+				"Invalid Go code": []error{
+					errors.New(`snippet "broken"` +
+						` (` + filepath.Join(badGoDir, "broken") + `):` +
+						` the snippet text is not valid Go code:` +
+						` line 3, column 16:` +
+						` expected statement, found ')'`),
+					errors.New(`snippet "prose"` +
+						` (` + filepath.Join(badGoDir, "prose") + `):` +
+						` the snippet text is not valid Go code:` +
+						` line 1, column 5:` +
+						` expected ';', found the`),
+				},
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetCheckGo(true),
+				snippet.SetParts(snippet.NamePart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.trailingBlank"),
+			dirs: []string{trailingBlankDir},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetParts(snippet.NamePart, snippet.TextPart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.trailingBlank.trimmed"),
+			dirs: []string{trailingBlankDir},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.TrimTrailingBlankLines(true),
+				snippet.SetParts(snippet.NamePart, snippet.TextPart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.indent"),
+			dirs: []string{trailingBlankDir},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetIndent(2),
+				snippet.SetNameIndent(0),
+				snippet.SetParts(snippet.NamePart, snippet.DocsPart,
+					snippet.TextPart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.wrap"),
+			dirs: []string{wrapDir},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetWrapWidth(60),
+				snippet.SetParts(snippet.NamePart, snippet.DocsPart,
+					snippet.TextPart),
+				snippet.SetTags("Author", "Flag"),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.wrap.noIntro"),
+			dirs: []string{wrapDir},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetWrapWidth(40),
+				snippet.HideIntro(true),
+				snippet.SetParts(snippet.DocsPart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.json"),
+			dirs: []string{filepath.Join("testdata", "deprecated.snippets")},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetOutputFormat(snippet.FormatJSON),
+				// read through an fs.FS so that there is no
+				// modification time to vary between runs
+				snippet.SetFS(os.DirFS(".")),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.json.parts"),
+			dirs: []string{wrapDir},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetOutputFormat(snippet.FormatJSON),
+				snippet.SetParts(snippet.ImportPart, snippet.TextPart),
+				snippet.SetTags("Author"),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.json.limit"),
+			dirs: []string{filepath.Join("testdata", "deprecated.snippets")},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetOutputFormat(snippet.FormatJSON),
+				snippet.SetParts(snippet.NamePart),
+				snippet.SetLimit(1),
+			},
+		},
+		{
+			ID: testhelper.MkID("configList.markdown"),
+			dirs: []string{
+				filepath.Join("testdata", "deprecated.snippets"),
+				wrapDir,
+				layeredDirs[0],
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetOutputFormat(snippet.FormatMarkdown),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.markdown.parts"),
+			dirs: []string{wrapDir},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetOutputFormat(snippet.FormatMarkdown),
+				snippet.SetParts(snippet.NamePart, snippet.PathPart,
+					snippet.ExpectPart),
+				snippet.HideIntro(true),
+			},
+		},
+		{
+			ID: testhelper.MkID("configList.textMatch"),
+			dirs: []string{
+				filepath.Join("testdata", "deprecated.snippets"),
+				wrapDir,
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetTextMatch(`^(old|retired)\(\)$`),
+				snippet.SetParts(snippet.NamePart, snippet.TextPart),
+			},
+		},
+		{
+			ID: testhelper.MkID("configList.textMatch.constrained"),
+			dirs: []string{
+				filepath.Join("testdata", "deprecated.snippets"),
+				wrapDir,
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetTextMatch(`\(\)`),
+				snippet.SetConstraints("old", "long"),
+				snippet.SetParts(snippet.NamePart, snippet.TextPart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.ignoreFile"),
+			dirs: []string{filepath.Join("testdata", "ignoreFile.snippets")},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetParts(snippet.NamePart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.ignoreFile.noPatterns"),
+			dirs: []string{filepath.Join("testdata", "ignoreFile.snippets")},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetIgnorePatterns(),
+				snippet.SetParts(snippet.NamePart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.registry"),
+			dirs: []string{testListCfgDir},
+			expErrs: errutil.ErrMap{
+				"Bad snippet alias": []error{
+					errors.New(`"gone" refers to "nonesuch"` +
+						` which does not exist`),
+				},
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetConstraints("s1", "gone", "snip3"),
+				snippet.SetRegistry(registry),
+				snippet.SetParts(snippet.NamePart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.fs"),
+			dirs: []string{"lib", "more"},
+			expErrs: errutil.ErrMap{
+				"Eclipsed snippet": []error{
+					errors.New(`"hw" in "more"` +
+						` is eclipsed by the entry in "lib"`),
+				},
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetFS(fstest.MapFS{
+					"lib/hw": {
+						Data: []byte("// snippet: note: in lib\n" +
+							"fmt.Println(\"Hello\")\n"),
+					},
+					"lib/sub/use": {
+						Data: []byte("// snippet: expects: hw\nuse()\n"),
+					},
+					"more/hw": {Data: []byte("hw()\n")},
+				}),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.lint.encoding"),
+			dirs: []string{lintDir},
+			expErrs: errutil.ErrMap{
+				"Bad encoding": []error{
+					errors.New(`snippet "bom" starts with a byte order mark`),
+					errors.New(`snippet "notUTF8" is not valid UTF-8`),
+				},
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.CheckEncoding(true),
+				snippet.SetParts(snippet.NamePart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.lint.uniqueBaseNames"),
+			dirs: []string{lintDir},
+			expErrs: errutil.ErrMap{
+				"Duplicate snippet file name": []error{
+					errors.New(`"shadow" in "` + lintDir + `"` +
+						` is used by: shadow, ` +
+						filepath.Join("sub", "shadow")),
+				},
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.RequireUniqueBaseNames(true),
+				snippet.SetParts(snippet.NamePart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.checkDocLinks"),
+			dirs: []string{testListCfgDir},
+			expWarns: errutil.ErrMap{
+				"Missing related snippet": []error{
+					errors.New(`snippet "noSuchSnippet" does not exist` +
+						` but is 'seealso' by "snip3"`),
+				},
+				"Missing snippet in notes": []error{
+					errors.New(`snippet "nonesuch" does not exist` +
+						` but is noted by "snip3"`),
+				},
+			},
+			opts: []snippet.ListCfgOptFunc{snippet.CheckDocLinks(true)},
+		},
+		{
+			ID:   testhelper.MkID("configList.snip3.SeeAlso"),
+			dirs: []string{testListCfgDir},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetConstraints("snip3"),
+				snippet.SetParts(snippet.SeeAlsoPart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.hideIntro"),
+			dirs: []string{snippet.GoodSnippets},
+			opts: []snippet.ListCfgOptFunc{snippet.HideIntro(true)},
+		},
+		{
+			ID:   testhelper.MkID("configList.specific-hw"),
+			dirs: []string{snippet.GoodSnippets},
+			opts: []snippet.ListCfgOptFunc{snippet.SetConstraints("hw")},
+		},
+		{
+			ID:   testhelper.MkID("configList.specific-subDir1"),
+			dirs: []string{snippet.GoodSnippets},
+			opts: []snippet.ListCfgOptFunc{snippet.SetConstraints("subDir1")},
+		},
+		{
+			ID:   testhelper.MkID("configList.specific-subDir1-file"),
+			dirs: []string{snippet.GoodSnippets},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetConstraints("subDir1/goodNoExp"),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.exclude-subDir1"),
+			dirs: []string{snippet.GoodSnippets},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetExcludeConstraints("subDir1"),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.exclude-subDir1-file"),
+			dirs: []string{snippet.GoodSnippets},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetExcludeConstraints("subDir1/goodNoExp/"),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.exclude-overrides-constraint"),
+			dirs: []string{snippet.GoodSnippets},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetConstraints("hw", "subDir1"),
+				snippet.SetExcludeConstraints("subDir1/goodNoExp"),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.exclude-snip3"),
+			dirs: []string{testListCfgDir},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetExcludeConstraints("snip3"),
+				snippet.SetParts(snippet.NamePart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.specific-snip1"),
+			dirs: []string{testListCfgDir},
+			opts: []snippet.ListCfgOptFunc{snippet.SetConstraints("snip1")},
+		},
+		{
+			ID:   testhelper.MkID("configList.specific-snip2"),
+			dirs: []string{testListCfgDir},
+			opts: []snippet.ListCfgOptFunc{snippet.SetConstraints("snip2")},
+		},
+		{
+			ID:   testhelper.MkID("configList.snip3.Docs"),
+			dirs: []string{testListCfgDir},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetConstraints("snip3"),
+				snippet.SetParts(snippet.DocsPart),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.snip3.Docs.HideIntro"),
+			dirs: []string{testListCfgDir},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetConstraints("snip3"),
+				snippet.SetParts(snippet.DocsPart),
+				snippet.HideIntro(true),
+			},
+		},
+		{
+			ID:   testhelper.MkID("configList.denyImports"),
+			dirs: []string{testListCfgDir},
+			expErrs: errutil.ErrMap{
+				"Denied import": []error{
+					errors.New(`snippet "snip2/snip2.1" imports` +
+						` "snip2/xxx" (denied by "snip2/...")`),
+				},
+			},
+			expWarns: errutil.ErrMap{
+				"Missing related snippet": []error{
+					errors.New(`snippet "noSuchSnippet" does not exist` +
+						` but is 'seealso' by "snip3"`),
+				},
+			},
+			opts: []snippet.ListCfgOptFunc{
+				snippet.DenyImports("snip2/...", "snip3"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		errs := errutil.NewErrMap()
+		warns := errutil.NewErrMap()
+		opts := append([]snippet.ListCfgOptFunc{snippet.SetWarnings(warns)},
+			tc.opts...)
+		lc, err := snippet.NewListCfg(&buf, tc.dirs, errs, opts...)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+
+		lc.List()
+
+		if err = warns.Matches(tc.expWarns); err != nil {
+			var warnRpt bytes.Buffer
+			warns.Report(&warnRpt, "Snippet warnings")
+			t.Log(tc.IDStr())
+			t.Log("\t: differences:", err)
+			t.Log("\t: warning map:\n", warnRpt.String())
+			t.Errorf("\t: unexpected warning map\n\n")
+			continue
+		}
+
+		if err = errs.Matches(tc.expErrs); err != nil {
+			var errRpt bytes.Buffer
+			errs.Report(&errRpt, "Snippet errors")
+			t.Log(tc.IDStr())
+			t.Log("\t: differences:", err)
+			t.Log("\t: error map:\n", errRpt.String())
+			t.Errorf("\t: unexpected error map\n\n")
+			continue
+		}
+		gfc.Check(t, tc.IDStr(), tc.ID.Name, buf.Bytes())
+	}
+}
+
+func TestList(t *testing.T) {
+	defer testhelper.MakeTempDir(snippet.EmptyDir, 0o777)()
+	defer testhelper.MakeTempDir(snippet.UnreadableDir, 0)()
+	defer testhelper.MakeTempDir(snippet.BadDir, 0o777)()
+	defer testhelper.MakeTempDir(snippet.UnreadableSubDir, 0)()
+	defer testhelper.TempChmod(snippet.UnreadableFile, 0o333)()
+
+	testCases := []struct {
+		testhelper.ID
+		dirs    []string
+		expErrs errutil.ErrMap
+	}{
+		{
+			ID: testhelper.MkID("noDirs.noErrs"),
+		},
+		{
+			ID:   testhelper.MkID("oneDir.noErrs"),
+			dirs: []string{snippet.GoodSnippets},
+		},
+		{
+			ID: testhelper.MkID("twoDirs.oneEmpty.noErrs"),
+			dirs: []string{
+				snippet.GoodSnippets,
+				snippet.EmptyDir,
+			},
+		},
+		{
+			ID: testhelper.MkID("twoGoodDirs.eclipses"),
+			dirs: []string{
+				snippet.GoodSnippets,
+				snippet.MoreGoodSnippets,
+			},
+			expErrs: errutil.ErrMap{
+				"Eclipsed snippet": []error{
+					errors.New(`"hw" in "` + snippet.MoreGoodSnippets + `"` +
+						` is eclipsed by the entry` +
+						` in "` + snippet.GoodSnippets + `"`),
+				},
+			},
+		},
+		{
+			ID: testhelper.MkID("threeDirs.oneEmpty.oneNonExistant.noErrs"),
+			dirs: []string{
+				snippet.GoodSnippets,
+				snippet.EmptyDir,
+				snippet.NoSuchDir,
+			},
+		},
+		{
+			ID: testhelper.MkID("twoDirs.oneUnreadable"),
+			dirs: []string{
+				snippet.GoodSnippets,
+				snippet.UnreadableDir,
+			},
+			expErrs: errutil.ErrMap{
+				`Bad snippets directory: "` + snippet.UnreadableDir + `"`: []error{
+					errors.New("open " + snippet.UnreadableDir +
+						": permission denied"),
+				},
+			},
+		},
+		{
+			ID: testhelper.MkID("twoDirs.badSnippets"),
+			dirs: []string{
+				snippet.GoodSnippets,
+				snippet.BadSnippets,
+			},
+			expErrs: errutil.ErrMap{
+				`Bad snippet`: []error{
+					errors.New(
+						`snippet "noText"` +
+							` (` + snippet.BadSnippets + `/noText)` +
+							` has no text and no imports`),
+					errors.New(
+						`snippet "toBeMadeUnreadable":` +
+							` open ` + snippet.UnreadableFile + `:` +
+							` permission denied`),
+				},
+				"Duplicate snippet": []error{
+					errors.New(`snippet` +
+						` "` + snippet.BadSnippets + `/duplicate2"` +
+						` is a duplicate of` +
+						` "` + snippet.BadSnippets + `/duplicate1"`),
+				},
+				"Missing expected snippet": []error{
+					errors.New(`snippet "noSuchSnippet"` +
+						` does not exist but is 'expected' by` +
+						` "badExpectations"`),
+				},
+			},
+		},
+		{
+			ID: testhelper.MkID("twoDirs.unreadableSubDir"),
+			dirs: []string{
+				snippet.GoodSnippets,
+				snippet.BadDir,
+			},
+			expErrs: errutil.ErrMap{
+				`Bad sub-directory: "unreadable.dir"`: []error{
+					errors.New(`open ` + snippet.UnreadableSubDir + `:` +
+						` permission denied`),
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		errs := errutil.NewErrMap()
+		snippet.List(&buf, tc.dirs, errs)
+		if err := errs.Matches(tc.expErrs); err != nil {
+			var errRpt bytes.Buffer
+			errs.Report(&errRpt, "Snippet errors")
+			t.Log(tc.IDStr())
+			t.Log("\t: differences:", err)
+			t.Log("\t: error map:\n", errRpt.String())
+			t.Errorf("\t: unexpected error map\n\n")
+			continue
+		}
+		gfc.Check(t, tc.IDStr(), tc.ID.Name, buf.Bytes())
+	}
+}
+
+func TestListRequireDirsExist(t *testing.T) {
+	defer testhelper.MakeTempDir(snippet.EmptyDir, 0o777)()
+	defer testhelper.MakeTempDir(snippet.UnreadableDir, 0)()
+
+	testCases := []struct {
+		testhelper.ID
+		dirs    []string
+		expErrs errutil.ErrMap
+	}{
+		{
+			ID: testhelper.MkID("present"),
+			dirs: []string{
+				snippet.GoodSnippets,
+				snippet.EmptyDir,
+			},
+		},
+		{
+			ID: testhelper.MkID("missing"),
+			dirs: []string{
+				snippet.GoodSnippets,
+				snippet.NoSuchDir,
+			},
+			expErrs: errutil.ErrMap{
+				`Bad snippets directory: "` + snippet.NoSuchDir + `"`: []error{
+					errors.New("open " + snippet.NoSuchDir +
+						": no such file or directory"),
+				},
+			},
+		},
+		{
+			ID: testhelper.MkID("a file"),
+			dirs: []string{
+				snippet.GoodSnippets,
+				filepath.Join(snippet.GoodSnippets, "hw"),
+			},
+			expErrs: errutil.ErrMap{
+				`Bad snippets directory: "` +
+					filepath.Join(snippet.GoodSnippets, "hw") + `"`: []error{
+					errors.New(`snippet directory "` +
+						filepath.Join(snippet.GoodSnippets, "hw") + `"` +
+						` is not a directory`),
+				},
+			},
+		},
+		{
+			ID: testhelper.MkID("unreadable"),
+			dirs: []string{
+				snippet.GoodSnippets,
+				snippet.UnreadableDir,
+			},
+			expErrs: errutil.ErrMap{
+				`Bad snippets directory: "` + snippet.UnreadableDir + `"`: []error{
+					errors.New("open " + snippet.UnreadableDir +
+						": permission denied"),
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		errs := errutil.NewErrMap()
+		lc, err := snippet.NewListCfg(io.Discard, tc.dirs, errs,
+			snippet.RequireDirsExist(true))
+		if err != nil {
+			t.Fatal("Couldn't construct the ListCfg:", err)
+		}
+		lc.List()
+		if err := errs.Matches(tc.expErrs); err != nil {
+			t.Log(tc.IDStr())
+			t.Errorf("\t: unexpected error map: %s", err)
+		}
+	}
+}
+
+func TestNewListCfgSetParts(t *testing.T) {
+	badPart := "blah blah blah"
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		parts []string
+	}{
+		{
+			ID:    testhelper.MkID("good parts"),
+			parts: []string{snippet.ImportPart},
+		},
+		{
+			ID: testhelper.MkID("bad parts"),
+			ExpErr: testhelper.MkExpErr(`"` + badPart + `" is` +
+				` not a valid pre-defined part of a snippet`),
+			parts: []string{badPart},
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := snippet.NewListCfg(nil, nil, nil,
+			snippet.SetParts(tc.parts...))
+		testhelper.CheckExpErr(t, err, tc)
+	}
+}
+
+func TestNewListCfgSetMaxOutputLines(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		n int
+	}{
+		{
+			ID: testhelper.MkID("no limit"),
+		},
+		{
+			ID: testhelper.MkID("limited"),
+			n:  10,
+		},
+		{
+			ID: testhelper.MkID("negative"),
+			ExpErr: testhelper.MkExpErr(
+				"the maximum number of output lines (-1)" +
+					" must not be negative"),
+			n: -1,
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := snippet.NewListCfg(nil, nil, nil,
+			snippet.SetMaxOutputLines(tc.n))
+		testhelper.CheckExpErr(t, err, tc)
+	}
+}
+
+func TestNewListCfgSetLimit(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		n int
+	}{
+		{
+			ID: testhelper.MkID("no limit"),
+		},
+		{
+			ID: testhelper.MkID("limited"),
+			n:  20,
+		},
+		{
+			ID: testhelper.MkID("negative"),
+			ExpErr: testhelper.MkExpErr(
+				"the maximum number of snippets to show (-1)" +
+					" must not be negative"),
+			n: -1,
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := snippet.NewListCfg(nil, nil, nil,
+			snippet.SetLimit(tc.n))
+		testhelper.CheckExpErr(t, err, tc)
+	}
+}
+
+func TestNewListCfgSetStatusFilter(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		statuses []string
+	}{
+		{
+			ID: testhelper.MkID("no statuses"),
+		},
+		{
+			ID:       testhelper.MkID("valid statuses"),
+			statuses: []string{snippet.StatusStable, snippet.StatusDeprecated},
+		},
+		{
+			ID:       testhelper.MkID("bad status"),
+			statuses: []string{snippet.StatusStable, "Stable"},
+			ExpErr:   testhelper.MkExpErr(`bad status filter: "Stable"`),
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := snippet.NewListCfg(nil, nil, nil,
+			snippet.SetStatusFilter(tc.statuses...))
+		testhelper.CheckExpErr(t, err, tc)
+	}
+}
+
+func TestNewListCfgSetHashFunc(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		h func() hash.Hash
+	}{
+		{
+			ID: testhelper.MkID("sha256"),
+			h:  sha256.New,
+		},
+		{
+			ID:     testhelper.MkID("nil"),
+			ExpErr: testhelper.MkExpErr("the hash func must not be nil"),
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := snippet.NewListCfg(nil, nil, nil,
+			snippet.SetHashFunc(tc.h))
+		testhelper.CheckExpErr(t, err, tc)
+	}
+}
+
+func TestListSetHashFunc(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		content := "same()\n"
+		if name == "c" {
+			content = "different()\n"
+		}
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o666)
+		if err != nil {
+			t.Fatal("Couldn't write the snippet:", err)
+		}
+	}
+
+	hashCount := 0
+	hashFunc := func() hash.Hash {
+		hashCount++
+		return sha256.New()
+	}
+
+	errs := errutil.NewErrMap()
+	lc, err := snippet.NewListCfg(io.Discard, []string{dir}, errs,
+		snippet.SetHashFunc(hashFunc))
+	if err != nil {
+		t.Fatal("Couldn't construct the ListCfg:", err)
+	}
+	lc.List()
+
+	testhelper.DiffInt(t, "sha256", "hash count", hashCount, 3)
+	err = errs.Matches(errutil.ErrMap{
+		"Duplicate snippet": []error{
+			errors.New(`snippet "` + filepath.Join(dir, "b") + `"` +
+				` is a duplicate of "` + filepath.Join(dir, "a") + `"`),
+		},
+	})
+	if err != nil {
+		t.Error("unexpected errors: ", err)
+	}
+}
+
+func TestListAllowDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte("same()\n"), 0o666)
+		if err != nil {
+			t.Fatal("Couldn't write the snippet:", err)
+		}
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		allow   bool
+		expErrs errutil.ErrMap
+	}{
+		{
+			ID: testhelper.MkID("duplicates reported"),
+			expErrs: errutil.ErrMap{
+				"Duplicate snippet": []error{
+					errors.New(`snippet "` + filepath.Join(dir, "b") + `"` +
+						` is a duplicate of "` + filepath.Join(dir, "a") + `"`),
+				},
+			},
+		},
+		{
+			ID:      testhelper.MkID("duplicates allowed"),
+			allow:   true,
+			expErrs: errutil.ErrMap{},
+		},
+	}
+
+	for _, tc := range testCases {
+		hashCount := 0
+		hashFunc := func() hash.Hash {
+			hashCount++
+			return sha256.New()
+		}
+
+		var buf bytes.Buffer
+		errs := errutil.NewErrMap()
+		lc, err := snippet.NewListCfg(&buf, []string{dir}, errs,
+			snippet.SetHashFunc(hashFunc),
+			snippet.AllowDuplicates(tc.allow),
+			snippet.SetParts(snippet.NamePart))
+		if err != nil {
+			t.Fatal("Couldn't construct the ListCfg:", err)
+		}
+		lc.List()
+
+		testhelper.DiffInt(t, tc.IDStr(), "hash count", hashCount, 2)
+		if err = errs.Matches(tc.expErrs); err != nil {
+			t.Log(tc.IDStr())
+			t.Error("\t: unexpected errors: ", err)
+		}
+		testhelper.DiffString(t, tc.IDStr(), "output", buf.String(),
+			"in: "+dir+"\n\n    a\n\n    b\n")
+	}
+}
+
+func TestListSetDuplicateNormalization(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a": "// snippet: note: the original\nsame()\n",
+		"b": "// snippet: note: the copy\r\nsame()  \r\n\r\n",
+		"c": "// snippet: note: the original\nsame()\t\n",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o666)
+		if err != nil {
+			t.Fatal("Couldn't write the snippet:", err)
+		}
+	}
+	dupErr := func(dup, orig string) error {
+		return errors.New(`snippet "` + filepath.Join(dir, dup) + `"` +
+			` is a duplicate of "` + filepath.Join(dir, orig) + `"`)
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		dn      snippet.DuplicateNormalization
+		expErrs errutil.ErrMap
+	}{
+		{
+			ID:      testhelper.MkID("nothing"),
+			dn:      snippet.NormalizeNothing,
+			expErrs: errutil.ErrMap{},
+		},
+		{
+			ID: testhelper.MkID("white space"),
+			dn: snippet.NormalizeWhiteSpace,
+			expErrs: errutil.ErrMap{
+				"Duplicate snippet": []error{dupErr("c", "a")},
+			},
+		},
+		{
+			ID: testhelper.MkID("code only"),
+			dn: snippet.NormalizeCodeOnly,
+			expErrs: errutil.ErrMap{
+				"Duplicate snippet": []error{
+					dupErr("b", "a"),
+					dupErr("c", "a"),
+				},
+			},
+		},
+		{
+			ID: testhelper.MkID("bad"),
+			dn: snippet.DuplicateNormalization(-1),
+			ExpErr: testhelper.MkExpErr("bad duplicate normalization:" +
+				" unknown normalization (-1)"),
+		},
+	}
+
+	for _, tc := range testCases {
+		errs := errutil.NewErrMap()
+		lc, err := snippet.NewListCfg(io.Discard, []string{dir}, errs,
+			snippet.SetDuplicateNormalization(tc.dn))
+		if !testhelper.CheckExpErr(t, err, tc) || err != nil {
+			continue
+		}
+		lc.List()
+
+		if err = errs.Matches(tc.expErrs); err != nil {
+			t.Log(tc.IDStr())
+			t.Error("\t: unexpected errors: ", err)
+		}
+	}
+}
+
+func TestListSetIgnorePatterns(t *testing.T) {
+	dir := t.TempDir()
+	for i, name := range []string{
+		"a",
+		".a.swp",
+		"b~",
+		"#c#",
+		"README.md",
+		filepath.Join(".hidden", "x"),
+		filepath.Join("sub", "d"),
+	} {
+		fName := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fName), 0o777); err != nil {
+			t.Fatal("Couldn't make the directory:", err)
+		}
+		err := os.WriteFile(fName, []byte(fmt.Sprintf("f%d()\n", i)), 0o666)
+		if err != nil {
+			t.Fatal("Couldn't write the snippet:", err)
+		}
+	}
 
 	testCases := []struct {
 		testhelper.ID
-		dirs    []string
-		expErrs errutil.ErrMap
-		opts    []snippet.ListCfgOptFunc
+		testhelper.ExpErr
+		opts     []snippet.ListCfgOptFunc
+		expNames []string
 	}{
 		{
-			ID:   testhelper.MkID("configList.dflt"),
-			dirs: []string{snippet.GoodSnippets},
-		},
-		{
-			ID:   testhelper.MkID("configList.hideIntro"),
-			dirs: []string{snippet.GoodSnippets},
-			opts: []snippet.ListCfgOptFunc{snippet.HideIntro(true)},
+			ID:       testhelper.MkID("default"),
+			expNames: []string{"README.md", "a", "d"},
 		},
 		{
-			ID:   testhelper.MkID("configList.specific-hw"),
-			dirs: []string{snippet.GoodSnippets},
-			opts: []snippet.ListCfgOptFunc{snippet.SetConstraints("hw")},
+			ID: testhelper.MkID("set patterns"),
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetIgnorePatterns(
+					append(snippet.DfltIgnorePatterns(), "*.md", "sub")...),
+			},
+			expNames: []string{"a"},
 		},
 		{
-			ID:   testhelper.MkID("configList.specific-subDir1"),
-			dirs: []string{snippet.GoodSnippets},
-			opts: []snippet.ListCfgOptFunc{snippet.SetConstraints("subDir1")},
+			ID: testhelper.MkID("ignore nothing"),
+			opts: []snippet.ListCfgOptFunc{
+				snippet.SetIgnorePatterns(),
+			},
+			expNames: []string{
+				"#c#", ".a.swp", "x", "README.md", "a", "b~", "d",
+			},
 		},
 		{
-			ID:   testhelper.MkID("configList.specific-subDir1-file"),
-			dirs: []string{snippet.GoodSnippets},
+			ID: testhelper.MkID("bad pattern"),
 			opts: []snippet.ListCfgOptFunc{
-				snippet.SetConstraints("subDir1/goodNoExp"),
+				snippet.SetIgnorePatterns("*.md", "["),
 			},
+			ExpErr: testhelper.MkExpErr(`bad ignore pattern "[":`),
 		},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		errs := errutil.NewErrMap()
+		opts := append([]snippet.ListCfgOptFunc{
+			snippet.SetParts(snippet.NamePart),
+			snippet.HideIntro(true),
+		}, tc.opts...)
+		lc, err := snippet.NewListCfg(&buf, []string{dir}, errs, opts...)
+		if !testhelper.CheckExpErr(t, err, tc) || err != nil {
+			continue
+		}
+		lc.List()
+
+		if err = errs.Matches(errutil.ErrMap{}); err != nil {
+			t.Log(tc.IDStr())
+			t.Error("\t: unexpected errors: ", err)
+		}
+		names := []string{}
+		for _, n := range strings.Fields(buf.String()) {
+			names = append(names, filepath.Base(n))
+		}
+		testhelper.DiffStringSlice(t, tc.IDStr(), "names", names, tc.expNames)
+	}
+}
+
+func TestNewListCfgSetTagValues(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		pairs []string
+	}{
 		{
-			ID:   testhelper.MkID("configList.specific-snip1"),
-			dirs: []string{testListCfgDir},
-			opts: []snippet.ListCfgOptFunc{snippet.SetConstraints("snip1")},
+			ID:    testhelper.MkID("good"),
+			pairs: []string{"Author: John Doe", "XXX:YYY", "empty:"},
 		},
 		{
-			ID:   testhelper.MkID("configList.specific-snip2"),
-			dirs: []string{testListCfgDir},
-			opts: []snippet.ListCfgOptFunc{snippet.SetConstraints("snip2")},
+			ID:    testhelper.MkID("no colon"),
+			pairs: []string{"Author: John Doe", "Author"},
+			ExpErr: testhelper.MkExpErr(`bad tag value filter: "Author"` +
+				` (it should be tag: value)`),
 		},
 		{
-			ID:   testhelper.MkID("configList.snip3.Docs"),
-			dirs: []string{testListCfgDir},
-			opts: []snippet.ListCfgOptFunc{
-				snippet.SetConstraints("snip3"),
-				snippet.SetParts(snippet.DocsPart),
-			},
+			ID:    testhelper.MkID("no tag"),
+			pairs: []string{" : John Doe"},
+			ExpErr: testhelper.MkExpErr(`bad tag value filter: " : John Doe"` +
+				` (it should be tag: value)`),
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := snippet.NewListCfg(nil, nil, nil,
+			snippet.SetTagValues(tc.pairs...))
+		testhelper.CheckExpErr(t, err, tc)
+	}
+}
+
+func TestListSetTagValues(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		pairs    []string
+		expNames []string
+	}{
+		{
+			ID:       testhelper.MkID("no filter"),
+			expNames: []string{"complete", "expects1"},
 		},
 		{
-			ID:   testhelper.MkID("configList.snip3.Docs.HideIntro"),
-			dirs: []string{testListCfgDir},
-			opts: []snippet.ListCfgOptFunc{
-				snippet.SetConstraints("snip3"),
-				snippet.SetParts(snippet.DocsPart),
-				snippet.HideIntro(true),
-			},
+			ID:       testhelper.MkID("first of several values"),
+			pairs:    []string{"Author: John Doe"},
+			expNames: []string{"complete"},
+		},
+		{
+			ID:       testhelper.MkID("last of several values"),
+			pairs:    []string{"Author:Nedd Ludd"},
+			expNames: []string{"complete"},
+		},
+		{
+			ID:    testhelper.MkID("value only matches part of a value"),
+			pairs: []string{"XXX: YYY yy"},
+		},
+		{
+			ID:    testhelper.MkID("value of a different tag"),
+			pairs: []string{"XXX: John Doe"},
+		},
+		{
+			ID:       testhelper.MkID("any of several pairs"),
+			pairs:    []string{"Author: Nobody", "XXX: YYY yyy"},
+			expNames: []string{"complete"},
 		},
 	}
 
 	for _, tc := range testCases {
 		var buf bytes.Buffer
-		errs := errutil.NewErrMap()
-		lc, err := snippet.NewListCfg(&buf, tc.dirs, errs, tc.opts...)
+		lc, err := snippet.NewListCfg(&buf,
+			[]string{filepath.Join("testdata", "test.snippets")},
+			errutil.NewErrMap(),
+			snippet.SetConstraints("complete", "expects1"),
+			snippet.SetParts(snippet.NamePart),
+			snippet.SetTagValues(tc.pairs...))
 		if err != nil {
-			t.Fatal("Unexpected error:", err)
+			t.Fatal("Couldn't construct the ListCfg:", err)
 		}
-
 		lc.List()
 
-		if err = errs.Matches(tc.expErrs); err != nil {
-			var errRpt bytes.Buffer
-			errs.Report(&errRpt, "Snippet errors")
-			t.Log(tc.IDStr())
-			t.Log("\t: differences:", err)
-			t.Log("\t: error map:\n", errRpt.String())
-			t.Errorf("\t: unexpected error map\n\n")
-			continue
+		names := []string{}
+		for _, f := range strings.Fields(buf.String()) {
+			if f != "in:" && f != filepath.Join("testdata", "test.snippets") {
+				names = append(names, f)
+			}
 		}
-		gfc.Check(t, tc.IDStr(), tc.ID.Name, buf.Bytes())
+		if tc.expNames == nil {
+			tc.expNames = []string{}
+		}
+		testhelper.DiffStringSlice(t, tc.IDStr(), "snippets shown",
+			names, tc.expNames)
 	}
 }
 
-func TestList(t *testing.T) {
-	defer testhelper.MakeTempDir(snippet.EmptyDir, 0o777)()
-	defer testhelper.MakeTempDir(snippet.UnreadableDir, 0)()
-	defer testhelper.MakeTempDir(snippet.BadDir, 0o777)()
-	defer testhelper.MakeTempDir(snippet.UnreadableSubDir, 0)()
-	defer testhelper.TempChmod(snippet.UnreadableFile, 0o333)()
+func TestNewListCfgSetParallelism(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		n int
+	}{
+		{
+			ID: testhelper.MkID("one"),
+			n:  1,
+		},
+		{
+			ID: testhelper.MkID("zero"),
+		},
+		{
+			ID: testhelper.MkID("negative"),
+			n:  -1,
+			ExpErr: testhelper.MkExpErr(
+				"the parallelism (-1) must not be negative"),
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := snippet.NewListCfg(nil, nil, nil,
+			snippet.SetParallelism(tc.n))
+		testhelper.CheckExpErr(t, err, tc)
+	}
+}
 
+func TestNewListCfgSetIndent(t *testing.T) {
 	testCases := []struct {
 		testhelper.ID
-		dirs    []string
-		expErrs errutil.ErrMap
+		testhelper.ExpErr
+		opt snippet.ListCfgOptFunc
 	}{
 		{
-			ID: testhelper.MkID("noDirs.noErrs"),
+			ID:  testhelper.MkID("indent"),
+			opt: snippet.SetIndent(2),
 		},
 		{
-			ID:   testhelper.MkID("oneDir.noErrs"),
-			dirs: []string{snippet.GoodSnippets},
+			ID:  testhelper.MkID("zero indent"),
+			opt: snippet.SetIndent(0),
 		},
 		{
-			ID: testhelper.MkID("twoDirs.oneEmpty.noErrs"),
-			dirs: []string{
-				snippet.GoodSnippets,
-				snippet.EmptyDir,
-			},
+			ID:     testhelper.MkID("negative indent"),
+			opt:    snippet.SetIndent(-1),
+			ExpErr: testhelper.MkExpErr("the indent (-1) must not be negative"),
 		},
 		{
-			ID: testhelper.MkID("twoGoodDirs.eclipses"),
-			dirs: []string{
-				snippet.GoodSnippets,
-				snippet.MoreGoodSnippets,
-			},
-			expErrs: errutil.ErrMap{
-				"Eclipsed snippet": []error{
-					errors.New(`"hw" in "` + snippet.MoreGoodSnippets + `"` +
-						` is eclipsed by the entry` +
-						` in "` + snippet.GoodSnippets + `"`),
-				},
-			},
+			ID:  testhelper.MkID("wrap width"),
+			opt: snippet.SetWrapWidth(80),
 		},
 		{
-			ID: testhelper.MkID("threeDirs.oneEmpty.oneNonExistant.noErrs"),
-			dirs: []string{
-				snippet.GoodSnippets,
-				snippet.EmptyDir,
-				snippet.NoSuchDir,
-			},
+			ID:  testhelper.MkID("negative wrap width"),
+			opt: snippet.SetWrapWidth(-1),
+			ExpErr: testhelper.MkExpErr(
+				"the wrap width (-1) must not be negative"),
 		},
 		{
-			ID: testhelper.MkID("twoDirs.oneUnreadable"),
-			dirs: []string{
-				snippet.GoodSnippets,
-				snippet.UnreadableDir,
-			},
-			expErrs: errutil.ErrMap{
-				`Bad snippets directory: "` + snippet.UnreadableDir + `"`: []error{
-					errors.New("open " + snippet.UnreadableDir +
-						": permission denied"),
-				},
-			},
+			ID:  testhelper.MkID("output format"),
+			opt: snippet.SetOutputFormat(snippet.FormatJSON),
 		},
 		{
-			ID: testhelper.MkID("twoDirs.badSnippets"),
-			dirs: []string{
-				snippet.GoodSnippets,
-				snippet.BadSnippets,
-			},
-			expErrs: errutil.ErrMap{
-				`Bad snippet`: []error{
-					errors.New(
-						`snippet "noText"` +
-							` (` + snippet.BadSnippets + `/noText)` +
-							` has no text and no imports`),
-					errors.New(
-						`snippet "toBeMadeUnreadable":` +
-							` open ` + snippet.UnreadableFile + `:` +
-							` permission denied`),
-				},
-				"Duplicate snippet": []error{
-					errors.New(`snippet` +
-						` "` + snippet.BadSnippets + `/duplicate2"` +
-						` is a duplicate of` +
-						` "` + snippet.BadSnippets + `/duplicate1"`),
-				},
-				"Missing expected snippet": []error{
-					errors.New(`snippet "noSuchSnippet"` +
-						` does not exist but is 'expected' by` +
-						` "badExpectations"`),
-				},
-			},
+			ID:  testhelper.MkID("bad output format"),
+			opt: snippet.SetOutputFormat(snippet.OutputFormat(99)),
+			ExpErr: testhelper.MkExpErr(
+				"bad output format: unknown output format (99)"),
 		},
 		{
-			ID: testhelper.MkID("twoDirs.unreadableSubDir"),
-			dirs: []string{
-				snippet.GoodSnippets,
-				snippet.BadDir,
-			},
-			expErrs: errutil.ErrMap{
-				`Bad sub-directory: "unreadable.dir"`: []error{
-					errors.New(`open ` + snippet.UnreadableSubDir + `:` +
-						` permission denied`),
-				},
-			},
+			ID:  testhelper.MkID("text match"),
+			opt: snippet.SetTextMatch(`sync\.WaitGroup`),
+		},
+		{
+			ID:  testhelper.MkID("bad text match"),
+			opt: snippet.SetTextMatch(`(`),
+			ExpErr: testhelper.MkExpErr(`bad text match pattern "(":`,
+				"missing closing )"),
+		},
+		{
+			ID:  testhelper.MkID("name indent"),
+			opt: snippet.SetNameIndent(0),
+		},
+		{
+			ID:  testhelper.MkID("negative name indent"),
+			opt: snippet.SetNameIndent(-2),
+			ExpErr: testhelper.MkExpErr(
+				"the name indent (-2) must not be negative"),
 		},
 	}
 
 	for _, tc := range testCases {
+		_, err := snippet.NewListCfg(nil, nil, nil, tc.opt)
+		testhelper.CheckExpErr(t, err, tc)
+	}
+}
+
+func TestListSetParallelism(t *testing.T) {
+	dirs := []string{
+		filepath.Join("testdata", "layered", "override"),
+		filepath.Join("testdata", "layered", "base"),
+		filepath.Join("testdata", "layered", "deepest"),
+		filepath.Join("testdata", "lint.snippets"),
+		filepath.Join("testdata", "bad.snippets"),
+		snippet.GoodSnippets,
+		filepath.Join("testdata", "more.good.snippets"),
+	}
+
+	list := func(n int) (string, *errutil.ErrMap, *errutil.ErrMap) {
+		t.Helper()
 		var buf bytes.Buffer
 		errs := errutil.NewErrMap()
-		snippet.List(&buf, tc.dirs, errs)
-		if err := errs.Matches(tc.expErrs); err != nil {
-			var errRpt bytes.Buffer
-			errs.Report(&errRpt, "Snippet errors")
-			t.Log(tc.IDStr())
-			t.Log("\t: differences:", err)
-			t.Log("\t: error map:\n", errRpt.String())
-			t.Errorf("\t: unexpected error map\n\n")
-			continue
+		warns := errutil.NewErrMap()
+		lc, err := snippet.NewListCfg(&buf, dirs, errs,
+			snippet.SetParallelism(n),
+			snippet.SetWarnings(warns),
+			snippet.MergeEclipsedTags(true))
+		if err != nil {
+			t.Fatal("Couldn't construct the ListCfg:", err)
+		}
+		lc.List()
+		return buf.String(), errs, warns
+	}
+
+	expOut, expErrs, expWarns := list(1)
+	for _, n := range []int{2, 8} {
+		id := fmt.Sprintf("parallelism: %d", n)
+		for i := 0; i < 5; i++ {
+			out, errs, warns := list(n)
+			testhelper.DiffString(t, id, "output", out, expOut)
+			if err := errs.Matches(*expErrs); err != nil {
+				t.Log(id)
+				t.Error("\t: unexpected errors: ", err)
+			}
+			if err := warns.Matches(*expWarns); err != nil {
+				t.Log(id)
+				t.Error("\t: unexpected warnings: ", err)
+			}
 		}
-		gfc.Check(t, tc.IDStr(), tc.ID.Name, buf.Bytes())
 	}
 }
 
-func TestNewListCfgSetParts(t *testing.T) {
-	badPart := "blah blah blah"
+func TestNewListCfgSetSortBy(t *testing.T) {
 	testCases := []struct {
 		testhelper.ID
 		testhelper.ExpErr
-		parts []string
+		order string
 	}{
 		{
-			ID:    testhelper.MkID("good parts"),
-			parts: []string{snippet.ImportPart},
+			ID:    testhelper.MkID("by name"),
+			order: snippet.SortByName,
 		},
 		{
-			ID: testhelper.MkID("bad parts"),
-			ExpErr: testhelper.MkExpErr(`"` + badPart + `" is` +
-				` not a valid pre-defined part of a snippet`),
-			parts: []string{badPart},
+			ID:    testhelper.MkID("by tag"),
+			order: snippet.SortByTagPrefix + "Order",
+		},
+		{
+			ID:     testhelper.MkID("by tag, no tag name"),
+			ExpErr: testhelper.MkExpErr(`bad sort order: "tag:"`),
+			order:  snippet.SortByTagPrefix,
+		},
+		{
+			ID:     testhelper.MkID("unknown order"),
+			ExpErr: testhelper.MkExpErr(`bad sort order: "size"`),
+			order:  "size",
 		},
 	}
 
 	for _, tc := range testCases {
 		_, err := snippet.NewListCfg(nil, nil, nil,
-			snippet.SetParts(tc.parts...))
+			snippet.SetSortBy(tc.order))
 		testhelper.CheckExpErr(t, err, tc)
 	}
 }
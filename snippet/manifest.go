@@ -0,0 +1,60 @@
+package snippet
+
+import (
+	"time"
+)
+
+// ManifestEntry records the details of a snippet file needed to tell if it
+// has changed. The ContentHash is as given by the ContentHash func applied
+// to the content of the file, decompressed if the file is compressed.
+type ManifestEntry struct {
+	Path        string
+	Size        int64
+	ModTime     time.Time
+	ContentHash string
+}
+
+// Manifest reads every snippet file in the snippet directories and returns
+// a map from the snippet name to the details of the file. The snippet
+// files are found exactly as when listing the snippets, with the same
+// options, so ignored files (see SetIgnorePatterns and IgnoreFileName) are
+// left out and a snippet in an earlier directory eclipses any snippet of
+// the same name in a later one; only the eclipsing snippet is given.
+// Snippet directories which do not exist are ignored unless
+// RequireDirsExist is set. The content of the files is not parsed. Any
+// problems finding or reading the files are returned as errors.
+func Manifest(dirs []string, opts ...ListCfgOptFunc,
+) (map[string]ManifestEntry, []error) {
+	manifest := map[string]ManifestEntry{}
+
+	lc, errs := findSnippetFiles(dirs, opts...)
+	if lc == nil {
+		return manifest, errs
+	}
+
+	for _, sf := range lc.pending {
+		if _, eclipsed := manifest[sf.sName]; eclipsed {
+			continue
+		}
+
+		info, err := statFile(lc.fsys, sf.fName)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		content, err := readSnippetContent(lc.fsys, sf.fName)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		manifest[sf.sName] = ManifestEntry{
+			Path:        sf.fName,
+			Size:        info.Size(),
+			ModTime:     info.ModTime(),
+			ContentHash: ContentHash(content),
+		}
+	}
+
+	return manifest, errs
+}
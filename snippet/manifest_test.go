@@ -0,0 +1,92 @@
+package snippet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+func TestManifest(t *testing.T) {
+	tmp := t.TempDir()
+	first := filepath.Join(tmp, "first")
+	second := filepath.Join(tmp, "second")
+	missing := filepath.Join(tmp, "missing")
+
+	writeFile(t, filepath.Join(first, "a"), "a()\n")
+	writeFile(t, filepath.Join(first, "sub", "b"), "b()\n")
+	writeFile(t, filepath.Join(second, "a"), "eclipsed()\n")
+	writeFile(t, filepath.Join(second, "c"), "c := 1\n")
+
+	mTime := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(second, "c"), mTime, mTime); err != nil {
+		t.Fatal("cannot set the file times: ", err)
+	}
+
+	manifest, errs := Manifest([]string{first, missing, second})
+	if len(errs) != 0 {
+		t.Fatal("unexpected errors: ", errs)
+	}
+
+	expNames := []string{"a", "c", filepath.Join("sub", "b")}
+	names := []string{}
+	for name := range manifest {
+		names = append(names, name)
+	}
+	testhelper.DiffStringSlice(t, "manifest", "names",
+		tidySlice(names), expNames)
+
+	a := manifest["a"]
+	testhelper.DiffString(t, "eclipsing snippet", "path",
+		a.Path, filepath.Join(first, "a"))
+	testhelper.DiffString(t, "eclipsing snippet", "content hash",
+		a.ContentHash, ContentHash([]byte("a()\n")))
+
+	c := manifest["c"]
+	testhelper.DiffInt(t, "snippet c", "size", c.Size, int64(len("c := 1\n")))
+	if !c.ModTime.Equal(mTime) {
+		t.Errorf("snippet c: mod time: got %s, want %s", c.ModTime, mTime)
+	}
+}
+
+func TestManifestMatchesListing(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, IgnoreFileName), "x\n")
+	writeFile(t, filepath.Join(dir, "x"), "x()\n")
+	writeFile(t, filepath.Join(dir, "y"), "y()\n")
+	writeFile(t, filepath.Join(dir, "y~"), "backup()\n")
+
+	manifest, errs := Manifest([]string{dir})
+	if len(errs) != 0 {
+		t.Fatal("unexpected errors: ", errs)
+	}
+	names := []string{}
+	for name := range manifest {
+		names = append(names, name)
+	}
+	testhelper.DiffStringSlice(t, "ignored files", "names",
+		tidySlice(names), []string{"y"})
+
+	fsys := fstest.MapFS{
+		"lib/hw":  {Data: []byte("hw()\n")},
+		"lib/.hw": {Data: []byte("hidden()\n")},
+	}
+	manifest, errs = Manifest([]string{"lib"}, SetFS(fsys))
+	if len(errs) != 0 {
+		t.Fatal("unexpected errors: ", errs)
+	}
+	names = []string{}
+	for name := range manifest {
+		names = append(names, name)
+	}
+	testhelper.DiffStringSlice(t, "fs.FS", "names", names, []string{"hw"})
+	testhelper.DiffString(t, "fs.FS", "path",
+		manifest["hw"].Path, filepath.Join("lib", "hw"))
+
+	_, errs = Manifest([]string{filepath.Join(dir, "missing")},
+		RequireDirsExist(true))
+	testhelper.DiffInt(t, "required missing dir", "errors", len(errs), 1)
+}
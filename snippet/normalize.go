@@ -0,0 +1,73 @@
+package snippet
+
+import (
+	"bytes"
+	"fmt"
+	"unicode"
+)
+
+// DuplicateNormalization describes the changes made to the content of a
+// snippet file before it is compared with the content of the other snippet
+// files when looking for duplicate snippets.
+type DuplicateNormalization int
+
+const (
+	// NormalizeNothing compares the content of the snippet files exactly
+	// as it is read.
+	NormalizeNothing DuplicateNormalization = iota
+	// NormalizeWhiteSpace compares the content after removing any leading
+	// byte order mark, changing all line endings (CRLF or a lone CR) to
+	// a newline, removing any trailing white space from each line and
+	// removing any blank lines at the end of the content.
+	NormalizeWhiteSpace
+	// NormalizeCodeOnly makes the same changes as NormalizeWhiteSpace and
+	// then removes every line which is a snippet semantic comment (one
+	// starting with "// snippet:") so that only the snippet text is
+	// compared. Any other comments are kept.
+	NormalizeCodeOnly
+)
+
+// String returns a description of the DuplicateNormalization
+func (dn DuplicateNormalization) String() string {
+	switch dn {
+	case NormalizeNothing:
+		return "nothing"
+	case NormalizeWhiteSpace:
+		return "white space"
+	case NormalizeCodeOnly:
+		return "code only"
+	}
+	return fmt.Sprintf("unknown normalization (%d)", int(dn))
+}
+
+// isValid returns true if the DuplicateNormalization is one of the known
+// values
+func (dn DuplicateNormalization) isValid() bool {
+	return dn >= NormalizeNothing && dn <= NormalizeCodeOnly
+}
+
+// normalize returns the content changed as described for the
+// DuplicateNormalization.
+func (dn DuplicateNormalization) normalize(content []byte) []byte {
+	if dn == NormalizeNothing {
+		return content
+	}
+
+	content = bytes.TrimPrefix(content, utf8BOM)
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	content = bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+
+	lines := bytes.Split(content, []byte("\n"))
+	kept := make([][]byte, 0, len(lines))
+	for _, l := range lines {
+		if dn == NormalizeCodeOnly && commentRE.Match(l) {
+			continue
+		}
+		kept = append(kept, bytes.TrimRightFunc(l, unicode.IsSpace))
+	}
+	for len(kept) > 0 && len(kept[len(kept)-1]) == 0 {
+		kept = kept[:len(kept)-1]
+	}
+
+	return bytes.Join(kept, []byte("\n"))
+}
@@ -0,0 +1,51 @@
+package snippet
+
+import (
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+func TestNormalize(t *testing.T) {
+	content := "\xef\xbb\xbf// snippet: note: a note  \r\n" +
+		"a() \t\r\n" +
+		"\r\n" +
+		"// a comment\r" +
+		"b()\n" +
+		"  \n" +
+		"\n"
+
+	testCases := []struct {
+		testhelper.ID
+		dn     DuplicateNormalization
+		expVal string
+	}{
+		{
+			ID:     testhelper.MkID("nothing"),
+			dn:     NormalizeNothing,
+			expVal: content,
+		},
+		{
+			ID: testhelper.MkID("white space"),
+			dn: NormalizeWhiteSpace,
+			expVal: "// snippet: note: a note\n" +
+				"a()\n" +
+				"\n" +
+				"// a comment\n" +
+				"b()",
+		},
+		{
+			ID: testhelper.MkID("code only"),
+			dn: NormalizeCodeOnly,
+			expVal: "a()\n" +
+				"\n" +
+				"// a comment\n" +
+				"b()",
+		},
+	}
+
+	for _, tc := range testCases {
+		testhelper.DiffString(t, tc.IDStr(), "normalized",
+			string(tc.dn.normalize([]byte(content))), tc.expVal)
+	}
+}
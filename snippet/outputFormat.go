@@ -0,0 +1,289 @@
+package snippet
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OutputFormat gives the form in which the snippets are listed
+type OutputFormat int
+
+const (
+	// FormatText lists the snippets as human-readable text
+	FormatText OutputFormat = iota
+	// FormatJSON lists the snippets as a JSON array of objects, one per
+	// snippet
+	FormatJSON
+	// FormatMarkdown lists the snippets as a Markdown document suitable
+	// for publishing as documentation
+	FormatMarkdown
+)
+
+// String returns a description of the OutputFormat
+func (f OutputFormat) String() string {
+	switch f {
+	case FormatText:
+		return "text"
+	case FormatJSON:
+		return "json"
+	case FormatMarkdown:
+		return "markdown"
+	}
+	return fmt.Sprintf("unknown output format (%d)", int(f))
+}
+
+// isValid returns true if the OutputFormat is one of the known formats
+func (f OutputFormat) isValid() bool {
+	return f >= FormatText && f <= FormatMarkdown
+}
+
+// jsonKeyTags is the key of the tags in the JSON form of a snippet
+const jsonKeyTags = "tags"
+
+// snippetToJSONMap returns a map representing the snippet which, when
+// encoded as JSON, gives the parts of the snippet to be shown according to
+// the formatCfg. The keys are the names of the parts (see ValidParts)
+// except for the tags which are given as a single object keyed by the tag
+// name. The name is always given. If no parts or tags have been chosen the
+// path, notes, imports, expects, follows and all the tags are given
+// together with any other parts which have a value. Unlike the text form,
+// the expects include the snippets which are followed and the deprecated
+// part is only given, as the deprecation message, if the snippet is
+// deprecated.
+func (fc *formatCfg) snippetToJSONMap(s *S) map[string]interface{} {
+	all := len(fc.parts) == 0 && len(fc.tags) == 0
+	m := map[string]interface{}{NamePart: s.name}
+
+	addPart := func(part string, val interface{}, hasVal bool) {
+		if fc.parts[part] || (all && hasVal) {
+			m[part] = val
+		}
+	}
+	addPart(PathPart, s.path, true)
+	addPart(ModTimePart, modTimeString(s.modTime), !s.modTime.IsZero())
+	addPart(DocsPart, jsonStrings(s.docs), true)
+	addPart(ImportPart, jsonStrings(s.imports), true)
+	addPart(ExpectPart, jsonStrings(s.expects), true)
+	addPart(FollowPart, jsonStrings(s.follows), true)
+	addPart(SeeAlsoPart, jsonStrings(s.seeAlso), len(s.seeAlso) > 0)
+	addPart(IncludePart, s.Includes(), len(s.includes) > 0)
+	addPart(StatusPart, s.status, s.status != "")
+	addPart(SincePart, s.since, s.since != "")
+	if isDeprecated, msg := s.Deprecated(); isDeprecated {
+		addPart(DeprecatedPart, msg, true)
+	}
+	for _, cp := range customParts {
+		addPart(cp, jsonStrings(s.custom[cp]), len(s.custom[cp]) > 0)
+	}
+	addPart(TextPart, jsonStrings(s.text), false)
+
+	if all || fc.parts[TagPart] || len(fc.tags) > 0 {
+		tags := map[string][]string{}
+		for k, v := range s.tags {
+			if all || fc.parts[TagPart] || fc.tags[k] {
+				tags[k] = jsonStrings(v)
+			}
+		}
+		m[jsonKeyTags] = tags
+	}
+
+	return m
+}
+
+// modTimeString returns the modification time in RFC 3339 format or the
+// empty string if the time is not known
+func modTimeString(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// jsonStrings returns a copy of the strings. It is never nil so that it
+// is encoded as an empty JSON array rather than as null.
+func jsonStrings(strs []string) []string {
+	return append([]string{}, strs...)
+}
+
+// showJSON writes the snippets to be shown as a JSON array
+func (lc *ListCfg) showJSON() {
+	snippets := []map[string]interface{}{}
+groups:
+	for _, g := range lc.groups {
+		for _, s := range g.snippets {
+			if lc.limit > 0 && len(snippets) == lc.limit {
+				break groups
+			}
+			snippets = append(snippets, lc.formatCfg.snippetToJSONMap(s))
+		}
+	}
+
+	b, err := json.MarshalIndent(snippets, "", "  ")
+	if err != nil {
+		lc.errs.AddError("Cannot write the snippets as JSON", err)
+		return
+	}
+	fmt.Fprintln(lc.StdW(), string(b))
+}
+
+// snippetToMarkdown returns a Markdown section showing the parts of the
+// snippet to be shown according to the formatCfg. The name is given as a
+// heading, the notes as text, the text of the snippet as a fenced Go code
+// block and the other parts as bulleted lists. If no parts or tags have
+// been chosen then all the parts which have a value are shown, including
+// the snippet text.
+func (fc *formatCfg) snippetToMarkdown(s *S) string {
+	all := len(fc.parts) == 0 && len(fc.tags) == 0
+	show := func(part string, hasVal bool) bool {
+		return fc.parts[part] || (all && hasVal)
+	}
+
+	var b strings.Builder
+
+	name := s.name
+	isDeprecated, deprecatedMsg := s.Deprecated()
+	if isDeprecated {
+		name += " " + DeprecatedMarker
+	}
+	b.WriteString("### " + name + "\n")
+
+	if show(PathPart, false) {
+		b.WriteString("\n*" + s.path + "*\n")
+	}
+	if show(ModTimePart, false) {
+		b.WriteString("\n**Modified:** " + modTimeString(s.modTime) + "\n")
+	}
+	if show(DocsPart, len(s.docs) > 0) && len(s.docs) > 0 {
+		b.WriteString("\n" + strings.Join(s.docs, "\n") + "\n")
+	}
+	if isDeprecated && show(DeprecatedPart, deprecatedMsg != "") {
+		b.WriteString("\n**Deprecated:** " + deprecatedMsg + "\n")
+	}
+
+	expects := []string{}
+	for _, e := range s.expects {
+		if !containsString(s.follows, e) {
+			expects = append(expects, e)
+		}
+	}
+	for _, p := range []struct {
+		part   string
+		intro  string
+		values []string
+	}{
+		{ImportPart, "Imports", s.imports},
+		{FollowPart, "Follows", fc.annotated(s.follows)},
+		{ExpectPart, "Expects", fc.annotated(expects)},
+		{SeeAlsoPart, "See also", s.seeAlso},
+		{IncludePart, "Includes", s.Includes()},
+	} {
+		if show(p.part, len(p.values) > 0) {
+			writeMarkdownList(&b, p.intro, p.values, "`")
+		}
+	}
+	if show(StatusPart, s.status != "") {
+		b.WriteString("\n**Status:** " + s.status + "\n")
+	}
+	if show(SincePart, s.since != "") {
+		b.WriteString("\n**Since:** Go " + s.since + "\n")
+	}
+	for _, cp := range customParts {
+		if show(cp, len(s.custom[cp]) > 0) {
+			writeMarkdownList(&b, cp, s.custom[cp], "")
+		}
+	}
+
+	tags := []string{}
+	for _, k := range getTagKeys(s) {
+		if all || fc.parts[TagPart] || fc.tags[k] {
+			for _, v := range s.tags[k] {
+				if v == "" {
+					tags = append(tags, k)
+					continue
+				}
+				tags = append(tags, k+": "+v)
+			}
+		}
+	}
+	if len(tags) > 0 {
+		writeMarkdownList(&b, "Tags", tags, "")
+	}
+
+	if show(TextPart, len(s.text) > 0) {
+		fence := markdownFence(s.text)
+		b.WriteString("\n" + fence + "go\n")
+		b.WriteString(s.TextString())
+		b.WriteString(fence + "\n")
+	}
+
+	return b.String()
+}
+
+// writeMarkdownList writes the values as a Markdown bulleted list introduced
+// by the intro in bold. Each value is surrounded by the quote string.
+func writeMarkdownList(b *strings.Builder, intro string, values []string,
+	quote string,
+) {
+	b.WriteString("\n**" + intro + ":**\n\n")
+	if len(values) == 0 {
+		b.WriteString("- none\n")
+	}
+	for _, v := range values {
+		b.WriteString("- " + quote + v + quote + "\n")
+	}
+}
+
+// markdownFence returns a code fence long enough that it will not be
+// mistaken for any run of backquotes in the text.
+func markdownFence(text []string) string {
+	longest := 0
+	for _, l := range text {
+		run := 0
+		for _, r := range l {
+			if r != '`' {
+				run = 0
+				continue
+			}
+			run++
+			if run > longest {
+				longest = run
+			}
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// showMarkdown writes the snippets to be shown as a Markdown document. Each
+// directory the snippets were found in starts a new section unless the
+// intro is hidden.
+func (lc *ListCfg) showMarkdown() {
+	count := 0
+	for _, g := range lc.groups {
+		for i, s := range g.snippets {
+			if lc.limit > 0 && count == lc.limit {
+				lc.writeLines(
+					fmt.Sprintf("\n*(showing first %d matches)*\n", lc.limit))
+				return
+			}
+
+			text := lc.formatCfg.snippetToMarkdown(s)
+			if i == 0 && g.dir != "" && !lc.hideIntro {
+				text = "## " + g.dir + "\n\n" + text
+			}
+			if count > 0 {
+				text = "\n" + text
+			}
+			count++
+
+			if !lc.writeLines(text) {
+				fmt.Fprint(lc.StdW(), "... (output truncated)\n")
+				return
+			}
+		}
+	}
+}
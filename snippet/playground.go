@@ -0,0 +1,144 @@
+package snippet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	// playgroundShareURL is the URL to which programs are sent to be shared
+	playgroundShareURL = "https://play.golang.org/share"
+	// playgroundViewURL is the prefix of the URL for viewing a shared
+	// program; the identifier returned by the share URL is appended
+	playgroundViewURL = "https://go.dev/play/p/"
+)
+
+// goImportSpec returns the import as it would appear in a Go import
+// declaration, with the path quoted and any alias preceding it.
+func goImportSpec(imp string) string {
+	fields := strings.Fields(imp)
+	if len(fields) == 0 {
+		return ""
+	}
+	spec := `"` + importPath(imp) + `"`
+	if len(fields) > 1 {
+		spec = fields[0] + " " + spec
+	}
+	return spec
+}
+
+// goImportDecl returns an import declaration for the snippet imports. If
+// the snippet has no imports an empty string is returned.
+func (s S) goImportDecl() string {
+	if len(s.imports) == 0 {
+		return ""
+	}
+	decl := "import (\n"
+	for _, imp := range s.imports {
+		decl += "\t" + goImportSpec(imp) + "\n"
+	}
+	return decl + ")\n\n"
+}
+
+// hasMainFunc returns true if the snippet text declares a main function
+func (s S) hasMainFunc() bool {
+	f, err := s.parseText()
+	if err != nil {
+		return false
+	}
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok &&
+			fd.Recv == nil && fd.Name.Name == "main" {
+			return true
+		}
+	}
+	return false
+}
+
+// program returns the snippet as a runnable Go program. Statements and
+// expressions are placed in the body of a main function; declarations are
+// used only if they include a main function and a whole file is used only
+// if it is in package main. The declared imports are added unless the text
+// is a whole file. An error is returned if the snippet cannot be made into
+// a program.
+func (s S) program() (string, error) {
+	text := s.TextString()
+	prog := ""
+
+	switch s.Kind() {
+	case KindWholeFile:
+		f, _ := s.parseText()
+		if f.Name.Name != "main" {
+			return "", fmt.Errorf(
+				"snippet %q is a file in package %q, not package main",
+				s.name, f.Name.Name)
+		}
+		if !s.hasMainFunc() {
+			return "", fmt.Errorf(
+				"snippet %q is a file with no main function", s.name)
+		}
+		prog = text
+	case KindDeclarations:
+		if !s.hasMainFunc() {
+			return "", fmt.Errorf(
+				"snippet %q has declarations but no main function", s.name)
+		}
+		prog = "package main\n\n" + s.goImportDecl() + text
+	case KindStatements, KindExpression:
+		prog = "package main\n\n" + s.goImportDecl() +
+			"func main() {\n" + text + "}\n"
+	default:
+		return "", fmt.Errorf("snippet %q is not valid Go code", s.name)
+	}
+
+	if formatted, err := format.Source([]byte(prog)); err == nil {
+		prog = string(formatted)
+	}
+	return prog, nil
+}
+
+// PlaygroundURL makes the snippet into a runnable program, shares it on
+// the Go playground and returns the URL where it can be found. See the
+// program method for how the program is made. An error is returned if the
+// snippet cannot be made into a program or the playground cannot be
+// reached.
+func (s S) PlaygroundURL(ctx context.Context) (string, error) {
+	prog, err := s.program()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		playgroundShareURL, strings.NewReader(prog))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot share snippet %q: %w", s.name, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("cannot share snippet %q: %w", s.name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cannot share snippet %q: %s: %s",
+			s.name, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	id := strings.TrimSpace(string(body))
+	if id == "" {
+		return "", errors.New("the playground returned an empty identifier")
+	}
+	return playgroundViewURL + id, nil
+}
@@ -0,0 +1,110 @@
+package snippet
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+func TestProgram(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		s       S
+		expProg string
+	}{
+		{
+			ID: testhelper.MkID("statements"),
+			s: S{
+				name:    "stmts",
+				imports: []string{"fmt", `str "strings"`},
+				text:    []string{`x := str.ToUpper("a")`, "fmt.Println(x)"},
+			},
+			expProg: `package main
+
+import (
+	"fmt"
+	str "strings"
+)
+
+func main() {
+	x := str.ToUpper("a")
+	fmt.Println(x)
+}
+`,
+		},
+		{
+			ID: testhelper.MkID("declarations with main"),
+			s: S{
+				name: "decls",
+				text: []string{"func main() {", "}"},
+			},
+			expProg: "package main\n\nfunc main() {\n}\n",
+		},
+		{
+			ID: testhelper.MkID("declarations without main"),
+			ExpErr: testhelper.MkExpErr(
+				`snippet "decls" has declarations but no main function`),
+			s: S{
+				name: "decls",
+				text: []string{"func f() {}"},
+			},
+		},
+		{
+			ID: testhelper.MkID("whole file, not main"),
+			ExpErr: testhelper.MkExpErr(
+				`snippet "file" is a file in package "xxx", not package main`),
+			s: S{
+				name: "file",
+				text: []string{"package xxx"},
+			},
+		},
+		{
+			ID: testhelper.MkID("not Go"),
+			ExpErr: testhelper.MkExpErr(
+				`snippet "bad" is not valid Go code`),
+			s: S{
+				name: "bad",
+				text: []string{"contents of snip1"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		prog, err := tc.s.program()
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffString(t, tc.IDStr(), "program", prog, tc.expProg)
+		}
+	}
+}
+
+func TestPlaygroundURL(t *testing.T) {
+	var received string
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received = string(body)
+			_, _ = io.WriteString(w, "abc123")
+		}))
+	defer srv.Close()
+
+	defer func(share, view string) {
+		playgroundShareURL, playgroundViewURL = share, view
+	}(playgroundShareURL, playgroundViewURL)
+	playgroundShareURL = srv.URL
+	playgroundViewURL = "https://example.com/p/"
+
+	s := S{name: "hw", text: []string{`println("Hello")`}}
+	url, err := s.PlaygroundURL(context.Background())
+	if err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+	testhelper.DiffString(t, "playground", "url",
+		url, "https://example.com/p/abc123")
+	testhelper.DiffString(t, "playground", "program",
+		received, "package main\n\nfunc main() {\n\tprintln(\"Hello\")\n}\n")
+}
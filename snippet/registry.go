@@ -0,0 +1,101 @@
+package snippet
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// RegistryCommentStr starts a comment line in a registry file
+const RegistryCommentStr = "#"
+
+// Registry maps short aliases to the names of snippets. It allows
+// frequently used snippets to be referred to by a memorable name without
+// changing the snippet files. The zero value is an empty Registry ready to
+// use.
+type Registry struct {
+	aliases map[string]string
+}
+
+// LoadRegistry reads the registry file and returns the Registry it
+// describes. Each line of the file gives an alias and the name of the
+// snippet it refers to, separated by an equals sign, as in:
+//
+//	retry = net/http/retryLoop
+//
+// Blank lines and lines starting with RegistryCommentStr are ignored. An
+// error is returned if the file cannot be read, if any line is badly
+// formed or if an alias is given for more than one snippet.
+func LoadRegistry(path string) (Registry, error) {
+	r := Registry{aliases: map[string]string{}}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Registry{}, err
+	}
+	defer f.Close()
+
+	lineNum := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineNum++
+		l := strings.TrimSpace(scanner.Text())
+		if l == "" || strings.HasPrefix(l, RegistryCommentStr) {
+			continue
+		}
+
+		parts := strings.SplitN(l, "=", 2)
+		if len(parts) != 2 {
+			return Registry{},
+				fmt.Errorf("%s:%d: missing '=' in the registry entry: %q",
+					path, lineNum, l)
+		}
+		alias := strings.TrimSpace(parts[0])
+		sName := strings.TrimSpace(parts[1])
+		if alias == "" || sName == "" {
+			return Registry{},
+				fmt.Errorf("%s:%d: the alias and the snippet name"+
+					" must both be given: %q",
+					path, lineNum, l)
+		}
+
+		if other, ok := r.aliases[alias]; ok && other != sName {
+			return Registry{},
+				fmt.Errorf("%s:%d: the alias %q is given for both %q and %q",
+					path, lineNum, alias, other, sName)
+		}
+		r.aliases[alias] = sName
+	}
+	if err := scanner.Err(); err != nil {
+		return Registry{}, err
+	}
+
+	return r, nil
+}
+
+// Resolve returns the name of the snippet that the alias refers to. If the
+// name is not an alias in the Registry it is returned unchanged.
+func (r Registry) Resolve(name string) string {
+	if sName, ok := r.aliases[name]; ok {
+		return sName
+	}
+	return name
+}
+
+// IsAlias returns true if the name is an alias in the Registry.
+func (r Registry) IsAlias(name string) bool {
+	_, ok := r.aliases[name]
+	return ok
+}
+
+// Aliases returns the aliases in the Registry in alphabetical order.
+func (r Registry) Aliases() []string {
+	aliases := make([]string, 0, len(r.aliases))
+	for a := range r.aliases {
+		aliases = append(aliases, a)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
@@ -0,0 +1,127 @@
+package snippet
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+func TestLoadRegistry(t *testing.T) {
+	tmp := t.TempDir()
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		content    string
+		expAliases []string
+		alias      string
+		expName    string
+	}{
+		{
+			ID:         testhelper.MkID("empty"),
+			expAliases: []string{},
+			alias:      "x",
+			expName:    "x",
+		},
+		{
+			ID: testhelper.MkID("good"),
+			content: "# comment\n" +
+				"\n" +
+				"retry = net/http/retryLoop\n" +
+				"  hw=hello/world  \n" +
+				"retry = net/http/retryLoop\n",
+			expAliases: []string{"hw", "retry"},
+			alias:      "hw",
+			expName:    "hello/world",
+		},
+		{
+			ID:      testhelper.MkID("conflict"),
+			content: "retry = net/retry\nretry = io/retry\n",
+			ExpErr: testhelper.MkExpErr(`:2: the alias "retry"` +
+				` is given for both "net/retry" and "io/retry"`),
+		},
+		{
+			ID:      testhelper.MkID("missing equals"),
+			content: "retry net/retry\n",
+			ExpErr: testhelper.MkExpErr(
+				`:1: missing '=' in the registry entry: "retry net/retry"`),
+		},
+		{
+			ID:      testhelper.MkID("missing name"),
+			content: "# comment\nretry = \n",
+			ExpErr: testhelper.MkExpErr(
+				`:2: the alias and the snippet name must both be given`),
+		},
+	}
+
+	for i, tc := range testCases {
+		path := filepath.Join(tmp, "registry"+string(rune('a'+i)))
+		writeFile(t, path, tc.content)
+
+		r, err := LoadRegistry(path)
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffStringSlice(t, tc.IDStr(), "aliases",
+				r.Aliases(), tc.expAliases)
+			testhelper.DiffString(t, tc.IDStr(), "resolved name",
+				r.Resolve(tc.alias), tc.expName)
+		}
+	}
+}
+
+func TestLoadRegistryMissingFile(t *testing.T) {
+	_, err := LoadRegistry(filepath.Join(t.TempDir(), "nonesuch"))
+	if err == nil {
+		t.Error("an error was expected for a missing registry file")
+	}
+}
+
+func TestCacheGetAlias(t *testing.T) {
+	r, err := LoadRegistry(filepath.Join("testdata", "aliases.registry"))
+	if err != nil {
+		t.Fatal("cannot load the registry: ", err)
+	}
+
+	sc := Cache{}
+	sc.SetRegistry(r)
+	dirs := []string{filepath.Join("testdata", "testListConfig")}
+	if _, err := sc.Add(dirs, "snip1"); err != nil {
+		t.Fatal("cannot add the snippet: ", err)
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		name    string
+		expName string
+	}{
+		{
+			ID:      testhelper.MkID("real name"),
+			name:    "snip1",
+			expName: "snip1",
+		},
+		{
+			ID:      testhelper.MkID("alias"),
+			name:    "s1",
+			expName: "snip1",
+		},
+		{
+			ID:   testhelper.MkID("alias for a missing snippet"),
+			name: "gone",
+			ExpErr: testhelper.MkExpErr(`"gone" is an alias for "nonesuch"` +
+				` which is not in the snippet cache`),
+		},
+		{
+			ID:     testhelper.MkID("unknown name"),
+			name:   "s2",
+			ExpErr: testhelper.MkExpErr(`"s2" is not in the snippet cache`),
+		},
+	}
+
+	for _, tc := range testCases {
+		s, err := sc.Get(tc.name)
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffString(t, tc.IDStr(), "name", s.Name(), tc.expName)
+		}
+	}
+}
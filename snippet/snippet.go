@@ -3,34 +3,59 @@ package snippet
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"os"
+	"io"
+	"io/fs"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 const (
 	// these are named parts of the snippet for listing
-	NamePart = "name"
-	PathPart = "path"
-	TextPart = "text"
+	NamePart    = "name"
+	PathPart    = "path"
+	TextPart    = "text"
+	ModTimePart = "modtime"
 
-	DocsPart   = "note"
-	ImportPart = "imports"
-	ExpectPart = "expects"
-	FollowPart = "follows"
-	TagPart    = "tag"
+	DocsPart       = "note"
+	ImportPart     = "imports"
+	ExpectPart     = "expects"
+	FollowPart     = "follows"
+	TagPart        = "tag"
+	SeeAlsoPart    = "seealso"
+	StatusPart     = "status"
+	SincePart      = "since"
+	DeprecatedPart = "deprecated"
+	IncludePart    = "include"
 
 	// these correspond to semantic comments in the snippet
-	CommentStr = "snippet:"
-	NoteStr    = DocsPart + ":"
-	ImportStr  = ImportPart + ":"
-	ExpectStr  = ExpectPart + ":"
-	AfterStr   = FollowPart + ":"
-	TagStr     = TagPart + ":"
+	CommentStr    = "snippet:"
+	NoteStr       = DocsPart + ":"
+	ImportStr     = ImportPart + ":"
+	ExpectStr     = ExpectPart + ":"
+	AfterStr      = FollowPart + ":"
+	TagStr        = TagPart + ":"
+	SeeAlsoStr    = SeeAlsoPart + ":"
+	StatusStr     = StatusPart + ":"
+	SinceStr      = SincePart + ":"
+	DeprecatedStr = DeprecatedPart + ":"
+	IncludeStr    = IncludePart + ":"
+
+	// these mark the start and end of the part of the snippet file to be
+	// used as the snippet text
+	BeginMarker = "begin"
+	EndMarker   = "end"
+	// this marks lines to be left out of the snippet text
+	SkipMarker = "skip"
 
 	// Regexp - note that this is case-blind because of the leading "(?i)"
 	commentREStr = `^(?i)\s*//\s*` + CommentStr
@@ -42,14 +67,21 @@ var snippetParts = []string{
 	ExpectPart,
 	FollowPart,
 	TagPart,
+	SeeAlsoPart,
+	StatusPart,
+	SincePart,
+	DeprecatedPart,
+	IncludePart,
 }
 
 var altPartNames = map[string][]string{
-	DocsPart:   {"notes", "doc", "docs"},
-	ImportPart: {"import"},
-	ExpectPart: {"expect", "comesbefore"},
-	FollowPart: {"follow", "comesafter"},
-	TagPart:    {"tags"},
+	DocsPart:    {"notes", "doc", "docs"},
+	ImportPart:  {"import"},
+	ExpectPart:  {"expect", "comesbefore"},
+	FollowPart:  {"follow", "comesafter"},
+	TagPart:     {"tags"},
+	SeeAlsoPart: {"see-also"},
+	IncludePart: {"includes"},
 }
 
 // AltPartNames returns a slice of alternative names for the given part. Note
@@ -59,14 +91,45 @@ func AltPartNames(part string) []string {
 }
 
 var validParts = map[string]string{
-	NamePart:   "the snippet name",
-	PathPart:   "the name of the snippet file",
-	TextPart:   "the snippet code to be used",
-	DocsPart:   "how the snippet should be used",
-	ExpectPart: "snippets used with this",
-	ImportPart: "packages this snippet imports",
-	FollowPart: "snippets coming before this",
-	TagPart:    "colon-separated name/value pairs",
+	NamePart:       "the snippet name",
+	PathPart:       "the name of the snippet file",
+	TextPart:       "the snippet code to be used",
+	ModTimePart:    "when the snippet file was last modified",
+	DocsPart:       "how the snippet should be used",
+	ExpectPart:     "snippets used with this",
+	ImportPart:     "packages this snippet imports",
+	FollowPart:     "snippets coming before this",
+	TagPart:        "colon-separated name/value pairs",
+	SeeAlsoPart:    "related snippets, not needed with this",
+	StatusPart:     "the maturity of the snippet",
+	SincePart:      "the minimum Go version needed",
+	DeprecatedPart: "why the snippet should no longer be used",
+	IncludePart:    "snippets whose text is inserted in this",
+}
+
+// These are the allowed values of the snippet status
+const (
+	StatusExperimental = "experimental"
+	StatusStable       = "stable"
+	StatusDeprecated   = "deprecated"
+)
+
+var validStatuses = map[string]string{
+	StatusExperimental: "the snippet may change or be removed",
+	StatusStable:       "the snippet is ready for general use",
+	StatusDeprecated:   "the snippet should no longer be used",
+}
+
+// ValidStatuses returns a map which has an entry for all the valid values
+// of the snippet status with a brief description of the status.
+func ValidStatuses() map[string]string {
+	rval := make(map[string]string)
+
+	for k, v := range validStatuses {
+		rval[k] = v
+	}
+
+	return rval
 }
 
 // ValidParts returns a map which has an entry for all the valid parts of a
@@ -83,6 +146,26 @@ func ValidParts() map[string]string {
 
 var commentRE = regexp.MustCompile(commentREStr)
 
+// beginRE and endRE match the comments marking the start and end of the
+// snippet text
+var (
+	beginRE = regexp.MustCompile(commentREStr + `\s*` + BeginMarker + `\s*$`)
+	endRE   = regexp.MustCompile(commentREStr + `\s*` + EndMarker + `\s*$`)
+)
+
+// skipRE matches the comment marking lines to be left out of the snippet
+// text, the count of lines, if any, is the first submatch
+var skipRE = regexp.MustCompile(commentREStr +
+	`\s*` + SkipMarker + `\s*(?::\s*(.*?))?\s*$`)
+
+// docLinkRE matches text in a snippet note which looks like a reference to
+// another snippet: a back-quoted word made up of letters, digits,
+// underscores, dashes and slashes. Note that back-quoted text containing
+// dots, spaces, brackets or operators is taken to be Go code rather than a
+// snippet name; this means that a snippet whose name contains a dot will
+// not be found.
+var docLinkRE = regexp.MustCompile("`([A-Za-z0-9_/-]+)`")
+
 var snippetPartREs = map[string]*regexp.Regexp{}
 
 // altNames returns a fragment of a regular expression which represents the
@@ -110,16 +193,95 @@ func init() {
 type S struct {
 	name    string
 	path    string
+	dir     string
 	text    []string
 	docs    []string
 	expects []string
 	imports []string
 	follows []string
+	seeAlso []string
+	status  string
+	since   string
 	tags    map[string][]string
+	// bareTags counts, for each tag, the number of times it was given with
+	// no value (with no colon after the tag name)
+	bareTags map[string]int
+	// deprecated is set if the snippet has a deprecated comment and
+	// deprecatedMsg holds the text of any such comments
+	deprecated    bool
+	deprecatedMsg string
+	// custom holds the values of the parts added by RegisterPart
+	custom map[string][]string
+	// includes records the snippets whose text is to be inserted into the
+	// text of this snippet, in the order given
+	includes []include
+
+	// modTime is the modification time of the snippet file. It is the
+	// zero time if the file was not read from the operating system's file
+	// system
+	modTime time.Time
+	// contentHash is the hash of the content of the snippet file
+	contentHash [md5.Size]byte
+	// textOffset is the byte offset in the snippet file of the first line
+	// of text or -1 if there is no text
+	textOffset int
+	// parseWarnings records any problems found while parsing the snippet
+	// which did not prevent it from being used
+	parseWarnings []error
+}
+
+// include records a snippet whose text is to be inserted into the text of
+// another snippet
+type include struct {
+	name string
+	// line is the index of the line in the text before which the included
+	// text is inserted; if it is the length of the text then the included
+	// text is added at the end
+	line int
+}
+
+// matchCfg holds the configuration for comparing snippets
+type matchCfg struct {
+	// maxDiffs is the maximum number of differing entries to show when
+	// comparing lists of values. If it is zero all the differences are
+	// shown.
+	maxDiffs int
+}
+
+// dfltMaxDiffs is the default number of differing entries to show
+const dfltMaxDiffs = 1
+
+// MatchOpt is the type of an option to the Matches method
+type MatchOpt func(*matchCfg)
+
+// MaxDiffs returns a MatchOpt which will set the maximum number of
+// differing entries shown when Matches compares lists of values such as the
+// imports or the values of a tag. By default just the first difference is
+// shown. A value of zero (or less) means that every difference is shown.
+func MaxDiffs(n int) MatchOpt {
+	return func(mc *matchCfg) {
+		if n < 0 {
+			n = 0
+		}
+		mc.maxDiffs = n
+	}
 }
 
-// Matches returns an error if the two snippets differ, nil otherwise
-func (s S) Matches(other S) error {
+// Equal returns true if the two snippets match, false otherwise. It is
+// equivalent to checking that Matches returns nil and so, like Matches, it
+// does not compare the text of the snippets.
+func (s S) Equal(other S) bool {
+	return s.Matches(other) == nil
+}
+
+// Matches returns an error if the two snippets differ, nil otherwise. The
+// options control how the differences are reported.
+func (s S) Matches(other S, opts ...MatchOpt) error {
+	mc := matchCfg{maxDiffs: dfltMaxDiffs}
+	for _, o := range opts {
+		o(&mc)
+	}
+
 	if s.name != other.name {
 		return fmt.Errorf("the names differ: this: %q, other: %q",
 			s.name, other.name)
@@ -128,24 +290,59 @@ func (s S) Matches(other S) error {
 		return fmt.Errorf("the paths differ: this: %q, other: %q",
 			s.path, other.path)
 	}
-	if err := cmpSlice("docs", s.docs, other.docs); err != nil {
+	if err := cmpSlice("docs", s.docs, other.docs,
+		mc.maxDiffs); err != nil {
 		return err
 	}
-	if err := cmpSlice("expects", s.expects, other.expects); err != nil {
+	if err := cmpSlice("expects", s.expects, other.expects,
+		mc.maxDiffs); err != nil {
 		return err
 	}
-	if err := cmpSlice("imports", s.imports, other.imports); err != nil {
+	if err := cmpSlice("imports", s.imports, other.imports,
+		mc.maxDiffs); err != nil {
 		return err
 	}
-	if err := cmpSlice("follows", s.follows, other.follows); err != nil {
+	if err := cmpSlice("follows", s.follows, other.follows,
+		mc.maxDiffs); err != nil {
 		return err
 	}
+	if err := cmpSlice("seeAlso", s.seeAlso, other.seeAlso,
+		mc.maxDiffs); err != nil {
+		return err
+	}
+	if err := cmpSlice("includes", s.Includes(), other.Includes(),
+		mc.maxDiffs); err != nil {
+		return err
+	}
+	if s.status != other.status {
+		return fmt.Errorf("the statuses differ: this: %q, other: %q",
+			s.status, other.status)
+	}
+	if s.deprecated != other.deprecated ||
+		s.deprecatedMsg != other.deprecatedMsg {
+		return fmt.Errorf("the deprecations differ:"+
+			" this: %t %q, other: %t %q",
+			s.deprecated, s.deprecatedMsg,
+			other.deprecated, other.deprecatedMsg)
+	}
+	if s.since != other.since {
+		return fmt.Errorf("the Go versions differ: this: %q, other: %q",
+			s.since, other.since)
+	}
+	for _, p := range customParts {
+		if err := cmpSlice(p, s.custom[p], other.custom[p],
+			mc.maxDiffs); err != nil {
+			return err
+		}
+	}
 
-	return cmpTags(s.tags, other.tags)
+	return cmpTags(s.tags, other.tags, mc.maxDiffs)
 }
 
-// cmpTags returns an error if the two tag maps are different, nil otherwise
-func cmpTags(a, b map[string][]string) error {
+// cmpTags returns an error if the two tag maps are different, nil otherwise.
+// At most maxDiffs differing values are shown for a tag; if maxDiffs is
+// zero then all the differences are shown.
+func cmpTags(a, b map[string][]string, maxDiffs int) error {
 	differingTags := []string{}
 	for k := range a {
 		if _, ok := b[k]; !ok {
@@ -166,7 +363,7 @@ func cmpTags(a, b map[string][]string) error {
 	}
 
 	for tag, vals := range a {
-		err := cmpSlice("Tag:"+tag, vals, b[tag])
+		err := cmpSlice("Tag:"+tag, vals, b[tag], maxDiffs)
 		if err != nil {
 			return err
 		}
@@ -175,32 +372,35 @@ func cmpTags(a, b map[string][]string) error {
 }
 
 // cmpSlice returns an error if the two slices are different, nil otherwise.
-func cmpSlice(name string, a, b []string) error {
+// At most maxDiffs differing entries are shown; if maxDiffs is zero then all
+// the differences are shown.
+func cmpSlice(name string, a, b []string, maxDiffs int) error {
 	diffs := []string{}
 	if len(a) != len(b) {
 		diffs = append(diffs,
 			fmt.Sprintf("the lengths differ: %d != %d", len(a), len(b)))
 	}
 	maxBIdx := len(b) - 1
-	var diffCount int
+	var diffCount, diffsShown int
 	var i int
 	var s string
 	for i, s = range a {
 		if i <= maxBIdx {
 			if s != b[i] {
-				if diffCount == 0 {
+				if maxDiffs == 0 || diffsShown < maxDiffs {
 					diffs = append(diffs,
 						fmt.Sprintf("entry[%d] differs: %q != %q", i, s, b[i]))
+					diffsShown++
 				}
 				diffCount++
 			}
 		}
 	}
-	if diffCount == 2 {
+	if extra := diffCount - diffsShown; extra == 1 {
 		diffs = append(diffs, "an additional difference was found")
-	} else if diffCount > 2 {
+	} else if extra > 1 {
 		diffs = append(diffs,
-			fmt.Sprintf("%d additional differences were found", diffCount-1))
+			fmt.Sprintf("%d additional differences were found", extra))
 	}
 	if len(diffs) > 0 {
 		return fmt.Errorf("%s differs:\n\t%s",
@@ -219,6 +419,13 @@ func (s S) Path() string {
 	return s.path
 }
 
+// Dir returns the snippet directory that the snippet was found in. If the
+// snippet was given by an absolute pathname which is not in a snippet
+// directory this is the directory containing the snippet file.
+func (s S) Dir() string {
+	return s.dir
+}
+
 // Text returns the text of the snippet - every line not starting with the
 // snippet comment (// snippet:).
 func (s S) Text() []string {
@@ -227,6 +434,80 @@ func (s S) Text() []string {
 	return rval
 }
 
+// trimTrailingBlankLines removes any lines at the end of the snippet text
+// which are empty or consist only of white space.
+func (s *S) trimTrailingBlankLines() {
+	n := len(s.text)
+	for n > 0 && strings.TrimSpace(s.text[n-1]) == "" {
+		n--
+	}
+	s.text = s.text[:n]
+}
+
+// TextString returns the text of the snippet as a single string with the
+// lines joined by newlines. Each line, including the last, is terminated by
+// a newline, as in a file. If the snippet has no text an empty string is
+// returned.
+func (s S) TextString() string {
+	if len(s.text) == 0 {
+		return ""
+	}
+	return strings.Join(s.text, "\n") + "\n"
+}
+
+// TrimmedText returns the text of the snippet as for Text but with any
+// trailing white space removed from each line. Lines consisting only of
+// white space will be empty.
+func (s S) TrimmedText() []string {
+	rval := make([]string, 0, len(s.text))
+	for _, l := range s.text {
+		rval = append(rval, strings.TrimRightFunc(l, unicode.IsSpace))
+	}
+	return rval
+}
+
+// TextInline returns the text of the snippet as a single string with the
+// lines joined by the joiner (for instance, "<br>" for an HTML table). This
+// differs from Text which returns the lines as a slice. Note that the lines
+// are not escaped; use TextInlineEscaped to escape them for the target
+// format.
+func (s S) TextInline(joiner string) string {
+	return s.TextInlineEscaped(joiner, nil)
+}
+
+// TextInlineEscaped returns the text of the snippet as for TextInline but
+// with each line first passed to the escape func (for instance,
+// html.EscapeString). If escape is nil the lines are not changed.
+func (s S) TextInlineEscaped(joiner string, escape func(string) string) string {
+	lines := make([]string, 0, len(s.text))
+	for _, l := range s.text {
+		if escape != nil {
+			l = escape(l)
+		}
+		lines = append(lines, l)
+	}
+	return strings.Join(lines, joiner)
+}
+
+// TextOffset returns the byte offset in the snippet file of the first
+// line of the snippet text. It returns -1 if the snippet has no text or was
+// not read from a file.
+func (s S) TextOffset() int {
+	if len(s.text) == 0 || s.path == "" {
+		return -1
+	}
+	return s.textOffset
+}
+
+// ContentHash returns the MD5 hash of the content of the snippet file as a
+// string of hexadecimal digits. This is the same as the value returned by
+// the ContentHash func when given the content of the snippet file. For a
+// snippet made with a SnippetBuilder it is the hash of the snippet written
+// as a snippet file (see WriteSnippetFile).
+func (s S) ContentHash() string {
+	return hex.EncodeToString(s.contentHash[:])
+}
+
 // Docs returns the documentary notes for the snippet.
 func (s S) Docs() []string {
 	rval := make([]string, len(s.docs))
@@ -234,6 +515,13 @@ func (s S) Docs() []string {
 	return rval
 }
 
+// DocsString returns the documentary notes for the snippet as a single
+// string with the lines joined by newlines. Unlike TextString there is no
+// trailing newline.
+func (s S) DocsString() string {
+	return strings.Join(s.docs, "\n")
+}
+
 // Expects returns the list of other snippets that are expected to be used if
 // this snippet is used.
 func (s S) Expects() []string {
@@ -250,6 +538,26 @@ func (s S) Imports() []string {
 	return rval
 }
 
+// ImportsBeyond returns those imports of the snippet which are not in the
+// existing imports, sorted and without duplicates. Imports are compared by
+// package path alone, any alias is ignored, so an aliased import matches an
+// existing import of the same path. Note that this means the snippet may
+// refer to a package by a different name from the existing import.
+func (s S) ImportsBeyond(existing []string) []string {
+	have := map[string]bool{}
+	for _, imp := range existing {
+		have[importPath(imp)] = true
+	}
+
+	rval := []string{}
+	for _, imp := range s.imports {
+		if !have[importPath(imp)] {
+			rval = append(rval, imp)
+		}
+	}
+	return tidySlice(rval)
+}
+
 // Follows returns the list of other snippets that this snippet should
 // come after in any code that uses it.
 func (s S) Follows() []string {
@@ -258,6 +566,67 @@ func (s S) Follows() []string {
 	return rval
 }
 
+// SeeAlso returns the list of other snippets which are related to this
+// snippet. Unlike the expected snippets these need not be used with this
+// snippet and imply no ordering.
+func (s S) SeeAlso() []string {
+	rval := make([]string, len(s.seeAlso))
+	copy(rval, s.seeAlso)
+	return rval
+}
+
+// ModTime returns the modification time of the snippet file. This is only
+// known for snippets read from the operating system's file system; for
+// snippets read from an fs.FS (see SetFS), parsed from a reader or built
+// with a SnippetBuilder the zero time is returned.
+func (s S) ModTime() time.Time {
+	return s.modTime
+}
+
+// Includes returns the names of the snippets whose text is to be inserted
+// into the text of this snippet, in the order in which they are given. The
+// text is only inserted by Cache.ExpandIncludes; the text of this snippet
+// has just its own lines.
+func (s S) Includes() []string {
+	rval := make([]string, 0, len(s.includes))
+	for _, inc := range s.includes {
+		rval = append(rval, inc.name)
+	}
+	return rval
+}
+
+// Deprecated returns true if the snippet should no longer be used together
+// with the reason given in the deprecated comments. A snippet is deprecated
+// if it has a deprecated comment or if its status is StatusDeprecated; in
+// the latter case the message may be empty.
+func (s S) Deprecated() (bool, string) {
+	return s.deprecated || s.status == StatusDeprecated, s.deprecatedMsg
+}
+
+// Since returns the minimum Go version needed to use the snippet, as given
+// by the since comment, or the empty string if no version is given.
+func (s S) Since() string {
+	return s.since
+}
+
+// Status returns the status of the snippet. This will be one of the values
+// given by ValidStatuses or the empty string if the snippet has no status.
+func (s S) Status() string {
+	return s.status
+}
+
+// docLinks returns the names of any snippets which appear to be referred to
+// in the notes. See docLinkRE for the rules used to find them.
+func (s S) docLinks() []string {
+	links := []string{}
+	for _, d := range s.docs {
+		for _, m := range docLinkRE.FindAllStringSubmatch(d, -1) {
+			links = append(links, m[1])
+		}
+	}
+	return tidySlice(links)
+}
+
 // Tags returns the tags of the snippet - those comments marked as tags. Any
 // tag text will be split around the first ':' and the first part will be
 // used as a label for the second part.
@@ -271,20 +640,292 @@ func (s S) Tags() map[string][]string {
 	return rval
 }
 
+// HasTag returns true if the snippet has the named tag. The name must match
+// exactly, including case; see TagValueFold for a case-blind lookup.
+func (s S) HasTag(key string) bool {
+	_, ok := s.tags[key]
+	return ok
+}
+
+// TagValueFold returns a copy of the values of the named tag where the tag
+// name is matched regardless of case, so "author" will find the values of
+// both "Author" and "AUTHOR". Where more than one tag matches the values are
+// given in alphabetical order of tag name. The bool result is false if no
+// tag matches.
+func (s S) TagValueFold(key string) ([]string, bool) {
+	var vals []string
+	found := false
+	for _, k := range getTagKeys(&s) {
+		if strings.EqualFold(k, key) {
+			vals = append(vals, s.tags[k]...)
+			found = true
+		}
+	}
+	return vals, found
+}
+
+// TagHasValue returns true if the snippet has the named tag and it was
+// given with a value at least once. A tag given with no colon after the
+// tag name, as in:
+//
+//	// snippet: tag: standalone
+//
+// has no value (though the value "" is still recorded in the Tags) whereas
+// one given with a colon but nothing after it has an empty value. This
+// allows tags used as simple flags to be distinguished from tags with an
+// empty value.
+func (s S) TagHasValue(key string) bool {
+	return len(s.tags[key]) > s.bareTags[key]
+}
+
+// copyBareTag copies the count of the times the named tag was given with
+// no value from the other snippet.
+func (s *S) copyBareTag(other S, tag string) {
+	if n := other.bareTags[tag]; n > 0 {
+		if s.bareTags == nil {
+			s.bareTags = map[string]int{}
+		}
+		s.bareTags[tag] = n
+	}
+}
+
+// TagValue returns the first value of the named tag. The bool result is
+// false if the snippet does not have the tag.
+func (s S) TagValue(key string) (string, bool) {
+	vals := s.tags[key]
+	if len(vals) == 0 {
+		return "", false
+	}
+	return vals[0], true
+}
+
+// TagInt returns the first value of the named tag as an int. The bool
+// result is false if the snippet does not have the tag. An error is
+// returned if the tag value is not an int.
+func (s S) TagInt(key string) (int, bool, error) {
+	v, ok := s.TagValue(key)
+	if !ok {
+		return 0, false, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, true, fmt.Errorf("tag %q: %q is not an int", key, v)
+	}
+	return i, true, nil
+}
+
+// TagBool returns the first value of the named tag as a bool. The values
+// allowed are those accepted by strconv.ParseBool, such as "true" and
+// "false". The bool result is false if the snippet does not have the
+// tag. An error is returned if the tag value is not a bool.
+func (s S) TagBool(key string) (bool, bool, error) {
+	v, ok := s.TagValue(key)
+	if !ok {
+		return false, false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, true, fmt.Errorf("tag %q: %q is not a bool", key, v)
+	}
+	return b, true, nil
+}
+
+// copySlice returns a copy of the slice
+func copySlice(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	rval := make([]string, len(s))
+	copy(rval, s)
+	return rval
+}
+
+// Clone returns a deep copy of the snippet. The copy shares no slices or
+// maps with the original so changing one cannot affect the other. As the
+// parts of a snippet cannot be changed directly this, together with
+// Project, is the supported way of deriving a new snippet from an existing
+// one.
+func (s S) Clone() *S {
+	c := s
+	c.text = copySlice(s.text)
+	c.docs = copySlice(s.docs)
+	c.expects = copySlice(s.expects)
+	c.imports = copySlice(s.imports)
+	c.follows = copySlice(s.follows)
+	c.seeAlso = copySlice(s.seeAlso)
+	c.tags = copyMap(s.tags)
+	if s.bareTags != nil {
+		c.bareTags = map[string]int{}
+		for k, v := range s.bareTags {
+			c.bareTags[k] = v
+		}
+	}
+	c.custom = copyMap(s.custom)
+	if s.includes != nil {
+		c.includes = append([]include{}, s.includes...)
+	}
+	if s.parseWarnings != nil {
+		c.parseWarnings = append([]error{}, s.parseWarnings...)
+	}
+	return &c
+}
+
+// copyMap returns a copy of the map with each of the values copied
+func copyMap(m map[string][]string) map[string][]string {
+	if m == nil {
+		return nil
+	}
+	rval := make(map[string][]string, len(m))
+	for k, v := range m {
+		rval[k] = copySlice(v)
+	}
+	return rval
+}
+
+// Project returns a copy of the snippet with only the given parts
+// populated; all the other parts are left empty. The parts are named as
+// for ValidParts, including any parts added by RegisterPart. If TagPart is
+// given all the tags are kept; a single tag is kept by giving its name
+// after TagStr, as in "tag:owner". An error is returned, and no snippet,
+// if any name is neither a valid part nor a tag given in this way.
+func (s S) Project(parts ...string) (S, error) {
+	for _, part := range parts {
+		if strings.HasPrefix(part, TagStr) {
+			continue
+		}
+		if _, ok := validParts[part]; !ok {
+			return S{}, fmt.Errorf(
+				"%q is not a valid pre-defined part of a snippet", part)
+		}
+	}
+	return s.project(parts...), nil
+}
+
+// project returns a copy of the snippet with only the given parts
+// populated, as for Project. The parts are not checked; any part given
+// which is not a valid part or a tag given after TagStr is ignored.
+func (s S) project(parts ...string) S {
+	p := S{tags: map[string][]string{}}
+
+	for _, part := range parts {
+		switch part {
+		case NamePart:
+			p.name = s.name
+		case PathPart:
+			p.path = s.path
+			p.dir = s.dir
+		case ModTimePart:
+			p.modTime = s.modTime
+		case TextPart:
+			p.text = copySlice(s.text)
+		case DocsPart:
+			p.docs = copySlice(s.docs)
+		case ImportPart:
+			p.imports = copySlice(s.imports)
+		case ExpectPart:
+			p.expects = copySlice(s.expects)
+		case FollowPart:
+			p.follows = copySlice(s.follows)
+		case SeeAlsoPart:
+			p.seeAlso = copySlice(s.seeAlso)
+		case IncludePart:
+			if s.includes != nil {
+				p.includes = append([]include{}, s.includes...)
+			}
+		case StatusPart:
+			p.status = s.status
+		case SincePart:
+			p.since = s.since
+		case DeprecatedPart:
+			p.deprecated = s.deprecated
+			p.deprecatedMsg = s.deprecatedMsg
+		case TagPart:
+			for k, v := range s.tags {
+				p.tags[k] = copySlice(v)
+				p.copyBareTag(s, k)
+			}
+		default:
+			if isCustomPart(part) {
+				if v, ok := s.custom[part]; ok {
+					if p.custom == nil {
+						p.custom = map[string][]string{}
+					}
+					p.custom[part] = copySlice(v)
+				}
+				continue
+			}
+			if !strings.HasPrefix(part, TagStr) {
+				continue
+			}
+			tag := strings.TrimSpace(strings.TrimPrefix(part, TagStr))
+			if v, ok := s.tags[tag]; ok {
+				p.tags[tag] = copySlice(v)
+				p.copyBareTag(s, tag)
+			}
+		}
+	}
+
+	return p
+}
+
+// These suffixes are added to the name of a snippet to give the names of
+// the snippets returned by ImportsOnly and CodeOnly.
+const (
+	ImportsOnlySuffix = "-imports"
+	CodeOnlySuffix    = "-code"
+)
+
+// ImportsOnly returns a copy of the snippet holding just its imports. The
+// name is that of the snippet with ImportsOnlySuffix added and the path is
+// unchanged. This can be used to start a new file with the imports that the
+// snippet needs.
+func (s S) ImportsOnly() S {
+	p := s.project(PathPart, ImportPart)
+	p.name = s.name + ImportsOnlySuffix
+	return p
+}
+
+// CodeOnly returns a copy of the snippet holding just its text. The name is
+// that of the snippet with CodeOnlySuffix added and the path is unchanged.
+func (s S) CodeOnly() S {
+	p := s.project(PathPart, TextPart)
+	p.name = s.name + CodeOnlySuffix
+	return p
+}
+
 // String returns a string representation of the snippet
 func (s S) String() string {
 	fc := formatCfg{}
 	return fc.snippetToString(&s)
 }
 
-// readSnippetFile will open and read the contents of a snippet file and
-// return the contents together with the full pathname of the file it was
-// read from. If the snippet file cannot be found in any of the snippet
-// directories or the absolute pathname cannot be opened an error is
-// returned.
-func readSnippetFile(dirs []string, sName string) ([]byte, string, error) {
+// checkNameIsConfined returns an error if the snippet name is not an
+// absolute pathname and, once cleaned, it would refer to a file outside
+// the snippet directory it is joined to.
+func checkNameIsConfined(sName string) error {
+	if filepath.IsAbs(sName) {
+		return nil
+	}
+	clean := filepath.Clean(sName)
+	if clean == ".." ||
+		strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("snippet name %q is outside the snippet directory",
+			sName)
+	}
+	return nil
+}
+
+// readSnippetFile will open and read the contents of a snippet file from
+// the file system (see readFile) and return the contents together with the
+// full pathname of the file it was read from. If the snippet file is not
+// found in a snippet directory a file of the same name with GzipSuffix
+// added is looked for. Compressed files are decompressed. If the snippet
+// file cannot be found in any of the snippet directories or the absolute
+// pathname cannot be opened an error is returned.
+func readSnippetFile(fsys fs.FS, dirs []string, sName string,
+) ([]byte, string, error) {
 	if filepath.IsAbs(sName) {
-		content, err := os.ReadFile(sName)
+		content, err := readSnippetContent(fsys, sName)
 		return content, sName, err
 	}
 
@@ -294,10 +935,14 @@ func readSnippetFile(dirs []string, sName string) ([]byte, string, error) {
 
 	for _, dir := range dirs {
 		fName := filepath.Join(dir, sName)
-		content, err := os.ReadFile(fName)
+		content, err := readSnippetContent(fsys, fName)
 		if err == nil {
 			return content, fName, nil
 		}
+		content, err = readSnippetContent(fsys, fName+GzipSuffix)
+		if err == nil {
+			return content, fName + GzipSuffix, nil
+		}
 	}
 
 	if len(dirs) == 1 {
@@ -310,44 +955,241 @@ func readSnippetFile(dirs []string, sName string) ([]byte, string, error) {
 			sName, strings.Join(dirs, `", "`))
 }
 
-// parseSnippet will construct the snippet from the content.
+// GzipSuffix is the suffix of a snippet file which has been compressed with
+// gzip. The suffix is not part of the snippet name.
+const GzipSuffix = ".gz"
+
+// gzipMagic is the start of a file compressed with gzip
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// readSnippetContent reads the snippet file from the file system (see
+// readFile) and returns its contents. If the file has been compressed with
+// gzip the contents are decompressed.
+func readSnippetContent(fsys fs.FS, fName string) ([]byte, error) {
+	content, err := readFile(fsys, fName)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(content, gzipMagic) {
+		return content, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress %q: %w", fName, err)
+	}
+	defer zr.Close()
+
+	content, err = io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress %q: %w", fName, err)
+	}
+	return content, nil
+}
+
+// snippetDir returns the snippet directory containing the snippet file. If
+// the file is not in any of the snippet directories the directory containing
+// the file is returned.
+func snippetDir(dirs []string, fName string) string {
+	for _, dir := range dirs {
+		if strings.HasPrefix(fName, dir+string(filepath.Separator)) {
+			return dir
+		}
+	}
+	return filepath.Dir(fName)
+}
+
+// utf8BOM is the UTF-8 encoding of the byte order mark. Some editors put it
+// at the start of a file.
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// Parse reads the snippet content from the reader and constructs a snippet
+// with the given name and path. The path need not refer to an existing
+// file; it is only recorded in the snippet. The content is parsed and
+// checked exactly as if it had been read from a snippet file.
+func Parse(r io.Reader, name, path string) (*S, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snippet %q: %w", name, err)
+	}
+	return parseSnippet(content, path, name)
+}
+
+// parseSnippet will construct the snippet from the content. Any leading
+// byte order mark is ignored and lines may end with a newline, a carriage
+// return or both (see scanLines).
+//
+// If the content has any lines marked with the comments:
+//
+//	// snippet: begin
+//	...
+//	// snippet: end
+//
+// then only the lines between these markers are used as the snippet text;
+// the semantic comments are still recognised anywhere in the file. A begin
+// marker with no following end marker extends to the end of the file.
+// Otherwise all the lines which are not semantic comments are the text.
+//
+// The comment:
+//
+//	// snippet: skip: N
+//
+// causes the next N lines which are not semantic comments to be left out of
+// the text; if the count is not given then one line is skipped. Skipped
+// lines are dropped whether or not they are between begin and end markers.
+// The markers themselves are not counted as skipped lines and a count
+// which runs past an end marker continues to drop lines after it.
+//
+// The place in the text of each include comment is recorded but the
+// included text is not inserted; that is done by Cache.ExpandIncludes.
 func parseSnippet(content []byte, fName, sName string) (*S, error) {
 	s := &S{
-		name: sName,
-		path: fName,
-		tags: map[string][]string{},
+		name:        sName,
+		path:        fName,
+		tags:        map[string][]string{},
+		contentHash: md5.Sum(content),
+		textOffset:  -1,
 	}
 
-	buf := bytes.NewBuffer(content)
-	scanner := bufio.NewScanner(buf)
+	trimmed := bytes.TrimPrefix(content, utf8BOM)
+	pos := len(content) - len(trimmed)
+	lineStart := pos
+
+	var statuses []string
+	var since []string
+	var deprecations []string
+	lineNum := 0
+
+	var hasMarkers, inMarkedText bool
+	var markedText []string
+	var markedIncludes []include
+	markedOffset := -1
+	skip := 0
+
+	scanner := bufio.NewScanner(bytes.NewBuffer(trimmed))
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := scanLines(data, atEOF)
+		lineStart = pos
+		pos += advance
+		return advance, token, err
+	})
 	for scanner.Scan() {
 		l := scanner.Text()
+		lineNum++
 		if commentRE.FindStringIndex(l) != nil {
-			if addMatchToSlices(l, snippetPartREs[ImportPart], &s.imports) {
+			if beginRE.MatchString(l) {
+				if inMarkedText {
+					s.parseWarnings = append(s.parseWarnings,
+						fmt.Errorf("%s:%d: unexpected snippet %s marker,"+
+							" the previous %s has no %s",
+							fName, lineNum, BeginMarker,
+							BeginMarker, EndMarker))
+				}
+				hasMarkers, inMarkedText = true, true
+				continue
+			}
+			if endRE.MatchString(l) {
+				if !inMarkedText {
+					s.parseWarnings = append(s.parseWarnings,
+						fmt.Errorf("%s:%d: unexpected snippet %s marker,"+
+							" there is no preceding %s",
+							fName, lineNum, EndMarker, BeginMarker))
+				}
+				inMarkedText = false
+				continue
+			}
+			if m := skipRE.FindStringSubmatch(l); m != nil {
+				n, err := skipCount(m[1])
+				if err != nil {
+					return nil, fmt.Errorf("snippet %q (%s) line %d: %w",
+						sName, fName, lineNum, err)
+				}
+				skip += n
+				continue
+			}
+			if addListMatchToSlices(l, snippetPartREs[ImportPart],
+				&s.imports) {
 				continue
 			}
-			if addMatchToSlices(l, snippetPartREs[ExpectPart], &s.expects) {
+			if addListMatchToSlices(l, snippetPartREs[ExpectPart],
+				&s.expects) {
 				continue
 			}
-			if addMatchToSlices(l, snippetPartREs[FollowPart],
+			if addListMatchToSlices(l, snippetPartREs[FollowPart],
 				&s.expects, &s.follows) {
 				continue
 			}
+			if addMatchToSlices(l, snippetPartREs[SeeAlsoPart], &s.seeAlso) {
+				continue
+			}
+			var includes []string
+			if addListMatchToSlices(l, snippetPartREs[IncludePart],
+				&includes) {
+				for _, inc := range includes {
+					s.includes = append(s.includes,
+						include{name: inc, line: len(s.text)})
+					markedIncludes = append(markedIncludes,
+						include{name: inc, line: len(markedText)})
+				}
+				continue
+			}
+			if addMatchToSlices(l, snippetPartREs[StatusPart], &statuses) {
+				continue
+			}
+			if addMatchToSlices(l, snippetPartREs[SincePart], &since) {
+				continue
+			}
+			if addMatchToSlices(l, snippetPartREs[DeprecatedPart],
+				&deprecations) {
+				s.deprecated = true
+				continue
+			}
 			if addWholeMatchToSlice(l, snippetPartREs[DocsPart], &s.docs) {
 				continue
 			}
 			if s.addTag(l) {
 				continue
 			}
+			if s.addCustomPart(l) {
+				continue
+			}
+			s.parseWarnings = append(s.parseWarnings,
+				fmt.Errorf("%s:%d: unknown snippet comment: %q",
+					fName, lineNum, l))
+		} else if skip > 0 {
+			skip--
 		} else {
+			if len(s.text) == 0 {
+				s.textOffset = lineStart
+			}
 			s.text = append(s.text, l)
+			if inMarkedText {
+				if len(markedText) == 0 {
+					markedOffset = lineStart
+				}
+				markedText = append(markedText, l)
+			}
 		}
 	}
 
+	if hasMarkers {
+		s.text, s.textOffset = markedText, markedOffset
+		s.includes = markedIncludes
+	}
+
 	s.tidy()
 
+	if err := s.setStatus(statuses); err != nil {
+		return nil, fmt.Errorf("snippet %q (%s) %w", sName, fName, err)
+	}
+	if err := s.setSince(since); err != nil {
+		return nil, fmt.Errorf("snippet %q (%s) %w", sName, fName, err)
+	}
+	s.deprecatedMsg = strings.Join(deprecations, " ")
+
 	if len(s.text) == 0 &&
-		len(s.imports) == 0 {
+		len(s.imports) == 0 &&
+		len(s.includes) == 0 {
 		return nil,
 			fmt.Errorf("snippet %q (%s) has no text and no imports",
 				sName, fName)
@@ -356,12 +1198,123 @@ func parseSnippet(content []byte, fName, sName string) (*S, error) {
 	return s, nil
 }
 
-// tidy sorts and removes duplicates from the imports, expects and
-// follows slices. It also removes any empty entries.
+// scanLines is a bufio.SplitFunc which behaves as bufio.ScanLines except
+// that a carriage return on its own also ends a line. This means that
+// snippet files with any of the common line endings (LF, CRLF or CR) are
+// split into the same lines.
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		// the CR may be followed by a LF so request more data
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// skipCount returns the number of lines to be skipped as given by the
+// value of a skip comment. If the value is empty one line is to be skipped,
+// otherwise the value must be a positive number.
+func skipCount(val string) (int, error) {
+	if val == "" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n <= 0 {
+		return 0,
+			fmt.Errorf("bad %s count: %q (it should be a positive number)",
+				SkipMarker, val)
+	}
+	return n, nil
+}
+
+// ParseWarnings returns the problems found while parsing the snippet which
+// did not stop it from being parsed. Each warning gives the name of the
+// snippet file and the line number where the problem was found. For
+// instance, a semantic comment which does not start with any of the known
+// snippet parts is ignored and reported here.
+func (s S) ParseWarnings() []error {
+	return s.parseWarnings
+}
+
+// setStatus sets the status of the snippet from the status values found
+// when parsing it. It returns an error if there is more than one value or
+// the value is not a valid status.
+func (s *S) setStatus(statuses []string) error {
+	statuses = tidySlice(statuses)
+	if len(statuses) == 0 {
+		return nil
+	}
+	if len(statuses) > 1 {
+		return fmt.Errorf("has more than one status: %s",
+			strings.Join(statuses, ", "))
+	}
+
+	status := strings.ToLower(statuses[0])
+	if _, ok := validStatuses[status]; !ok {
+		return fmt.Errorf("has an unknown status: %q", statuses[0])
+	}
+	s.status = status
+	return nil
+}
+
+// goVersionRE matches a Go version such as 1.18 or 1.21.3
+var goVersionRE = regexp.MustCompile(`^[0-9]+\.[0-9]+(\.[0-9]+)?$`)
+
+// setSince sets the minimum Go version of the snippet from the since values
+// found when parsing it. It returns an error if there is more than one
+// value or the value is not a Go version.
+func (s *S) setSince(since []string) error {
+	since = tidySlice(since)
+	if len(since) == 0 {
+		return nil
+	}
+	if len(since) > 1 {
+		return fmt.Errorf("has more than one Go version: %s",
+			strings.Join(since, ", "))
+	}
+
+	if !goVersionRE.MatchString(since[0]) {
+		return fmt.Errorf("has a bad Go version: %q", since[0])
+	}
+	s.since = since[0]
+	return nil
+}
+
+// importPath returns the package path from an import entry. An import may
+// be given with an alias (as in a Go import statement) and the path may be
+// quoted; this strips off any alias and quotes.
+func importPath(imp string) string {
+	fields := strings.Fields(imp)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.Trim(fields[len(fields)-1], `"`)
+}
+
+// tidy sorts and removes duplicates from the imports, expects, follows
+// and seeAlso slices. It also removes any empty entries.
 func (s *S) tidy() {
 	s.imports = tidySlice(s.imports)
 	s.expects = tidySlice(s.expects)
 	s.follows = tidySlice(s.follows)
+	s.seeAlso = tidySlice(s.seeAlso)
 }
 
 // tidySlice sorts the slice, removes any blank or duplicate entries and
@@ -395,6 +1348,11 @@ func (s *S) addTag(line string) bool {
 	tag = strings.TrimSpace(parts[0])
 	if len(parts) == 2 {
 		value = strings.TrimSpace(parts[1])
+	} else {
+		if s.bareTags == nil {
+			s.bareTags = map[string]int{}
+		}
+		s.bareTags[tag]++
 	}
 	s.tags[tag] = append(s.tags[tag], value)
 	return true
@@ -419,6 +1377,31 @@ func addMatchToSlices(s string, re *regexp.Regexp, slcs ...*[]string) bool {
 	return true
 }
 
+// addListMatchToSlices behaves as per addMatchToSlices but the matched text
+// is split on commas and each non-empty, trimmed, value is added. This
+// allows several values to be given on a single line, as in:
+//
+//	// snippet: imports: fmt, os, strings
+//
+// Note that the text is not split on white space so that an import can
+// still be given with an alias.
+func addListMatchToSlices(s string, re *regexp.Regexp, slcs ...*[]string,
+) bool {
+	loc := re.FindStringIndex(s)
+	if loc == nil {
+		return false
+	}
+	for _, text := range strings.Split(s[loc[1]:], ",") {
+		text = strings.TrimSpace(text)
+		if len(text) > 0 {
+			for _, slc := range slcs {
+				*slc = append(*slc, text)
+			}
+		}
+	}
+	return true
+}
+
 // addWholeMatchToSlice behaves as per addMatchToSlices but doesn't trim
 // the line or ignore empty lines.
 func addWholeMatchToSlice(s string, re *regexp.Regexp, slc *[]string) bool {
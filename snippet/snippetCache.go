@@ -1,25 +1,80 @@
 package snippet
 
 import (
+	"context"
+	"crypto/md5"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/nickwells/errutil.mod/errutil"
 )
 
-// Cache holds a collection of snippets by name
-type Cache map[string]*S
+// Cache holds a collection of snippets by name. It also records the order
+// in which the snippets were added so that they can be retrieved in that
+// order and the snippet directories that they were searched for in. The
+// zero value is an empty Cache ready to use.
+//
+// The snippet directories given to the Cache methods have any environment
+// variables and any leading "~" expanded, as for NewListCfg, before they
+// are used or recorded. Only the directory names are expanded, not the
+// snippet names.
+type Cache struct {
+	snippets map[string]*S
+	order    []string
+	dirs     []string
+	registry Registry
+	fsys     fs.FS
+	// watchInterval is the time between the checks made by Watch
+	watchInterval time.Duration
+	// stdin is where the snippet named StdinName is read from. If it is
+	// nil the snippet is read from os.Stdin
+	stdin io.Reader
+	// confineNames controls whether relative snippet names which refer to
+	// files outside the snippet directories are rejected
+	confineNames bool
+}
+
+// These give the name by which a snippet is read from the standard input
+// and the path recorded for it.
+const (
+	StdinName = "-"
+	StdinPath = "<stdin>"
+)
 
 // Add will check that the snippet is not already in the cache and if not it
 // will search for the snippet file in the snippetDirs, parse the file and
 // generate a snippet which it will then store in the cache. It returns the
 // snippet and any error; if the error is non-nil the snippet will be nil.
+//
+// If the snippet name is StdinName ("-") the snippet is read from the
+// standard input (see SetStdin) rather than from the snippet directories
+// and its path is StdinPath. As the standard input can only be read once
+// the snippet is then kept in the cache under that name.
 func (c *Cache) Add(snippetDirs []string, sName string) (*S, error) {
-	s, ok := (*c)[sName]
+	snippetDirs = expandDirs(snippetDirs)
+	c.addDirs(snippetDirs)
+
+	s, ok := c.snippets[sName]
 	if ok {
 		return s, nil
 	}
 
-	content, fName, err := readSnippetFile(snippetDirs, sName)
+	if sName == StdinName {
+		return c.addStdin()
+	}
+	if c.confineNames {
+		if err := checkNameIsConfined(sName); err != nil {
+			return nil, err
+		}
+	}
+
+	content, fName, err := readSnippetFile(c.fsys, snippetDirs, sName)
 	if err != nil {
 		return nil, err
 	}
@@ -28,28 +83,197 @@ func (c *Cache) Add(snippetDirs []string, sName string) (*S, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.dir = snippetDir(snippetDirs, fName)
+	s.modTime = fileModTime(c.fsys, fName)
 
-	(*c)[sName] = s
+	c.store(sName, s)
 
 	return s, nil
 }
 
-// Get will retrieve the named snippet from the cache, returning an error if
-// it is not present.
-func (c Cache) Get(sName string) (*S, error) {
-	s, ok := c[sName]
+// addStdin reads the snippet from the standard input, parses it and stores
+// it in the cache.
+func (c *Cache) addStdin() (*S, error) {
+	r := c.stdin
+	if r == nil {
+		r = os.Stdin
+	}
+
+	s, err := Parse(r, StdinName, StdinPath)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store(StdinName, s)
+
+	return s, nil
+}
+
+// LoadDir reads every snippet in the snippet directories, and their
+// sub-directories, and stores them in the cache by name. The directories
+// are read in the same way as when listing the snippets so a snippet in an
+// earlier directory eclipses any of the same name in later directories.
+// Any problems found, such as snippets which cannot be parsed, eclipsed
+// snippets, duplicate snippets or missing directories, are recorded in the
+// ErrMap and the remaining snippets are still loaded. Snippets already in
+// the cache are left unchanged.
+func (c *Cache) LoadDir(snippetDirs []string, em *errutil.ErrMap) {
+	snippetDirs = expandDirs(snippetDirs)
+	c.addDirs(snippetDirs)
+
+	lc, err := NewListCfg(io.Discard, snippetDirs, em,
+		SetFS(c.fsys), RequireDirsExist(true))
+	if err != nil {
+		em.AddError("Bad listing configuration", err)
+		return
+	}
+	lc.tidy()
+	lc.ctx = context.Background()
+	lc.readSnippets()
+
+	for _, g := range lc.groups {
+		for _, s := range g.snippets {
+			if _, ok := c.snippets[s.name]; !ok {
+				c.store(s.name, s)
+			}
+		}
+	}
+}
+
+// AddStrict behaves as Add except when the snippet is already in the
+// cache. In that case the snippet file is searched for again and if it is a
+// different file from the cached snippet and its content differs an error
+// is returned, reporting both paths. This catches the case where two
+// snippet directories have different snippets with the same name. Note that
+// the cached snippet is returned with the error. A snippet read from the
+// standard input cannot be read again and so is never checked.
+func (c *Cache) AddStrict(snippetDirs []string, sName string) (*S, error) {
+	s, ok := c.snippets[sName]
 	if !ok {
-		return nil, fmt.Errorf("%q is not in the snippet cache", sName)
+		return c.Add(snippetDirs, sName)
+	}
+	if sName == StdinName {
+		return s, nil
+	}
+	if c.confineNames {
+		if err := checkNameIsConfined(sName); err != nil {
+			return s, err
+		}
+	}
+	snippetDirs = expandDirs(snippetDirs)
+	c.addDirs(snippetDirs)
+
+	content, fName, err := readSnippetFile(c.fsys, snippetDirs, sName)
+	if err != nil {
+		return s, err
+	}
+	if fName != s.path && md5.Sum(content) != s.contentHash {
+		return s, fmt.Errorf(
+			"snippet %q in %q differs from the cached snippet in %q",
+			sName, fName, s.path)
 	}
 	return s, nil
 }
 
+// store records the snippet in the cache under the given name and notes the
+// order in which it was added.
+func (c *Cache) store(sName string, s *S) {
+	if c.snippets == nil {
+		c.snippets = map[string]*S{}
+	}
+	c.snippets[sName] = s
+	c.order = append(c.order, sName)
+}
+
+// addDirs records any of the snippet directories not already recorded,
+// keeping them in the order in which they were first given.
+func (c *Cache) addDirs(snippetDirs []string) {
+	for _, dir := range snippetDirs {
+		if !containsString(c.dirs, dir) {
+			c.dirs = append(c.dirs, dir)
+		}
+	}
+}
+
+// SetRegistry sets the Registry used by Get to find snippets by alias.
+func (c *Cache) SetRegistry(r Registry) {
+	c.registry = r
+}
+
+// SetFS sets the file system that snippet files are read from. By default,
+// or if the file system is nil, they are read from the operating system's
+// file system. Note that absolute pathnames cannot be used with an fs.FS.
+func (c *Cache) SetFS(fsys fs.FS) {
+	c.fsys = fsys
+}
+
+// SetConfineSnippetNames sets whether snippet names are confined to the
+// snippet directories. If they are, Add, AddStrict and ResolveExpect
+// reject any snippet name which is not an absolute pathname and which,
+// once cleaned, would refer to a file outside the snippet directories,
+// such as "../../etc/passwd". By default these names are allowed.
+func (c *Cache) SetConfineSnippetNames(val bool) {
+	c.confineNames = val
+}
+
+// SetStdin sets the reader that the snippet named StdinName is read from.
+// By default, or if the reader is nil, it is read from os.Stdin.
+func (c *Cache) SetStdin(r io.Reader) {
+	c.stdin = r
+}
+
+// Get will retrieve the named snippet from the cache, returning an error if
+// it is not present. If there is no snippet of that name but the name is an
+// alias in the Registry (see SetRegistry) the snippet the alias refers to
+// is retrieved instead.
+func (c Cache) Get(sName string) (*S, error) {
+	s, ok := c.snippets[sName]
+	if ok {
+		return s, nil
+	}
+	if c.registry.IsAlias(sName) {
+		target := c.registry.Resolve(sName)
+		if s, ok = c.snippets[target]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("%q is an alias for %q"+
+			" which is not in the snippet cache", sName, target)
+	}
+	return nil, fmt.Errorf("%q is not in the snippet cache", sName)
+}
+
+// InOrder returns the snippets in the cache in the order in which they were
+// first added.
+func (c Cache) InOrder() []*S {
+	rval := make([]*S, 0, len(c.order))
+	for _, sName := range c.order {
+		rval = append(rval, c.snippets[sName])
+	}
+	return rval
+}
+
+// Names returns the names of the snippets in the cache in alphabetical
+// order. Any aliases in the Registry are not included.
+func (c Cache) Names() []string {
+	names := make([]string, 0, len(c.snippets))
+	for n := range c.snippets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Len returns the number of snippets in the cache
+func (c Cache) Len() int {
+	return len(c.snippets)
+}
+
 // Check will check that all the snippets in the Cache have all their
 // expected snippets also in the cache
 func (c Cache) Check(em *errutil.ErrMap) {
-	for sName, s := range c {
+	for sName, s := range c.snippets {
 		for _, expected := range s.expects {
-			_, ok := c[expected]
+			_, ok := c.snippets[expected]
 			if !ok {
 				em.AddError(
 					fmt.Sprintf("Missing snippet %q", expected),
@@ -58,3 +282,124 @@ func (c Cache) Check(em *errutil.ErrMap) {
 		}
 	}
 }
+
+// CheckDuplicates will check that no two snippets in the Cache have the
+// same content. Any which do are recorded as errors in the same way that
+// they are when listing snippets. The first snippet added to the cache is
+// taken as the original and each later snippet with the same content is
+// reported as a duplicate of it.
+func (c Cache) CheckDuplicates(em *errutil.ErrMap) {
+	firstPath := map[[md5.Size]byte]string{}
+	for _, sName := range c.order {
+		s := c.snippets[sName]
+		if otherPath, isDup := firstPath[s.contentHash]; isDup {
+			em.AddError("Duplicate snippet",
+				fmt.Errorf("snippet %q is a duplicate of %q",
+					s.path, otherPath))
+			continue
+		}
+		firstPath[s.contentHash] = s.path
+	}
+}
+
+// ReferencedBy returns the names of the snippets in the Cache which refer
+// to the named snippet. The followedBy names are those of the snippets which
+// follow it and the expectedBy names are those of the snippets which expect
+// it but do not follow it (a snippet which follows another also expects
+// it). Both are in alphabetical order. If the name is an alias in the
+// Registry (see SetRegistry) the references to the snippet the alias refers
+// to are given.
+func (c Cache) ReferencedBy(sName string) (expectedBy, followedBy []string) {
+	if c.registry.IsAlias(sName) {
+		sName = c.registry.Resolve(sName)
+	}
+
+	expectedBy = []string{}
+	followedBy = []string{}
+	for _, name := range c.Names() {
+		s := c.snippets[name]
+		if containsString(s.follows, sName) {
+			followedBy = append(followedBy, name)
+		} else if containsString(s.expects, sName) {
+			expectedBy = append(expectedBy, name)
+		}
+	}
+	return expectedBy, followedBy
+}
+
+// EntryPointTag is the tag marking a snippet which is meant to be used
+// directly rather than through other snippets. Such snippets are not
+// reported by Orphans.
+const EntryPointTag = "entrypoint"
+
+// Orphans returns the names of the snippets in the Cache which no other
+// snippet in the Cache expects, follows or includes, in alphabetical
+// order. These may be snippets which are no longer used. A snippet which
+// refers to itself is still reported. Snippets with the EntryPointTag tag,
+// with or without a value, are not reported. References which are aliases
+// in the Registry (see SetRegistry) refer to the snippet the alias
+// refers to.
+func (c Cache) Orphans() []string {
+	referenced := map[string]bool{}
+	for name, s := range c.snippets {
+		refs := append(copySlice(s.expects), s.follows...)
+		refs = append(refs, s.Includes()...)
+		for _, ref := range refs {
+			if c.registry.IsAlias(ref) {
+				ref = c.registry.Resolve(ref)
+			}
+			if ref != name {
+				referenced[ref] = true
+			}
+		}
+	}
+
+	orphans := []string{}
+	for _, name := range c.Names() {
+		if referenced[name] {
+			continue
+		}
+		if _, ok := c.snippets[name].tags[EntryPointTag]; ok {
+			continue
+		}
+		orphans = append(orphans, name)
+	}
+	return orphans
+}
+
+// ResolveExpect finds the file which satisfies the expectation of the named
+// snippet for the expected snippet. The expected snippet is first looked for
+// in the same directory as the expecting snippet and then in each of the
+// snippet directories given when adding snippets to the Cache, in the order
+// they were given. As for Add, a compressed file with the GzipSuffix added
+// to the name is also looked for. It returns the pathname of the first file
+// found. An error is returned if the expecting snippet is not in the Cache,
+// if the expected snippet cannot be found or, if snippet names are confined
+// (see SetConfineSnippetNames), if the expected snippet name is outside the
+// snippet directory.
+func (c Cache) ResolveExpect(sName, expectName string) (string, error) {
+	s, err := c.Get(sName)
+	if err != nil {
+		return "", err
+	}
+	if c.confineNames {
+		if err := checkNameIsConfined(expectName); err != nil {
+			return "", err
+		}
+	}
+
+	searched := []string{filepath.Dir(s.path)}
+	searched = append(searched, c.dirs...)
+	for _, dir := range searched {
+		fName := filepath.Join(dir, expectName)
+		for _, f := range []string{fName, fName + GzipSuffix} {
+			info, err := statFile(c.fsys, f)
+			if err == nil && info.Mode().IsRegular() {
+				return f, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("snippet %q, expected by %q, is not in: \"%s\"",
+		expectName, sName, strings.Join(searched, `", "`))
+}
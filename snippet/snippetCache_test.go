@@ -1,10 +1,15 @@
 package snippet
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/nickwells/errutil.mod/errutil"
 	"github.com/nickwells/testhelper.mod/v2/testhelper"
@@ -144,6 +149,7 @@ func TestSnippet(t *testing.T) {
 		expExpects []string
 		expImports []string
 		expFollows []string
+		expSeeAlso []string
 		expTags    map[string][]string
 	}{
 		{
@@ -161,6 +167,7 @@ func TestSnippet(t *testing.T) {
 			},
 			expImports: []string{"package/one", "package/two"},
 			expFollows: []string{"anotherSnippet2", "anotherSnippet3"},
+			expSeeAlso: []string{"expects1", "expects2"},
 			expTags: map[string][]string{
 				"Author": {
 					"John Doe",
@@ -184,10 +191,632 @@ func TestSnippet(t *testing.T) {
 		testhelper.DiffStringSlice(t, id, "expects", s.Expects(), tc.expExpects)
 		testhelper.DiffStringSlice(t, id, "imports", s.Imports(), tc.expImports)
 		testhelper.DiffStringSlice(t, id, "follows", s.Follows(), tc.expFollows)
-		if err = cmpTags(s.Tags(), tc.expTags); err != nil {
+		testhelper.DiffStringSlice(t, id, "seeAlso", s.SeeAlso(), tc.expSeeAlso)
+		if err = cmpTags(s.Tags(), tc.expTags, dfltMaxDiffs); err != nil {
 			t.Log(id)
 			t.Logf("\t: %s", err)
 			t.Error("\t: the tags differ")
 		}
 	}
 }
+
+func TestCacheInOrder(t *testing.T) {
+	snippetDirs := []string{TestSnippets}
+
+	testCases := []struct {
+		testhelper.ID
+		names    []string
+		expOrder []string
+	}{
+		{
+			ID: testhelper.MkID("empty cache"),
+		},
+		{
+			ID:       testhelper.MkID("not alphabetical"),
+			names:    []string{"expects3", "complete", "expects1"},
+			expOrder: []string{"expects3", "complete", "expects1"},
+		},
+		{
+			ID:       testhelper.MkID("repeated additions are ignored"),
+			names:    []string{"expects2", "expects1", "expects2"},
+			expOrder: []string{"expects2", "expects1"},
+		},
+		{
+			ID:       testhelper.MkID("failed additions are ignored"),
+			names:    []string{"expects2", "nonesuch", "expects1"},
+			expOrder: []string{"expects2", "expects1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		sc := Cache{}
+		for _, name := range tc.names {
+			_, _ = sc.Add(snippetDirs, name)
+		}
+		order := []string{}
+		for _, s := range sc.InOrder() {
+			order = append(order, s.Name())
+		}
+		testhelper.DiffStringSlice(t, tc.IDStr(), "order", order, tc.expOrder)
+	}
+}
+
+func TestCacheCheckDuplicates(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		dirs    []string
+		names   []string
+		expErrs errutil.ErrMap
+	}{
+		{
+			ID:    testhelper.MkID("no duplicates"),
+			dirs:  []string{TestSnippets},
+			names: []string{"expects1", "expects2", "complete"},
+		},
+		{
+			ID:    testhelper.MkID("duplicates"),
+			dirs:  []string{BadSnippets, TestSnippets},
+			names: []string{"duplicate2", "goodNoExp", "duplicate1"},
+			expErrs: errutil.ErrMap{
+				"Duplicate snippet": []error{
+					errors.New(`snippet` +
+						` "` + filepath.Join(BadSnippets, "duplicate1") + `"` +
+						` is a duplicate of` +
+						` "` + filepath.Join(BadSnippets, "duplicate2") + `"`),
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		sc := Cache{}
+		for _, name := range tc.names {
+			if _, err := sc.Add(tc.dirs, name); err != nil {
+				t.Fatalf("%s: cannot add %q: %s", tc.IDStr(), name, err)
+			}
+		}
+		errMap := errutil.NewErrMap()
+		sc.CheckDuplicates(errMap)
+		if err := errMap.Matches(tc.expErrs); err != nil {
+			t.Log(tc.IDStr())
+			t.Errorf("\t: unexpected error: %s", err)
+		}
+	}
+}
+
+func TestCacheResolveExpect(t *testing.T) {
+	first := filepath.Join("testdata", "resolve", "first")
+	second := filepath.Join("testdata", "resolve", "second")
+	netDir := filepath.Join(first, "net")
+
+	sc := Cache{}
+	if _, err := sc.Add([]string{first, second}, "net/client"); err != nil {
+		t.Fatalf("cannot add the expecting snippet: %s", err)
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		sName      string
+		expectName string
+		expPath    string
+	}{
+		{
+			ID:         testhelper.MkID("same dir"),
+			sName:      "net/client",
+			expectName: "pool",
+			expPath:    filepath.Join(netDir, "pool"),
+		},
+		{
+			ID:         testhelper.MkID("first snippet dir"),
+			sName:      "net/client",
+			expectName: "net/pool",
+			expPath:    filepath.Join(first, "net", "pool"),
+		},
+		{
+			ID:         testhelper.MkID("fallback snippet dir"),
+			sName:      "net/client",
+			expectName: "helpers/retry",
+			expPath:    filepath.Join(second, "helpers", "retry"),
+		},
+		{
+			ID:         testhelper.MkID("directory, not a snippet"),
+			sName:      "net/client",
+			expectName: "helpers",
+			ExpErr: testhelper.MkExpErr(`snippet "helpers",` +
+				` expected by "net/client", is not in:`),
+		},
+		{
+			ID:         testhelper.MkID("not found"),
+			sName:      "net/client",
+			expectName: "nonesuch",
+			ExpErr: testhelper.MkExpErr(`snippet "nonesuch",` +
+				` expected by "net/client", is not in:` +
+				` "` + netDir + `", "` + first + `", "` + second + `"`),
+		},
+		{
+			ID:         testhelper.MkID("expecting snippet not in cache"),
+			sName:      "nonesuch",
+			expectName: "pool",
+			ExpErr: testhelper.MkExpErr(
+				`"nonesuch" is not in the snippet cache`),
+		},
+	}
+
+	for _, tc := range testCases {
+		path, err := sc.ResolveExpect(tc.sName, tc.expectName)
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffString(t, tc.IDStr(), "resolved path",
+				path, tc.expPath)
+		}
+	}
+}
+
+func TestCacheAddStrict(t *testing.T) {
+	override := filepath.Join("testdata", "layered", "override")
+	base := filepath.Join("testdata", "layered", "base")
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		firstDirs  []string
+		secondDirs []string
+	}{
+		{
+			ID:         testhelper.MkID("same file"),
+			firstDirs:  []string{override},
+			secondDirs: []string{override, base},
+		},
+		{
+			ID:         testhelper.MkID("different file, different content"),
+			firstDirs:  []string{override},
+			secondDirs: []string{base},
+			ExpErr: testhelper.MkExpErr(`snippet "tagged"` +
+				` in "` + filepath.Join(base, "tagged") + `"` +
+				` differs from the cached snippet` +
+				` in "` + filepath.Join(override, "tagged") + `"`),
+		},
+		{
+			ID:         testhelper.MkID("not found the second time"),
+			firstDirs:  []string{override},
+			secondDirs: []string{TestSnippets},
+			ExpErr: testhelper.MkExpErr(`snippet "tagged"` +
+				` is not in the snippet directory`),
+		},
+	}
+
+	for _, tc := range testCases {
+		sc := Cache{}
+		first, err := sc.AddStrict(tc.firstDirs, "tagged")
+		if err != nil {
+			t.Fatalf("%s: cannot add the snippet: %s", tc.IDStr(), err)
+		}
+		second, err := sc.AddStrict(tc.secondDirs, "tagged")
+		testhelper.CheckExpErr(t, err, tc)
+		if second != first {
+			t.Log(tc.IDStr())
+			t.Errorf("\t: the cached snippet should be returned")
+		}
+	}
+}
+
+func TestCacheAddGzipped(t *testing.T) {
+	zipDir := filepath.Join("testdata", "gz.snippets")
+	plainDir := filepath.Join("testdata", "gz.plain")
+
+	zc := Cache{}
+	zipped, err := zc.Add([]string{zipDir}, "zipped")
+	if err != nil {
+		t.Fatal("cannot add the gzipped snippet: ", err)
+	}
+	testhelper.DiffString(t, "gzipped snippet", "path",
+		zipped.Path(), filepath.Join(zipDir, "zipped"+GzipSuffix))
+
+	pc := Cache{}
+	plain, err := pc.Add([]string{plainDir}, "zipped")
+	if err != nil {
+		t.Fatal("cannot add the plain snippet: ", err)
+	}
+
+	unzipped := *zipped
+	unzipped.path = plain.path
+	if err := unzipped.Matches(*plain); err != nil {
+		t.Error("the gzipped snippet differs from the plain one: ", err)
+	}
+	testhelper.DiffStringSlice(t, "gzipped snippet", "text",
+		zipped.Text(), plain.Text())
+
+	if _, err := zc.Add([]string{zipDir}, "user"); err != nil {
+		t.Fatal("cannot add the expecting snippet: ", err)
+	}
+	path, err := zc.ResolveExpect("user", "zipped")
+	testhelper.DiffErr(t, "gzipped expected snippet", "error", err, nil)
+	testhelper.DiffString(t, "gzipped expected snippet", "path",
+		path, filepath.Join(zipDir, "zipped"+GzipSuffix))
+
+	manifest, errs := Manifest([]string{zipDir})
+	if len(errs) != 0 {
+		t.Fatal("unexpected manifest errors: ", errs)
+	}
+	testhelper.DiffString(t, "gzipped snippet", "manifest path",
+		manifest["zipped"].Path, filepath.Join(zipDir, "zipped"+GzipSuffix))
+	plainContent, err := os.ReadFile(filepath.Join(plainDir, "zipped"))
+	if err != nil {
+		t.Fatal("cannot read the plain snippet: ", err)
+	}
+	testhelper.DiffString(t, "gzipped snippet", "manifest content hash",
+		manifest["zipped"].ContentHash, ContentHash(plainContent))
+}
+
+func TestCacheAddDir(t *testing.T) {
+	absFile, err := filepath.Abs(filepath.Join(TestSnippets, "complete"))
+	if err != nil {
+		t.Fatal("cannot get the absolute pathname: ", err)
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		dirs   []string
+		sName  string
+		expDir string
+	}{
+		{
+			ID:     testhelper.MkID("first dir"),
+			dirs:   []string{GoodSnippets, MoreGoodSnippets},
+			sName:  "hw",
+			expDir: GoodSnippets,
+		},
+		{
+			ID:     testhelper.MkID("later dir"),
+			dirs:   []string{GoodSnippets, TestSnippets},
+			sName:  "complete",
+			expDir: TestSnippets,
+		},
+		{
+			ID:     testhelper.MkID("absolute pathname"),
+			dirs:   []string{GoodSnippets},
+			sName:  absFile,
+			expDir: filepath.Dir(absFile),
+		},
+	}
+
+	for _, tc := range testCases {
+		sc := Cache{}
+		s, err := sc.Add(tc.dirs, tc.sName)
+		if err != nil {
+			t.Fatalf("%s: cannot add %q: %s", tc.IDStr(), tc.sName, err)
+		}
+		testhelper.DiffString(t, tc.IDStr(), "dir", s.Dir(), tc.expDir)
+	}
+}
+
+func TestCacheSetFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lib/hw":      {Data: []byte("fmt.Println(\"Hello\")\n")},
+		"lib/sub/use": {Data: []byte("// snippet: expects: hw\nuse()\n")},
+	}
+
+	sc := Cache{}
+	sc.SetFS(fsys)
+
+	s, err := sc.Add([]string{"lib"}, "sub/use")
+	if err != nil {
+		t.Fatal("cannot add the snippet: ", err)
+	}
+	testhelper.DiffString(t, "fs snippet", "path",
+		s.Path(), filepath.Join("lib", "sub", "use"))
+	testhelper.DiffStringSlice(t, "fs snippet", "text",
+		s.Text(), []string{"use()"})
+
+	path, err := sc.ResolveExpect("sub/use", "hw")
+	if err != nil {
+		t.Fatal("cannot resolve the expected snippet: ", err)
+	}
+	testhelper.DiffString(t, "fs snippet", "resolved path",
+		path, filepath.Join("lib", "hw"))
+
+	if _, err := sc.Add([]string{GoodSnippets}, "hw"); err == nil {
+		t.Error("a snippet not in the fs.FS should not be found")
+	}
+}
+
+func TestCacheModTime(t *testing.T) {
+	dir := t.TempDir()
+	fName := filepath.Join(dir, "hw")
+	err := os.WriteFile(fName, []byte("fmt.Println(\"Hello\")\n"), 0o644)
+	if err != nil {
+		t.Fatal("cannot write the snippet file: ", err)
+	}
+	mt := time.Date(2020, time.March, 4, 5, 6, 7, 0, time.UTC)
+	if err := os.Chtimes(fName, mt, mt); err != nil {
+		t.Fatal("cannot set the modification time: ", err)
+	}
+
+	sc := Cache{}
+	s, err := sc.Add([]string{dir}, "hw")
+	if err != nil {
+		t.Fatal("cannot add the snippet: ", err)
+	}
+	if !s.ModTime().Equal(mt) {
+		t.Errorf("the modification time should be %s, not %s",
+			mt, s.ModTime())
+	}
+
+	var buf bytes.Buffer
+	lc, err := NewListCfg(&buf, []string{dir}, errutil.NewErrMap(),
+		SetParts(NamePart, ModTimePart), SetOutputFormat(FormatJSON))
+	if err != nil {
+		t.Fatal("cannot construct the ListCfg: ", err)
+	}
+	lc.List()
+	testhelper.DiffString(t, "listed snippet", "JSON", buf.String(),
+		"[\n"+
+			"  {\n"+
+			"    \"modtime\": \""+mt.Local().Format(time.RFC3339)+"\",\n"+
+			"    \"name\": \"hw\"\n"+
+			"  }\n"+
+			"]\n")
+
+	buf.Reset()
+	lc, err = NewListCfg(&buf, []string{dir}, errutil.NewErrMap(),
+		SetOutputFormat(FormatJSON))
+	if err != nil {
+		t.Fatal("cannot construct the ListCfg: ", err)
+	}
+	lc.List()
+	if !strings.Contains(buf.String(),
+		`"modtime": "`+mt.Local().Format(time.RFC3339)+`"`) {
+		t.Errorf("the JSON output should include the modification time:\n%s",
+			buf.String())
+	}
+
+	fsSC := Cache{}
+	fsSC.SetFS(fstest.MapFS{
+		"lib/hw": {Data: []byte("hw()\n"), ModTime: mt},
+	})
+	s, err = fsSC.Add([]string{"lib"}, "hw")
+	if err != nil {
+		t.Fatal("cannot add the snippet: ", err)
+	}
+	if !s.ModTime().IsZero() {
+		t.Errorf("a snippet from an fs.FS should have no modification"+
+			" time, not %s", s.ModTime())
+	}
+}
+
+func TestCacheAddStdin(t *testing.T) {
+	sc := Cache{}
+	sc.SetStdin(strings.NewReader("// snippet: imports: fmt\nfmt.Println()\n"))
+
+	s, err := sc.Add(nil, StdinName)
+	if err != nil {
+		t.Fatal("cannot add the snippet: ", err)
+	}
+	testhelper.DiffString(t, "stdin snippet", "path", s.Path(), StdinPath)
+	testhelper.DiffStringSlice(t, "stdin snippet", "imports",
+		s.Imports(), []string{"fmt"})
+	testhelper.DiffStringSlice(t, "stdin snippet", "text",
+		s.Text(), []string{"fmt.Println()"})
+
+	again, err := sc.AddStrict(nil, StdinName)
+	if err != nil {
+		t.Fatal("cannot add the snippet again: ", err)
+	}
+	if again != s {
+		t.Error("the cached stdin snippet should be returned")
+	}
+
+	badSC := Cache{}
+	badSC.SetStdin(strings.NewReader(""))
+	_, err = badSC.Add(nil, StdinName)
+	testhelper.DiffErr(t, "empty stdin", "error", err,
+		errors.New(`snippet "-" (<stdin>) has no text and no imports`))
+}
+
+func TestCacheConfineSnippetNames(t *testing.T) {
+	dirs := []string{filepath.Join(TestSnippets, "subDir1")}
+	const sName = "../complete"
+
+	sc := Cache{}
+	if _, err := sc.Add(dirs, sName); err != nil {
+		t.Fatal("by default the snippet should be found: ", err)
+	}
+
+	if _, err := sc.AddStrict(dirs, sName); err != nil {
+		t.Fatal("by default the snippet should be found again: ", err)
+	}
+	sc.SetConfineSnippetNames(true)
+	_, err := sc.AddStrict(dirs, sName)
+	testhelper.DiffErr(t, "confined, cached", "error", err,
+		errors.New(`snippet name "../complete"`+
+			` is outside the snippet directory`))
+
+	confined := Cache{}
+	confined.SetConfineSnippetNames(true)
+	_, err = confined.Add(dirs, sName)
+	testhelper.DiffErr(t, "confined", "error", err,
+		errors.New(`snippet name "../complete"`+
+			` is outside the snippet directory`))
+
+	if _, err := confined.Add(dirs, "goodNoExp"); err != nil {
+		t.Fatal("cannot add the snippet: ", err)
+	}
+	path, err := confined.ResolveExpect("goodNoExp", "goodNoExp")
+	testhelper.DiffErr(t, "confined, resolve", "error", err, nil)
+	testhelper.DiffString(t, "confined, resolve", "path",
+		path, filepath.Join(dirs[0], "goodNoExp"))
+	_, err = confined.ResolveExpect("goodNoExp", sName)
+	testhelper.DiffErr(t, "confined, resolve", "error", err,
+		errors.New(`snippet name "../complete"`+
+			` is outside the snippet directory`))
+
+	path, err = sc.ResolveExpect(sName, "complete")
+	testhelper.DiffErr(t, "resolve from the cached snippet", "error", err, nil)
+	testhelper.DiffString(t, "resolve from the cached snippet", "path",
+		path, filepath.Join(TestSnippets, "complete"))
+}
+
+func TestCacheReferencedBy(t *testing.T) {
+	c := Cache{}
+	for _, s := range []*S{
+		{name: "base"},
+		{name: "user1", expects: []string{"base"}},
+		{name: "user2", expects: []string{"base"}, follows: []string{"base"}},
+		{name: "user3", expects: []string{"base", "other"}},
+		{name: "other", expects: []string{"user1"}},
+	} {
+		c.store(s.name, s)
+	}
+	c.SetRegistry(Registry{aliases: map[string]string{"b": "base"}})
+
+	testCases := []struct {
+		testhelper.ID
+		sName         string
+		expExpectedBy []string
+		expFollowedBy []string
+	}{
+		{
+			ID:            testhelper.MkID("expected and followed"),
+			sName:         "base",
+			expExpectedBy: []string{"user1", "user3"},
+			expFollowedBy: []string{"user2"},
+		},
+		{
+			ID:            testhelper.MkID("alias"),
+			sName:         "b",
+			expExpectedBy: []string{"user1", "user3"},
+			expFollowedBy: []string{"user2"},
+		},
+		{
+			ID:            testhelper.MkID("expected only"),
+			sName:         "user1",
+			expExpectedBy: []string{"other"},
+			expFollowedBy: []string{},
+		},
+		{
+			ID:            testhelper.MkID("not referenced"),
+			sName:         "user2",
+			expExpectedBy: []string{},
+			expFollowedBy: []string{},
+		},
+		{
+			ID:            testhelper.MkID("not in the cache"),
+			sName:         "nonesuch",
+			expExpectedBy: []string{},
+			expFollowedBy: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		expectedBy, followedBy := c.ReferencedBy(tc.sName)
+		testhelper.DiffStringSlice(t, tc.IDStr(), "expectedBy",
+			expectedBy, tc.expExpectedBy)
+		testhelper.DiffStringSlice(t, tc.IDStr(), "followedBy",
+			followedBy, tc.expFollowedBy)
+	}
+}
+
+func TestCacheOrphans(t *testing.T) {
+	c := Cache{}
+	for _, s := range []*S{
+		{name: "base"},
+		{name: "aliased"},
+		{name: "included"},
+		{name: "followed"},
+		{name: "user", expects: []string{"base", "nonesuch"},
+			follows:  []string{"followed"},
+			includes: []include{{name: "included"}}},
+		{name: "main", expects: []string{"a", "main"},
+			tags: map[string][]string{EntryPointTag: {""}}},
+		{name: "self", expects: []string{"self"}},
+		{name: "unused", tags: map[string][]string{"owner": {"me"}}},
+	} {
+		c.store(s.name, s)
+	}
+	c.SetRegistry(Registry{aliases: map[string]string{"a": "aliased"}})
+
+	testhelper.DiffStringSlice(t, "orphans", "names",
+		c.Orphans(), []string{"self", "unused", "user"})
+
+	empty := Cache{}
+	testhelper.DiffStringSlice(t, "empty cache", "names",
+		empty.Orphans(), []string{})
+}
+
+func TestCacheNames(t *testing.T) {
+	c := Cache{}
+	testhelper.DiffStringSlice(t, "empty cache", "names",
+		c.Names(), []string{})
+	testhelper.DiffInt(t, "empty cache", "len", c.Len(), 0)
+
+	for _, name := range []string{"b", "c", "a"} {
+		c.store(name, &S{name: name})
+	}
+	c.SetRegistry(Registry{aliases: map[string]string{"x": "a"}})
+
+	testhelper.DiffStringSlice(t, "populated cache", "names",
+		c.Names(), []string{"a", "b", "c"})
+	testhelper.DiffInt(t, "populated cache", "len", c.Len(), 3)
+}
+
+func TestCacheLoadDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lib/hw":       {Data: []byte("fmt.Println(\"Hello\")\n")},
+		"lib/sub/use":  {Data: []byte("// snippet: expects: hw\nuse()\n")},
+		"lib/copy":     {Data: []byte("fmt.Println(\"Hello\")\n")},
+		"lib/noText":   {Data: []byte("// snippet: note: nothing\n")},
+		"other/hw":     {Data: []byte("fmt.Println(\"Goodbye\")\n")},
+		"other/extra":  {Data: []byte("extra()\n")},
+		"cached/extra": {Data: []byte("cached()\n")},
+		"notADir":      {Data: []byte("x()\n")},
+	}
+
+	sc := Cache{}
+	sc.SetFS(fsys)
+	if _, err := sc.Add([]string{"cached"}, "extra"); err != nil {
+		t.Fatal("cannot add the snippet: ", err)
+	}
+
+	em := errutil.NewErrMap()
+	sc.LoadDir([]string{"lib", "other", "nonesuch", "notADir"}, em)
+
+	testhelper.DiffStringSlice(t, "loaded", "names",
+		sc.Names(), []string{"copy", "extra", "hw", "sub/use"})
+
+	s, err := sc.Get("hw")
+	if err != nil {
+		t.Fatal("cannot get the snippet: ", err)
+	}
+	testhelper.DiffString(t, "eclipsing snippet", "path",
+		s.Path(), filepath.Join("lib", "hw"))
+	s, err = sc.Get("extra")
+	if err != nil {
+		t.Fatal("cannot get the snippet: ", err)
+	}
+	testhelper.DiffString(t, "already cached snippet", "path",
+		s.Path(), filepath.Join("cached", "extra"))
+
+	err = em.Matches(errutil.ErrMap{
+		`Bad snippets directory: "nonesuch"`: []error{
+			errors.New("open nonesuch: file does not exist"),
+		},
+		`Bad snippets directory: "notADir"`: []error{
+			errors.New(`snippet directory "notADir" is not a directory`),
+		},
+		"Bad snippet": []error{
+			errors.New(`snippet "noText" (` +
+				filepath.Join("lib", "noText") +
+				`) has no text and no imports`),
+		},
+		"Duplicate snippet": []error{
+			errors.New(`snippet "` + filepath.Join("lib", "hw") + `"` +
+				` is a duplicate of "` + filepath.Join("lib", "copy") + `"`),
+		},
+		"Eclipsed snippet": []error{
+			errors.New(`"hw" in "other" is eclipsed by the entry in "lib"`),
+		},
+	})
+	if err != nil {
+		t.Error("unexpected errors: ", err)
+	}
+}
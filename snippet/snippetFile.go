@@ -0,0 +1,206 @@
+package snippet
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// semanticComment returns a snippet semantic comment line for the part
+// with the given value
+func semanticComment(part, value string) string {
+	l := "// " + CommentStr + " " + part + ":"
+	if value != "" {
+		l += " " + value
+	}
+	return l + "\n"
+}
+
+// canonical returns the snippet in the canonical snippet file format. The
+// semantic comments come first, in this order: notes, imports, expects,
+// follows, related snippets (seealso), status, deprecation, Go version
+// (since), any parts added by RegisterPart and tags. The text follows the
+// comments. Only those expected snippets which are not also followed are
+// given as expects comments since a follows comment also records the
+// snippet as expected. Several deprecated comments are combined into one.
+// Tags are given in alphabetical order of tag name. Any include comments
+// are given among the lines of text, where the included text is to go.
+func (s S) canonical() string {
+	var b strings.Builder
+
+	for _, d := range s.docs {
+		b.WriteString(semanticComment(DocsPart, d))
+	}
+	for _, imp := range s.imports {
+		b.WriteString(semanticComment(ImportPart, imp))
+	}
+	for _, e := range s.expects {
+		if !containsString(s.follows, e) {
+			b.WriteString(semanticComment(ExpectPart, e))
+		}
+	}
+	for _, f := range s.follows {
+		b.WriteString(semanticComment(FollowPart, f))
+	}
+	for _, sa := range s.seeAlso {
+		b.WriteString(semanticComment(SeeAlsoPart, sa))
+	}
+	if s.status != "" {
+		b.WriteString(semanticComment(StatusPart, s.status))
+	}
+	if s.deprecated {
+		b.WriteString(semanticComment(DeprecatedPart, s.deprecatedMsg))
+	}
+	if s.since != "" {
+		b.WriteString(semanticComment(SincePart, s.since))
+	}
+	for _, cp := range customParts {
+		for _, v := range s.custom[cp] {
+			b.WriteString(semanticComment(cp, v))
+		}
+	}
+	for _, k := range getTagKeys(&s) {
+		bare := s.bareTags[k]
+		for _, v := range s.tags[k] {
+			if v == "" && bare > 0 {
+				b.WriteString(semanticComment(TagPart, k))
+				bare--
+				continue
+			}
+			b.WriteString(semanticComment(TagPart, k+": "+v))
+		}
+	}
+	inc := 0
+	for i, l := range s.text {
+		for ; inc < len(s.includes) && s.includes[inc].line <= i; inc++ {
+			b.WriteString(semanticComment(IncludePart, s.includes[inc].name))
+		}
+		b.WriteString(l + "\n")
+	}
+	for ; inc < len(s.includes); inc++ {
+		b.WriteString(semanticComment(IncludePart, s.includes[inc].name))
+	}
+
+	return b.String()
+}
+
+// WriteSnippetFile writes the snippet to the writer in the snippet file
+// format. The semantic comments are written first, in this order: notes,
+// imports, expects, follows, related snippets (seealso), status,
+// deprecation, Go version (since), any parts added by RegisterPart and
+// tags, with the tags in alphabetical order of tag name. The text follows
+// the comments, with any include comments in their place in the text.
+// Parsing the output gives a snippet which Matches this one and has the
+// same text. This is the format that ReformatFile uses.
+func (s S) WriteSnippetFile(w io.Writer) error {
+	_, err := io.WriteString(w, s.canonical())
+	return err
+}
+
+// containsString returns true if the string is in the slice
+func containsString(slc []string, s string) bool {
+	for _, str := range slc {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSameSnippet returns an error if the two snippets differ in any part,
+// including the text.
+func checkSameSnippet(s, other *S) error {
+	if err := s.Matches(*other); err != nil {
+		return err
+	}
+	for i, inc := range s.includes {
+		if other.includes[i].line != inc.line {
+			return fmt.Errorf("the place of include %q differs:"+
+				" this: line %d, other: line %d",
+				inc.name, inc.line, other.includes[i].line)
+		}
+	}
+	return cmpSlice("text", s.text, other.text, dfltMaxDiffs)
+}
+
+// checkCanReformat returns an error if the snippet file content has lines
+// which would be lost if it were rewritten in the canonical format. These
+// are the begin and end markers and the skip comments, which mean that
+// some of the lines in the file are not part of the snippet text.
+func checkCanReformat(content []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Split(scanLines)
+	for scanner.Scan() {
+		l := scanner.Text()
+		if beginRE.MatchString(l) || endRE.MatchString(l) {
+			return fmt.Errorf("it has snippet %s or %s markers",
+				BeginMarker, EndMarker)
+		}
+		if skipRE.MatchString(l) {
+			return fmt.Errorf("it has snippet %s comments", SkipMarker)
+		}
+	}
+	return nil
+}
+
+// ReformatFile reads the snippet file and rewrites it in the canonical
+// format (see the canonical method for details). The file is only written
+// if its content would change and the returned bool reports whether it
+// was. Before the file is written the new content is parsed and checked
+// against the original snippet; if the meaning of the snippet would change
+// an error is returned and the file is left unchanged. A file with begin
+// and end markers or skip comments is never rewritten, as the lines
+// outside the markers or skipped would be lost, and an error is returned.
+// Nor is a file with unknown snippet comments; they would be lost too and
+// the error reports them.
+func ReformatFile(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	sName := filepath.Base(path)
+	s, err := parseSnippet(content, path, sName)
+	if err != nil {
+		return false, err
+	}
+	if err = checkCanReformat(content); err != nil {
+		return false, fmt.Errorf("cannot reformat snippet %q: %w", path, err)
+	}
+	if warns := s.ParseWarnings(); len(warns) > 0 {
+		msgs := make([]string, 0, len(warns))
+		for _, w := range warns {
+			msgs = append(msgs, w.Error())
+		}
+		return false, fmt.Errorf("cannot reformat snippet %q: %s",
+			path, strings.Join(msgs, "; "))
+	}
+
+	newContent := s.canonical()
+	if newContent == string(content) {
+		return false, nil
+	}
+
+	newS, err := parseSnippet([]byte(newContent), path, sName)
+	if err != nil {
+		return false, fmt.Errorf("cannot reformat snippet %q: %w", path, err)
+	}
+	if err = checkSameSnippet(s, newS); err != nil {
+		return false, fmt.Errorf(
+			"cannot reformat snippet %q, the meaning would change: %w",
+			path, err)
+	}
+
+	if err = os.WriteFile(path, []byte(newContent), info.Mode()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
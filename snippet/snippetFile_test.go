@@ -0,0 +1,163 @@
+package snippet
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+func TestReformatFile(t *testing.T) {
+	dir := t.TempDir()
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		content    string
+		expChanged bool
+		expContent string
+	}{
+		{
+			ID: testhelper.MkID("already canonical"),
+			content: "// snippet: note: a note\n" +
+				"// snippet: imports: fmt\n" +
+				`fmt.Println("Hello")` + "\n",
+			expContent: "// snippet: note: a note\n" +
+				"// snippet: imports: fmt\n" +
+				`fmt.Println("Hello")` + "\n",
+		},
+		{
+			ID: testhelper.MkID("needs reformatting"),
+			content: `fmt.Println("Hello")` + "\n" +
+				"//snippet:Tag:B: b value\n" +
+				"//   snippet: ComesAfter: x\n" +
+				"// SNIPPET: Expect: y\n" +
+				"// snippet: imports: os\n" +
+				"// snippet: imports: fmt\n" +
+				"// snippet: Tag: A: a value\n" +
+				"// snippet: Notes: a note",
+			expChanged: true,
+			expContent: "// snippet: note: a note\n" +
+				"// snippet: imports: fmt\n" +
+				"// snippet: imports: os\n" +
+				"// snippet: expects: y\n" +
+				"// snippet: follows: x\n" +
+				"// snippet: tag: A: a value\n" +
+				"// snippet: tag: B: b value\n" +
+				`fmt.Println("Hello")` + "\n",
+		},
+		{
+			ID: testhelper.MkID("bad snippet"),
+			ExpErr: testhelper.MkExpErr(
+				"has no text and no imports"),
+			content:    "// snippet: note: a note\n",
+			expContent: "// snippet: note: a note\n",
+		},
+		{
+			ID: testhelper.MkID("begin and end markers"),
+			ExpErr: testhelper.MkExpErr(
+				"it has snippet begin or end markers"),
+			content: "package main\n\nfunc main() {\n" +
+				"// snippet: begin\n\tx := 1\n// snippet: end\n}\n",
+			expContent: "package main\n\nfunc main() {\n" +
+				"// snippet: begin\n\tx := 1\n// snippet: end\n}\n",
+		},
+		{
+			ID:         testhelper.MkID("skip comment"),
+			ExpErr:     testhelper.MkExpErr("it has snippet skip comments"),
+			content:    "// snippet: skip\npackage main\nx := 1\n",
+			expContent: "// snippet: skip\npackage main\nx := 1\n",
+		},
+		{
+			ID: testhelper.MkID("unknown snippet comment"),
+			ExpErr: testhelper.MkExpErr(
+				`unknown snippet comment: "// snippet: colour: red"`),
+			content:    "// snippet: colour: red\nx := 1\n",
+			expContent: "// snippet: colour: red\nx := 1\n",
+		},
+	}
+
+	for i, tc := range testCases {
+		fName := filepath.Join(dir, "snippet"+string(rune('A'+i)))
+		writeFile(t, fName, tc.content)
+
+		changed, err := ReformatFile(fName)
+		testhelper.CheckExpErr(t, err, tc)
+		testhelper.DiffBool(t, tc.IDStr(), "changed", changed, tc.expChanged)
+
+		content, err := os.ReadFile(fName)
+		if err != nil {
+			t.Fatal("cannot read the reformatted file: ", err)
+		}
+		testhelper.DiffString(t, tc.IDStr(), "content",
+			string(content), tc.expContent)
+	}
+}
+
+func TestWriteSnippetFile(t *testing.T) {
+	for _, name := range []string{"complete", "expects1", "goodNoExp"} {
+		fName := filepath.Join(TestSnippets, name)
+		content, err := os.ReadFile(fName)
+		if err != nil {
+			t.Fatal("cannot read the snippet: ", err)
+		}
+		s, err := parseSnippet(content, fName, name)
+		if err != nil {
+			t.Fatal("cannot parse the snippet: ", err)
+		}
+
+		var buf bytes.Buffer
+		if err := s.WriteSnippetFile(&buf); err != nil {
+			t.Log(name)
+			t.Errorf("\t: cannot write the snippet: %s", err)
+			continue
+		}
+
+		written, err := parseSnippet(buf.Bytes(), fName, name)
+		if err != nil {
+			t.Log(name)
+			t.Errorf("\t: cannot parse the written snippet: %s", err)
+			continue
+		}
+		if err := checkSameSnippet(s, written); err != nil {
+			t.Log(name)
+			t.Errorf("\t: the written snippet differs: %s", err)
+		}
+	}
+
+	const withIncludes = "// snippet: include: a\n" +
+		"x()\n" +
+		"// snippet: include: b, c\n" +
+		"y()\n" +
+		"// snippet: include: d\n"
+	s, err := parseSnippet([]byte(withIncludes), "inc", "inc")
+	if err != nil {
+		t.Fatal("cannot parse the snippet: ", err)
+	}
+	var buf bytes.Buffer
+	if err := s.WriteSnippetFile(&buf); err != nil {
+		t.Fatal("cannot write the snippet: ", err)
+	}
+	testhelper.DiffString(t, "includes", "written snippet",
+		buf.String(), "// snippet: include: a\n"+
+			"x()\n"+
+			"// snippet: include: b\n"+
+			"// snippet: include: c\n"+
+			"y()\n"+
+			"// snippet: include: d\n")
+
+	err = S{name: "x", text: []string{"x()"}}.WriteSnippetFile(errWriter{})
+	if err == nil {
+		t.Error("a write error should be reported")
+	}
+}
+
+// errWriter is an io.Writer which always fails
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("cannot write")
+}
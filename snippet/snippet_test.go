@@ -1,10 +1,16 @@
 package snippet
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
+	"html"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/nickwells/testhelper.mod/v2/testhelper"
 )
@@ -20,16 +26,18 @@ var (
 	BadDir           = filepath.Join("testdata", "bad.dir")
 	UnreadableSubDir = filepath.Join("testdata", "bad.dir", "unreadable.dir")
 
-	TestSnippets = filepath.Join("testdata", "test.snippets")
+	TestSnippets  = filepath.Join("testdata", "test.snippets")
+	GraphSnippets = filepath.Join("testdata", "graph.snippets")
 )
 
 func TestCmpSlice(t *testing.T) {
 	testCases := []struct {
 		testhelper.ID
-		s1     []string
-		s2     []string
-		name   string
-		expErr error
+		s1       []string
+		s2       []string
+		name     string
+		maxDiffs int
+		expErr   error
 	}{
 		{
 			ID:   testhelper.MkID("empty slices - no err"),
@@ -52,27 +60,50 @@ func TestCmpSlice(t *testing.T) {
 	the lengths differ: 3 != 2`),
 		},
 		{
-			ID:   testhelper.MkID("same length, different content"),
-			s1:   []string{"Hello", "World"},
-			s2:   []string{"Bonjour", "le Monde"},
-			name: "different content slices",
+			ID:       testhelper.MkID("same length, different content"),
+			s1:       []string{"Hello", "World"},
+			s2:       []string{"Bonjour", "le Monde"},
+			name:     "different content slices",
+			maxDiffs: 1,
 			expErr: errors.New(`different content slices differs:
 	entry[0] differs: "Hello" != "Bonjour"
 	an additional difference was found`),
 		},
 		{
-			ID:   testhelper.MkID("same length, different content (2 diffs)"),
+			ID:       testhelper.MkID("same length, different content (2 diffs)"),
+			s1:       []string{"Hello", "World", "and other things"},
+			s2:       []string{"Bonjour", "le Monde", "etc"},
+			name:     "different content slices",
+			maxDiffs: 1,
+			expErr: errors.New(`different content slices differs:
+	entry[0] differs: "Hello" != "Bonjour"
+	2 additional differences were found`),
+		},
+		{
+			ID:       testhelper.MkID("different content, show 2 diffs"),
+			s1:       []string{"Hello", "World", "and other things"},
+			s2:       []string{"Bonjour", "le Monde", "etc"},
+			name:     "different content slices",
+			maxDiffs: 2,
+			expErr: errors.New(`different content slices differs:
+	entry[0] differs: "Hello" != "Bonjour"
+	entry[1] differs: "World" != "le Monde"
+	an additional difference was found`),
+		},
+		{
+			ID:   testhelper.MkID("different content, show all diffs"),
 			s1:   []string{"Hello", "World", "and other things"},
 			s2:   []string{"Bonjour", "le Monde", "etc"},
 			name: "different content slices",
 			expErr: errors.New(`different content slices differs:
 	entry[0] differs: "Hello" != "Bonjour"
-	2 additional differences were found`),
+	entry[1] differs: "World" != "le Monde"
+	entry[2] differs: "and other things" != "etc"`),
 		},
 	}
 
 	for _, tc := range testCases {
-		err := cmpSlice(tc.name, tc.s1, tc.s2)
+		err := cmpSlice(tc.name, tc.s1, tc.s2, tc.maxDiffs)
 		testhelper.DiffErr(t, tc.IDStr(), "error", err, tc.expErr)
 	}
 }
@@ -134,7 +165,66 @@ func TestCmpTags(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		err := cmpTags(tc.t1, tc.t2)
+		err := cmpTags(tc.t1, tc.t2, dfltMaxDiffs)
+		testhelper.DiffErr(t, tc.IDStr(), "error", err, tc.expErr)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	s1 := S{name: "n", imports: []string{"a", "b"}, text: []string{"x"}}
+
+	testCases := []struct {
+		testhelper.ID
+		other    S
+		expEqual bool
+	}{
+		{
+			ID:       testhelper.MkID("same"),
+			other:    S{name: "n", imports: []string{"a", "b"}},
+			expEqual: true,
+		},
+		{
+			ID:    testhelper.MkID("imports differ"),
+			other: S{name: "n", imports: []string{"a"}},
+		},
+		{
+			ID:    testhelper.MkID("names differ"),
+			other: S{name: "m", imports: []string{"a", "b"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		testhelper.DiffBool(t, tc.IDStr(), "equal",
+			s1.Equal(tc.other), tc.expEqual)
+	}
+}
+
+func TestMatchesMaxDiffs(t *testing.T) {
+	s1 := S{name: "n", imports: []string{"a", "b", "c"}}
+	s2 := S{name: "n", imports: []string{"x", "y", "c"}}
+
+	testCases := []struct {
+		testhelper.ID
+		opts   []MatchOpt
+		expErr error
+	}{
+		{
+			ID: testhelper.MkID("default"),
+			expErr: errors.New(`imports differs:
+	entry[0] differs: "a" != "x"
+	an additional difference was found`),
+		},
+		{
+			ID:   testhelper.MkID("all differences"),
+			opts: []MatchOpt{MaxDiffs(0)},
+			expErr: errors.New(`imports differs:
+	entry[0] differs: "a" != "x"
+	entry[1] differs: "b" != "y"`),
+		},
+	}
+
+	for _, tc := range testCases {
+		err := s1.Matches(s2, tc.opts...)
 		testhelper.DiffErr(t, tc.IDStr(), "error", err, tc.expErr)
 	}
 }
@@ -175,6 +265,42 @@ func TestTidySlice(t *testing.T) {
 	}
 }
 
+func TestCheckNameIsConfined(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		sName string
+	}{
+		{ID: testhelper.MkID("simple"), sName: "hw"},
+		{ID: testhelper.MkID("sub-directory"), sName: "net/client"},
+		{ID: testhelper.MkID("climbs back down"), sName: "net/../hw"},
+		{ID: testhelper.MkID("absolute"), sName: "/etc/passwd"},
+		{ID: testhelper.MkID("dots in the name"), sName: "..hw"},
+		{
+			ID:    testhelper.MkID("parent"),
+			sName: "..",
+			ExpErr: testhelper.MkExpErr(
+				`snippet name ".." is outside the snippet directory`),
+		},
+		{
+			ID:    testhelper.MkID("escapes"),
+			sName: "../../etc/passwd",
+			ExpErr: testhelper.MkExpErr(`snippet name "../../etc/passwd"` +
+				` is outside the snippet directory`),
+		},
+		{
+			ID:    testhelper.MkID("escapes after cleaning"),
+			sName: "net/../../hw",
+			ExpErr: testhelper.MkExpErr(`snippet name "net/../../hw"` +
+				` is outside the snippet directory`),
+		},
+	}
+
+	for _, tc := range testCases {
+		testhelper.CheckExpErr(t, checkNameIsConfined(tc.sName), tc)
+	}
+}
+
 func TestReadSnippetFile(t *testing.T) {
 	wd, err := os.Getwd()
 	if err != nil {
@@ -231,8 +357,1129 @@ func TestReadSnippetFile(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		_, fname, err := readSnippetFile(tc.dirs, tc.sName)
+		_, fname, err := readSnippetFile(nil, tc.dirs, tc.sName)
 		testhelper.DiffString(t, tc.IDStr(), "error", fname, tc.expFName)
 		testhelper.DiffErr(t, tc.IDStr(), "error", err, tc.expErr)
 	}
 }
+
+func TestTrimmedText(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		text    []string
+		expText []string
+	}{
+		{
+			ID:      testhelper.MkID("no text"),
+			expText: []string{},
+		},
+		{
+			ID:      testhelper.MkID("nothing to trim"),
+			text:    []string{"a := 1", "", "\tb := a"},
+			expText: []string{"a := 1", "", "\tb := a"},
+		},
+		{
+			ID:      testhelper.MkID("trailing white space"),
+			text:    []string{"a := 1 \t", "  \t ", "\tb := a  "},
+			expText: []string{"a := 1", "", "\tb := a"},
+		},
+	}
+
+	for _, tc := range testCases {
+		s := S{text: tc.text}
+		testhelper.DiffStringSlice(t, tc.IDStr(), "trimmed text",
+			s.TrimmedText(), tc.expText)
+		testhelper.DiffStringSlice(t, tc.IDStr(), "untrimmed text",
+			s.Text(), tc.text)
+	}
+}
+
+func TestParse(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join(TestSnippets, "complete"))
+	if err != nil {
+		t.Fatal("cannot read the snippet: ", err)
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		r io.Reader
+	}{
+		{
+			ID: testhelper.MkID("good"),
+			r:  bytes.NewReader(content),
+		},
+		{
+			ID: testhelper.MkID("no text and no imports"),
+			r:  strings.NewReader("// snippet: note: nothing here\n"),
+			ExpErr: testhelper.MkExpErr(`snippet "name" (path)` +
+				` has no text and no imports`),
+		},
+		{
+			ID: testhelper.MkID("bad reader"),
+			r:  iotest.ErrReader(errors.New("broken")),
+			ExpErr: testhelper.MkExpErr(
+				`cannot read snippet "name": broken`),
+		},
+	}
+
+	expS, err := parseSnippet(content, "path", "name")
+	if err != nil {
+		t.Fatal("cannot parse the snippet: ", err)
+	}
+
+	for _, tc := range testCases {
+		s, err := Parse(tc.r, "name", "path")
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			if err := s.Matches(*expS); err != nil {
+				t.Log(tc.IDStr())
+				t.Errorf("\t: unexpected snippet: %s", err)
+			}
+			testhelper.DiffStringSlice(t, tc.IDStr(), "text",
+				s.Text(), expS.Text())
+		}
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	content := []byte("same()\n")
+	const expHash = "daeb837edcb419d435c2073ec13e6400"
+
+	testhelper.DiffString(t, "ContentHash", "hash",
+		ContentHash(content), expHash)
+
+	s, err := parseSnippet(content, "path", "name")
+	if err != nil {
+		t.Fatal("cannot parse the snippet: ", err)
+	}
+	testhelper.DiffString(t, "S.ContentHash", "hash",
+		s.ContentHash(), expHash)
+
+	if ContentHash([]byte("same() \n")) == expHash {
+		t.Error("different content should have a different hash")
+	}
+}
+
+func TestParseSnippetBOM(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join(TestSnippets, "complete"))
+	if err != nil {
+		t.Fatal("cannot read the snippet: ", err)
+	}
+	withBOM := append([]byte{0xef, 0xbb, 0xbf}, content...)
+
+	s, err := parseSnippet(content, "path", "name")
+	if err != nil {
+		t.Fatal("cannot parse the snippet: ", err)
+	}
+	sBOM, err := parseSnippet(withBOM, "path", "name")
+	if err != nil {
+		t.Fatal("cannot parse the snippet with a BOM: ", err)
+	}
+	if err = s.Matches(*sBOM); err != nil {
+		t.Error("the snippet with a BOM differs: ", err)
+	}
+	testhelper.DiffStringSlice(t, "BOM", "text", sBOM.Text(), s.Text())
+}
+
+func TestParseSnippetLists(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		content    string
+		expImports []string
+		expExpects []string
+		expFollows []string
+	}{
+		{
+			ID: testhelper.MkID("one per line"),
+			content: "// snippet: imports: os\n" +
+				"// snippet: imports: fmt\n" +
+				"// snippet: expects: a\n" +
+				"// snippet: follows: b\n" +
+				"x()\n",
+			expImports: []string{"fmt", "os"},
+			expExpects: []string{"a", "b"},
+			expFollows: []string{"b"},
+		},
+		{
+			ID: testhelper.MkID("comma-separated"),
+			content: "// snippet: imports: fmt, os,strings\n" +
+				"// snippet: expects: a, c\n" +
+				"// snippet: follows: b ,d\n" +
+				"x()\n",
+			expImports: []string{"fmt", "os", "strings"},
+			expExpects: []string{"a", "b", "c", "d"},
+			expFollows: []string{"b", "d"},
+		},
+		{
+			ID: testhelper.MkID("aliases and empty entries"),
+			content: "// snippet: imports: f fmt, , o \"os\",\n" +
+				"x()\n",
+			expImports: []string{"f fmt", `o "os"`},
+			expExpects: []string{},
+			expFollows: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		s, err := parseSnippet([]byte(tc.content), "path", "name")
+		if err != nil {
+			t.Log(tc.IDStr())
+			t.Error("\t: cannot parse the snippet: ", err)
+			continue
+		}
+		testhelper.DiffStringSlice(t, tc.IDStr(), "imports",
+			s.Imports(), tc.expImports)
+		testhelper.DiffStringSlice(t, tc.IDStr(), "expects",
+			s.Expects(), tc.expExpects)
+		testhelper.DiffStringSlice(t, tc.IDStr(), "follows",
+			s.Follows(), tc.expFollows)
+	}
+}
+
+func TestParseSnippetMarkers(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		content     string
+		expText     []string
+		expImports  []string
+		expOffset   int
+		expWarnings int
+	}{
+		{
+			ID: testhelper.MkID("no markers"),
+			content: "package main\n" +
+				"// snippet: imports: fmt\n" +
+				"fmt.Println()\n",
+			expText:    []string{"package main", "fmt.Println()"},
+			expImports: []string{"fmt"},
+			expOffset:  0,
+		},
+		{
+			ID: testhelper.MkID("with markers"),
+			content: "package main\n" +
+				"// snippet: begin\n" +
+				"fmt.Println()\n" +
+				"// snippet: imports: fmt\n" +
+				"// snippet: end\n" +
+				"func main() {}\n",
+			expText:    []string{"fmt.Println()"},
+			expImports: []string{"fmt"},
+			expOffset:  31,
+		},
+		{
+			ID: testhelper.MkID("several regions, mixed case"),
+			content: "a\n" +
+				"// Snippet: BEGIN\n" +
+				"b\n" +
+				"//snippet:end\n" +
+				"c\n" +
+				"// snippet: begin \n" +
+				"d\n",
+			expText:    []string{"b", "d"},
+			expImports: []string{},
+			expOffset:  20,
+		},
+		{
+			ID: testhelper.MkID("unmatched markers"),
+			content: "// snippet: end\n" +
+				"a\n" +
+				"// snippet: begin\n" +
+				"// snippet: begin\n" +
+				"b\n",
+			expText:     []string{"b"},
+			expImports:  []string{},
+			expOffset:   54,
+			expWarnings: 2,
+		},
+		{
+			ID: testhelper.MkID("empty region, imports only"),
+			content: "a\n" +
+				"// snippet: begin\n" +
+				"// snippet: end\n" +
+				"// snippet: imports: os\n",
+			expImports: []string{"os"},
+			expOffset:  -1,
+		},
+	}
+
+	for _, tc := range testCases {
+		s, err := parseSnippet([]byte(tc.content), "path", "name")
+		if err != nil {
+			t.Log(tc.IDStr())
+			t.Error("\t: cannot parse the snippet: ", err)
+			continue
+		}
+		testhelper.DiffStringSlice(t, tc.IDStr(), "text",
+			s.Text(), tc.expText)
+		testhelper.DiffStringSlice(t, tc.IDStr(), "imports",
+			s.Imports(), tc.expImports)
+		testhelper.DiffInt(t, tc.IDStr(), "text offset",
+			s.TextOffset(), tc.expOffset)
+		testhelper.DiffInt(t, tc.IDStr(), "parse warnings",
+			len(s.ParseWarnings()), tc.expWarnings)
+	}
+}
+
+func TestParseSnippetSkip(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		content string
+		expText []string
+	}{
+		{
+			ID: testhelper.MkID("skip one line"),
+			content: "// snippet: skip\n" +
+				"package main\n" +
+				"a\n",
+			expText: []string{"a"},
+		},
+		{
+			ID: testhelper.MkID("skip several lines"),
+			content: "// snippet: skip: 2\n" +
+				"package main\n" +
+				"// snippet: imports: fmt\n" +
+				"import \"fmt\"\n" +
+				"a\n",
+			expText: []string{"a"},
+		},
+		{
+			ID: testhelper.MkID("skip within markers"),
+			content: "package main\n" +
+				"// snippet: begin\n" +
+				"a\n" +
+				"// snippet: Skip:\n" +
+				"b\n" +
+				"c\n" +
+				"// snippet: end\n",
+			expText: []string{"a", "c"},
+		},
+		{
+			ID: testhelper.MkID("skip past an end marker"),
+			content: "// snippet: begin\n" +
+				"a\n" +
+				"// snippet: skip: 2\n" +
+				"b\n" +
+				"// snippet: end\n" +
+				"c\n" +
+				"d\n",
+			expText: []string{"a"},
+		},
+		{
+			ID: testhelper.MkID("skip everything"),
+			content: "// snippet: skip: 5\n" +
+				"a\n",
+			ExpErr: testhelper.MkExpErr("has no text and no imports"),
+		},
+		{
+			ID: testhelper.MkID("bad count"),
+			content: "// snippet: skip: none\n" +
+				"a\n",
+			ExpErr: testhelper.MkExpErr(`snippet "name" (path) line 1:`,
+				`bad skip count: "none" (it should be a positive number)`),
+		},
+		{
+			ID: testhelper.MkID("zero count"),
+			content: "// snippet: skip: 0\n" +
+				"a\n",
+			ExpErr: testhelper.MkExpErr(`bad skip count: "0"`),
+		},
+	}
+
+	for _, tc := range testCases {
+		s, err := parseSnippet([]byte(tc.content), "path", "name")
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffStringSlice(t, tc.IDStr(), "text",
+				s.Text(), tc.expText)
+		}
+	}
+}
+
+func TestParseSnippetLineEndings(t *testing.T) {
+	dir := filepath.Join("testdata", "lineEnding.snippets")
+	content, err := os.ReadFile(filepath.Join(dir, "lf"))
+	if err != nil {
+		t.Fatal("cannot read the snippet: ", err)
+	}
+	expS, err := parseSnippet(content, "path", "name")
+	if err != nil {
+		t.Fatal("cannot parse the snippet: ", err)
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		fName     string
+		expOffset int
+	}{
+		{
+			ID:        testhelper.MkID("CRLF"),
+			fName:     "crlf",
+			expOffset: 83,
+		},
+		{
+			ID:        testhelper.MkID("CRLF with a BOM"),
+			fName:     "crlf.bom",
+			expOffset: 86,
+		},
+		{
+			ID:        testhelper.MkID("CRLF with no final line ending"),
+			fName:     "crlf.noFinalEOL",
+			expOffset: 83,
+		},
+		{
+			ID:        testhelper.MkID("CR"),
+			fName:     "cr",
+			expOffset: 80,
+		},
+	}
+
+	for _, tc := range testCases {
+		content, err := os.ReadFile(filepath.Join(dir, tc.fName))
+		if err != nil {
+			t.Fatal("cannot read the snippet: ", err)
+		}
+		s, err := parseSnippet(content, "path", "name")
+		if err != nil {
+			t.Log(tc.IDStr())
+			t.Error("\t: cannot parse the snippet: ", err)
+			continue
+		}
+		if err := s.Matches(*expS); err != nil {
+			t.Log(tc.IDStr())
+			t.Error("\t: the snippet differs: ", err)
+		}
+		testhelper.DiffStringSlice(t, tc.IDStr(), "text",
+			s.Text(), expS.Text())
+		testhelper.DiffInt(t, tc.IDStr(), "parse warnings",
+			len(s.ParseWarnings()), 0)
+		testhelper.DiffInt(t, tc.IDStr(), "text offset",
+			s.TextOffset(), tc.expOffset)
+	}
+}
+
+func TestScanLines(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		content  string
+		expLines []string
+	}{
+		{
+			ID:       testhelper.MkID("empty"),
+			expLines: []string{},
+		},
+		{
+			ID:       testhelper.MkID("mixed line endings"),
+			content:  "a\nb\r\nc\rd",
+			expLines: []string{"a", "b", "c", "d"},
+		},
+		{
+			ID:       testhelper.MkID("blank lines"),
+			content:  "\r\n\r\r\n\n",
+			expLines: []string{"", "", "", ""},
+		},
+		{
+			ID:       testhelper.MkID("final CR"),
+			content:  "a\r",
+			expLines: []string{"a"},
+		},
+	}
+
+	for _, tc := range testCases {
+		for _, bufSize := range []int{1, 2, 64} {
+			scanner := bufio.NewScanner(
+				iotest.OneByteReader(strings.NewReader(tc.content)))
+			scanner.Buffer(make([]byte, bufSize), 64)
+			scanner.Split(scanLines)
+			lines := []string{}
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+			testhelper.DiffStringSlice(t, tc.IDStr(), "lines",
+				lines, tc.expLines)
+		}
+	}
+}
+
+func TestParseSnippetStatus(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		content   string
+		expStatus string
+	}{
+		{
+			ID:      testhelper.MkID("no status"),
+			content: "x()\n",
+		},
+		{
+			ID:        testhelper.MkID("status"),
+			content:   "// snippet: status: deprecated\nx()\n",
+			expStatus: StatusDeprecated,
+		},
+		{
+			ID: testhelper.MkID("status, mixed case, repeated"),
+			content: "// snippet: Status: Stable\n" +
+				"// snippet: status: Stable\n" +
+				"x()\n",
+			expStatus: StatusStable,
+		},
+		{
+			ID: testhelper.MkID("two statuses"),
+			content: "// snippet: status: stable\n" +
+				"// snippet: status: experimental\n" +
+				"x()\n",
+			ExpErr: testhelper.MkExpErr(`snippet "name" (path)` +
+				` has more than one status: experimental, stable`),
+		},
+		{
+			ID:      testhelper.MkID("unknown status"),
+			content: "// snippet: status: beta\nx()\n",
+			ExpErr: testhelper.MkExpErr(`snippet "name" (path)` +
+				` has an unknown status: "beta"`),
+		},
+	}
+
+	for _, tc := range testCases {
+		s, err := parseSnippet([]byte(tc.content), "path", "name")
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffString(t, tc.IDStr(), "status",
+				s.Status(), tc.expStatus)
+		}
+	}
+}
+
+func TestParseSnippetSince(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		content  string
+		expSince string
+	}{
+		{
+			ID:      testhelper.MkID("no version"),
+			content: "x()\n",
+		},
+		{
+			ID:       testhelper.MkID("minor version"),
+			content:  "// snippet: since: 1.18\nx()\n",
+			expSince: "1.18",
+		},
+		{
+			ID: testhelper.MkID("patch version, repeated"),
+			content: "// snippet: since: 1.21.3\n" +
+				"// snippet: Since: 1.21.3\n" +
+				"x()\n",
+			expSince: "1.21.3",
+		},
+		{
+			ID: testhelper.MkID("two versions"),
+			content: "// snippet: since: 1.18\n" +
+				"// snippet: since: 1.21\n" +
+				"x()\n",
+			ExpErr: testhelper.MkExpErr(`snippet "name" (path)` +
+				` has more than one Go version: 1.18, 1.21`),
+		},
+		{
+			ID:      testhelper.MkID("bad version"),
+			content: "// snippet: since: go1.18\nx()\n",
+			ExpErr: testhelper.MkExpErr(`snippet "name" (path)` +
+				` has a bad Go version: "go1.18"`),
+		},
+		{
+			ID:      testhelper.MkID("bad version, major only"),
+			content: "// snippet: since: 1\nx()\n",
+			ExpErr: testhelper.MkExpErr(`snippet "name" (path)` +
+				` has a bad Go version: "1"`),
+		},
+	}
+
+	noVersion, err := parseSnippet([]byte("x()\n"), "path", "name")
+	if err != nil {
+		t.Fatal("cannot parse the snippet: ", err)
+	}
+
+	for _, tc := range testCases {
+		s, err := parseSnippet([]byte(tc.content), "path", "name")
+		if testhelper.CheckExpErr(t, err, tc) && err == nil {
+			testhelper.DiffString(t, tc.IDStr(), "since",
+				s.Since(), tc.expSince)
+			testhelper.DiffBool(t, tc.IDStr(), "matches no version",
+				s.Matches(*noVersion) == nil, tc.expSince == "")
+		}
+	}
+}
+
+func TestParseSnippetDeprecated(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		content       string
+		expDeprecated bool
+		expMsg        string
+	}{
+		{
+			ID:      testhelper.MkID("not deprecated"),
+			content: "x()\n",
+		},
+		{
+			ID:            testhelper.MkID("deprecated, no message"),
+			content:       "// snippet: deprecated:\nx()\n",
+			expDeprecated: true,
+		},
+		{
+			ID: testhelper.MkID("deprecated, with message"),
+			content: "// snippet: deprecated: use y\n" +
+				"// snippet: deprecated: instead\n" +
+				"x()\n",
+			expDeprecated: true,
+			expMsg:        "use y instead",
+		},
+		{
+			ID:            testhelper.MkID("deprecated status"),
+			content:       "// snippet: status: deprecated\nx()\n",
+			expDeprecated: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		s, err := parseSnippet([]byte(tc.content), "path", "name")
+		if err != nil {
+			t.Log(tc.IDStr())
+			t.Errorf("\t: unexpected error: %s", err)
+			continue
+		}
+		isDeprecated, msg := s.Deprecated()
+		testhelper.DiffBool(t, tc.IDStr(), "deprecated",
+			isDeprecated, tc.expDeprecated)
+		testhelper.DiffString(t, tc.IDStr(), "message", msg, tc.expMsg)
+
+		other, err := parseSnippet([]byte(s.canonical()), "path", "name")
+		if err != nil {
+			t.Log(tc.IDStr())
+			t.Errorf("\t: cannot parse the canonical snippet: %s", err)
+			continue
+		}
+		if err := other.Matches(*s); err != nil {
+			t.Log(tc.IDStr())
+			t.Errorf("\t: the canonical snippet differs: %s", err)
+		}
+	}
+}
+
+func TestParseSnippetWarnings(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		content  string
+		expWarns []string
+	}{
+		{
+			ID: testhelper.MkID("no warnings"),
+			content: "// snippet: note: a note\n" +
+				"// snippet: tag: t: v\n" +
+				"x()\n",
+			expWarns: []string{},
+		},
+		{
+			ID: testhelper.MkID("alternative part names"),
+			content: "// snippet: notes: a note\n" +
+				"// snippet: import: fmt\n" +
+				"// snippet: comesafter: other\n" +
+				"// snippet: see-also: another\n" +
+				"x()\n",
+			expWarns: []string{},
+		},
+		{
+			ID: testhelper.MkID("unknown parts"),
+			content: "// snippet: note: a note\n" +
+				"// snippet: imprt: fmt\n" +
+				"x()\n" +
+				"// snippet:\n",
+			expWarns: []string{
+				`path:2: unknown snippet comment: "// snippet: imprt: fmt"`,
+				`path:4: unknown snippet comment: "// snippet:"`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		s, err := parseSnippet([]byte(tc.content), "path", "name")
+		if err != nil {
+			t.Log(tc.IDStr())
+			t.Errorf("\t: unexpected error: %s", err)
+			continue
+		}
+		warns := []string{}
+		for _, w := range s.ParseWarnings() {
+			warns = append(warns, w.Error())
+		}
+		testhelper.DiffStringSlice(t, tc.IDStr(), "warnings",
+			warns, tc.expWarns)
+	}
+}
+
+func TestImportsBeyond(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		imports  []string
+		existing []string
+		expVal   []string
+	}{
+		{
+			ID:       testhelper.MkID("no imports"),
+			existing: []string{"fmt"},
+			expVal:   []string{},
+		},
+		{
+			ID:      testhelper.MkID("nothing existing"),
+			imports: []string{"os", "fmt"},
+			expVal:  []string{"fmt", "os"},
+		},
+		{
+			ID:       testhelper.MkID("some existing"),
+			imports:  []string{"fmt", "os", "strings"},
+			existing: []string{"os", `"fmt"`, "io"},
+			expVal:   []string{"strings"},
+		},
+		{
+			ID:       testhelper.MkID("aliases"),
+			imports:  []string{`f "fmt"`, `ex "os/exec"`, "io"},
+			existing: []string{"fmt", `myio "io"`},
+			expVal:   []string{`ex "os/exec"`},
+		},
+	}
+
+	for _, tc := range testCases {
+		s := S{imports: tc.imports}
+		testhelper.DiffStringSlice(t, tc.IDStr(), "imports",
+			s.ImportsBeyond(tc.existing), tc.expVal)
+	}
+}
+
+func TestProject(t *testing.T) {
+	full := S{
+		name:    "name",
+		path:    "path",
+		text:    []string{"text"},
+		docs:    []string{"docs"},
+		imports: []string{"imports"},
+		expects: []string{"expects", "follows"},
+		follows: []string{"follows"},
+		seeAlso: []string{"seeAlso"},
+		tags: map[string][]string{
+			"T1": {"v1"},
+			"T2": {"v2", "v2a"},
+		},
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		parts []string
+		expS  S
+	}{
+		{
+			ID:   testhelper.MkID("nothing"),
+			expS: S{tags: map[string][]string{}},
+		},
+		{
+			ID: testhelper.MkID("everything"),
+			parts: []string{
+				NamePart, PathPart, TextPart, DocsPart, ImportPart,
+				ExpectPart, FollowPart, SeeAlsoPart, TagPart,
+			},
+			expS: full,
+		},
+		{
+			ID: testhelper.MkID("metadata and a named tag"),
+			parts: []string{
+				NamePart, DocsPart, ImportPart, TagStr + "T2", TagStr + "T3",
+			},
+			expS: S{
+				name:    "name",
+				docs:    []string{"docs"},
+				imports: []string{"imports"},
+				tags: map[string][]string{
+					"T2": {"v2", "v2a"},
+				},
+			},
+		},
+		{
+			ID:    testhelper.MkID("bad part"),
+			parts: []string{NamePart, "imprts"},
+			ExpErr: testhelper.MkExpErr(
+				`"imprts" is not a valid pre-defined part of a snippet`),
+		},
+		{
+			ID:    testhelper.MkID("tag name without the tag prefix"),
+			parts: []string{"T2"},
+			ExpErr: testhelper.MkExpErr(
+				`"T2" is not a valid pre-defined part of a snippet`),
+		},
+	}
+
+	for _, tc := range testCases {
+		p, err := full.Project(tc.parts...)
+		if !testhelper.CheckExpErr(t, err, tc) || err != nil {
+			continue
+		}
+		if err := p.Matches(tc.expS); err != nil {
+			t.Log(tc.IDStr())
+			t.Errorf("\t: unexpected projection: %s", err)
+		}
+		testhelper.DiffStringSlice(t, tc.IDStr(), "text",
+			p.Text(), tc.expS.Text())
+	}
+}
+
+func TestClone(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join(TestSnippets, "complete"))
+	if err != nil {
+		t.Fatal("cannot read the snippet: ", err)
+	}
+	s, err := parseSnippet(content, "path", "name")
+	if err != nil {
+		t.Fatal("cannot parse the snippet: ", err)
+	}
+	orig, err := parseSnippet(content, "path", "name")
+	if err != nil {
+		t.Fatal("cannot parse the snippet: ", err)
+	}
+
+	c := s.Clone()
+	if err := c.Matches(*s); err != nil {
+		t.Error("the clone differs from the original: ", err)
+	}
+	testhelper.DiffStringSlice(t, "clone", "text", c.Text(), s.Text())
+
+	c.text[0] = "changed"
+	c.docs[0] = "changed"
+	c.expects[0] = "changed"
+	c.imports[0] = "changed"
+	c.follows[0] = "changed"
+	c.seeAlso[0] = "changed"
+	c.tags["Author"][0] = "changed"
+	c.tags["new"] = []string{"tag"}
+
+	if err := s.Matches(*orig, MaxDiffs(0)); err != nil {
+		t.Error("changing the clone changed the original: ", err)
+	}
+	testhelper.DiffStringSlice(t, "original", "text", s.Text(), orig.Text())
+}
+
+func TestTypedTags(t *testing.T) {
+	s := S{tags: map[string][]string{
+		"priority":     {"3", "4"},
+		"experimental": {"true"},
+		"Author":       {"John Doe"},
+		"empty":        {""},
+	}}
+
+	testCases := []struct {
+		testhelper.ID
+		testhelper.ExpErr
+		key     string
+		getBool bool
+		expOK   bool
+		expInt  int
+		expBool bool
+	}{
+		{
+			ID:     testhelper.MkID("int, first value"),
+			key:    "priority",
+			expOK:  true,
+			expInt: 3,
+		},
+		{
+			ID:  testhelper.MkID("int, missing"),
+			key: "nonesuch",
+		},
+		{
+			ID:    testhelper.MkID("int, bad value"),
+			key:   "Author",
+			expOK: true,
+			ExpErr: testhelper.MkExpErr(
+				`tag "Author": "John Doe" is not an int`),
+		},
+		{
+			ID:      testhelper.MkID("bool"),
+			key:     "experimental",
+			getBool: true,
+			expOK:   true,
+			expBool: true,
+		},
+		{
+			ID:      testhelper.MkID("bool, missing"),
+			key:     "nonesuch",
+			getBool: true,
+		},
+		{
+			ID:      testhelper.MkID("bool, bad value"),
+			key:     "empty",
+			getBool: true,
+			expOK:   true,
+			ExpErr:  testhelper.MkExpErr(`tag "empty": "" is not a bool`),
+		},
+	}
+
+	for _, tc := range testCases {
+		if tc.getBool {
+			b, ok, err := s.TagBool(tc.key)
+			testhelper.CheckExpErr(t, err, tc)
+			testhelper.DiffBool(t, tc.IDStr(), "ok", ok, tc.expOK)
+			testhelper.DiffBool(t, tc.IDStr(), "value", b, tc.expBool)
+			continue
+		}
+		i, ok, err := s.TagInt(tc.key)
+		testhelper.CheckExpErr(t, err, tc)
+		testhelper.DiffBool(t, tc.IDStr(), "ok", ok, tc.expOK)
+		testhelper.DiffInt(t, tc.IDStr(), "value", i, tc.expInt)
+	}
+
+	v, ok := s.TagValue("Author")
+	testhelper.DiffBool(t, "TagValue", "ok", ok, true)
+	testhelper.DiffString(t, "TagValue", "value", v, "John Doe")
+	v, ok = s.TagValue("nonesuch")
+	testhelper.DiffBool(t, "TagValue, missing", "ok", ok, false)
+	testhelper.DiffString(t, "TagValue, missing", "value", v, "")
+}
+
+func TestTagFold(t *testing.T) {
+	s := S{tags: map[string][]string{
+		"Author": {"John Doe"},
+		"AUTHOR": {"Nedd Ludd", "John Barleycorn"},
+		"XXX":    {"YYY"},
+	}}
+
+	testCases := []struct {
+		testhelper.ID
+		key       string
+		expHasTag bool
+		expFound  bool
+		expVals   []string
+	}{
+		{
+			ID:        testhelper.MkID("exact match"),
+			key:       "XXX",
+			expHasTag: true,
+			expFound:  true,
+			expVals:   []string{"YYY"},
+		},
+		{
+			ID:       testhelper.MkID("case differs"),
+			key:      "xxx",
+			expFound: true,
+			expVals:  []string{"YYY"},
+		},
+		{
+			ID:        testhelper.MkID("several matches"),
+			key:       "Author",
+			expHasTag: true,
+			expFound:  true,
+			expVals:   []string{"Nedd Ludd", "John Barleycorn", "John Doe"},
+		},
+		{
+			ID:  testhelper.MkID("no match"),
+			key: "nonesuch",
+		},
+	}
+
+	for _, tc := range testCases {
+		testhelper.DiffBool(t, tc.IDStr(), "HasTag",
+			s.HasTag(tc.key), tc.expHasTag)
+		vals, found := s.TagValueFold(tc.key)
+		testhelper.DiffBool(t, tc.IDStr(), "found", found, tc.expFound)
+		testhelper.DiffStringSlice(t, tc.IDStr(), "values", vals, tc.expVals)
+	}
+}
+
+func TestTagHasValue(t *testing.T) {
+	content := "// snippet: tag: flag\n" +
+		"// snippet: tag: empty:\n" +
+		"// snippet: tag: valued: v\n" +
+		"// snippet: tag: mixed\n" +
+		"// snippet: tag: mixed: m\n" +
+		"// snippet: tag: twice\n" +
+		"// snippet: tag: twice\n" +
+		"x()\n"
+	s, err := parseSnippet([]byte(content), "path", "name")
+	if err != nil {
+		t.Fatal("cannot parse the snippet: ", err)
+	}
+
+	testCases := []struct {
+		testhelper.ID
+		key         string
+		expHasValue bool
+		expVals     []string
+	}{
+		{
+			ID:      testhelper.MkID("no value"),
+			key:     "flag",
+			expVals: []string{""},
+		},
+		{
+			ID:          testhelper.MkID("empty value"),
+			key:         "empty",
+			expHasValue: true,
+			expVals:     []string{""},
+		},
+		{
+			ID:          testhelper.MkID("with value"),
+			key:         "valued",
+			expHasValue: true,
+			expVals:     []string{"v"},
+		},
+		{
+			ID:          testhelper.MkID("with and without value"),
+			key:         "mixed",
+			expHasValue: true,
+			expVals:     []string{"", "m"},
+		},
+		{
+			ID:      testhelper.MkID("no value, repeated"),
+			key:     "twice",
+			expVals: []string{"", ""},
+		},
+		{
+			ID:  testhelper.MkID("no such tag"),
+			key: "nonesuch",
+		},
+	}
+
+	canon, err := parseSnippet([]byte(s.canonical()), "path", "name")
+	if err != nil {
+		t.Fatal("cannot parse the canonical snippet: ", err)
+	}
+	clone := s.Clone()
+	proj, err := s.Project(TagPart)
+	if err != nil {
+		t.Fatal("cannot project the snippet: ", err)
+	}
+
+	for _, tc := range testCases {
+		testhelper.DiffStringSlice(t, tc.IDStr(), "values",
+			s.Tags()[tc.key], tc.expVals)
+		testhelper.DiffBool(t, tc.IDStr(), "TagHasValue",
+			s.TagHasValue(tc.key), tc.expHasValue)
+		testhelper.DiffBool(t, tc.IDStr(), "TagHasValue (canonical)",
+			canon.TagHasValue(tc.key), tc.expHasValue)
+		testhelper.DiffBool(t, tc.IDStr(), "TagHasValue (clone)",
+			clone.TagHasValue(tc.key), tc.expHasValue)
+		testhelper.DiffBool(t, tc.IDStr(), "TagHasValue (projected)",
+			proj.TagHasValue(tc.key), tc.expHasValue)
+	}
+}
+
+func TestImportsOnlyCodeOnly(t *testing.T) {
+	full := S{
+		name:    "name",
+		path:    "path",
+		text:    []string{"text"},
+		docs:    []string{"docs"},
+		imports: []string{"imp1", "imp2"},
+		expects: []string{"expects"},
+		tags:    map[string][]string{"T1": {"v1"}},
+	}
+
+	expImports := S{
+		name:    "name" + ImportsOnlySuffix,
+		path:    "path",
+		imports: []string{"imp1", "imp2"},
+		tags:    map[string][]string{},
+	}
+	imports := full.ImportsOnly()
+	if err := imports.Matches(expImports); err != nil {
+		t.Errorf("unexpected imports-only snippet: %s", err)
+	}
+	testhelper.DiffStringSlice(t, "imports only", "text",
+		imports.Text(), nil)
+
+	expCode := S{
+		name: "name" + CodeOnlySuffix,
+		path: "path",
+		tags: map[string][]string{},
+	}
+	code := full.CodeOnly()
+	if err := code.Matches(expCode); err != nil {
+		t.Errorf("unexpected code-only snippet: %s", err)
+	}
+	testhelper.DiffStringSlice(t, "code only", "text",
+		code.Text(), []string{"text"})
+}
+
+func TestTextStringDocsString(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		text    []string
+		docs    []string
+		expText string
+		expDocs string
+	}{
+		{
+			ID: testhelper.MkID("empty"),
+		},
+		{
+			ID:      testhelper.MkID("one line"),
+			text:    []string{"a()"},
+			docs:    []string{"does a"},
+			expText: "a()\n",
+			expDocs: "does a",
+		},
+		{
+			ID:      testhelper.MkID("many lines"),
+			text:    []string{"a()", "", "b()"},
+			docs:    []string{"does a", "then b"},
+			expText: "a()\n\nb()\n",
+			expDocs: "does a\nthen b",
+		},
+	}
+
+	for _, tc := range testCases {
+		s := S{text: tc.text, docs: tc.docs}
+		testhelper.DiffString(t, tc.IDStr(), "text", s.TextString(), tc.expText)
+		testhelper.DiffString(t, tc.IDStr(), "docs", s.DocsString(), tc.expDocs)
+	}
+}
+
+func TestTextInline(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		text   []string
+		joiner string
+		escape func(string) string
+		expVal string
+	}{
+		{
+			ID:     testhelper.MkID("no text"),
+			joiner: "<br>",
+		},
+		{
+			ID:     testhelper.MkID("one line"),
+			text:   []string{"a < b"},
+			joiner: "<br>",
+			expVal: "a < b",
+		},
+		{
+			ID:     testhelper.MkID("many lines"),
+			text:   []string{"if a < b {", "}"},
+			joiner: `\n`,
+			expVal: `if a < b {\n}`,
+		},
+		{
+			ID:     testhelper.MkID("many lines, escaped"),
+			text:   []string{"if a < b {", "}"},
+			joiner: "<br>",
+			escape: html.EscapeString,
+			expVal: "if a &lt; b {<br>}",
+		},
+	}
+
+	for _, tc := range testCases {
+		s := S{text: tc.text}
+		testhelper.DiffString(t, tc.IDStr(), "inline text",
+			s.TextInlineEscaped(tc.joiner, tc.escape), tc.expVal)
+		if tc.escape == nil {
+			testhelper.DiffString(t, tc.IDStr(), "inline text (unescaped)",
+				s.TextInline(tc.joiner), tc.expVal)
+		}
+	}
+}
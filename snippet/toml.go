@@ -0,0 +1,109 @@
+package snippet
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var tomlBareKeyRE = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tomlKey returns the key as a bare key if that is allowed, otherwise as a
+// quoted key
+func tomlKey(k string) string {
+	if tomlBareKeyRE.MatchString(k) {
+		return k
+	}
+	return tomlString(k)
+}
+
+// tomlEscape returns the string with any characters which must be escaped
+// in a TOML basic string replaced with their escaped form.
+func tomlEscape(str string) string {
+	var b strings.Builder
+	for _, r := range str {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// tomlString returns the string as a TOML basic string
+func tomlString(str string) string {
+	return `"` + tomlEscape(str) + `"`
+}
+
+// tomlArray returns the strings as a TOML array of basic strings
+func tomlArray(strs []string) string {
+	quoted := make([]string, 0, len(strs))
+	for _, str := range strs {
+		quoted = append(quoted, tomlString(str))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// tomlMultiLine returns the lines as a TOML multi-line string. A literal
+// string is used if possible as it needs no escaping. If the lines contain
+// characters which cannot appear in a literal string then a basic string
+// is used with the text escaped, apart from the newlines.
+func tomlMultiLine(lines []string) string {
+	text := strings.Join(lines, "\n") + "\n"
+	if !strings.Contains(text, "'''") &&
+		strings.IndexFunc(text, func(r rune) bool {
+			return (r < 0x20 && r != '\t' && r != '\n') || r == 0x7f
+		}) == -1 {
+		return "'''\n" + text + "'''"
+	}
+
+	escLines := make([]string, 0, len(lines))
+	for _, l := range lines {
+		escLines = append(escLines, tomlEscape(l))
+	}
+	return `"""` + "\n" + strings.Join(escLines, "\n") + "\n" + `"""`
+}
+
+// TOML returns the snippet as a TOML document. The name, path and text are
+// given as strings, the text as a multi-line string. The notes, imports,
+// expects, follows and related snippets are given as arrays of strings and
+// the tags as a table of arrays of strings.
+func (s S) TOML() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s = %s\n", NamePart, tomlString(s.name))
+	fmt.Fprintf(&b, "%s = %s\n", PathPart, tomlString(s.path))
+	fmt.Fprintf(&b, "%s = %s\n", DocsPart, tomlArray(s.docs))
+	fmt.Fprintf(&b, "%s = %s\n", ImportPart, tomlArray(s.imports))
+	fmt.Fprintf(&b, "%s = %s\n", ExpectPart, tomlArray(s.expects))
+	fmt.Fprintf(&b, "%s = %s\n", FollowPart, tomlArray(s.follows))
+	fmt.Fprintf(&b, "%s = %s\n", SeeAlsoPart, tomlArray(s.seeAlso))
+	fmt.Fprintf(&b, "%s = %s\n", StatusPart, tomlString(s.status))
+	fmt.Fprintf(&b, "%s = %s\n", SincePart, tomlString(s.since))
+	fmt.Fprintf(&b, "%s = %s\n", TextPart, tomlMultiLine(s.text))
+
+	fmt.Fprintf(&b, "\n[%s]\n", TagPart)
+	for _, k := range getTagKeys(&s) {
+		fmt.Fprintf(&b, "%s = %s\n", tomlKey(k), tomlArray(s.tags[k]))
+	}
+
+	return b.String()
+}
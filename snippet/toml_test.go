@@ -0,0 +1,80 @@
+package snippet
+
+import (
+	"testing"
+
+	"github.com/nickwells/testhelper.mod/v2/testhelper"
+)
+
+func TestTOML(t *testing.T) {
+	testCases := []struct {
+		testhelper.ID
+		s      S
+		expVal string
+	}{
+		{
+			ID: testhelper.MkID("simple"),
+			s: S{
+				name:    "hw",
+				path:    "dir/hw",
+				text:    []string{`fmt.Println("Hello, World!")`},
+				docs:    []string{" says hello"},
+				imports: []string{"fmt"},
+				status:  StatusStable,
+				since:   "1.18",
+				tags: map[string][]string{
+					"Author": {"Nick Wells"},
+				},
+			},
+			expVal: `name = "hw"
+path = "dir/hw"
+note = [" says hello"]
+imports = ["fmt"]
+expects = []
+follows = []
+seealso = []
+status = "stable"
+since = "1.18"
+text = '''
+fmt.Println("Hello, World!")
+'''
+
+[tag]
+Author = ["Nick Wells"]
+`,
+		},
+		{
+			ID: testhelper.MkID("needs escaping"),
+			s: S{
+				name:    "a\\b",
+				path:    "a\\b",
+				text:    []string{"x := '''", "y := \"\\t\""},
+				expects: []string{"q\"q", "c\x01"},
+				tags: map[string][]string{
+					"a tag": {"v1", "v2"},
+				},
+			},
+			expVal: `name = "a\\b"
+path = "a\\b"
+note = []
+imports = []
+expects = ["q\"q", "c\u0001"]
+follows = []
+seealso = []
+status = ""
+since = ""
+text = """
+x := '''
+y := \"\\t\"
+"""
+
+[tag]
+"a tag" = ["v1", "v2"]
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		testhelper.DiffString(t, tc.IDStr(), "TOML", tc.s.TOML(), tc.expVal)
+	}
+}
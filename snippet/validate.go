@@ -0,0 +1,68 @@
+package snippet
+
+import (
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/nickwells/errutil.mod/errutil"
+)
+
+// ValidateAll lists the snippets in each group of snippet directories,
+// discarding the output, and returns the errors found for each group keyed
+// by the index of the group. The groups are validated concurrently using
+// as many workers as there are CPUs; see ValidateAllN. The options are
+// applied to the configuration for each group; they should not include
+// SetWarnings as the warnings map would then be shared between the groups.
+func ValidateAll(dirGroups [][]string, opts ...ListCfgOptFunc,
+) map[int]*errutil.ErrMap {
+	return ValidateAllN(runtime.NumCPU(), dirGroups, opts...)
+}
+
+// ValidateAllN behaves as ValidateAll but validates at most the given
+// number of groups at the same time. If the number of workers is less than
+// one then the groups are validated one at a time.
+func ValidateAllN(workers int, dirGroups [][]string, opts ...ListCfgOptFunc,
+) map[int]*errutil.ErrMap {
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make(map[int]*errutil.ErrMap, len(dirGroups))
+	for i := range dirGroups {
+		results[i] = errutil.NewErrMap()
+	}
+
+	idx := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				validateGroup(dirGroups[i], results[i], opts...)
+			}
+		}()
+	}
+
+	for i := range dirGroups {
+		idx <- i
+	}
+	close(idx)
+	wg.Wait()
+
+	return results
+}
+
+// validateGroup lists the snippets in the snippet directories, discarding
+// the output and recording any errors in the error map.
+func validateGroup(dirs []string, errs *errutil.ErrMap,
+	opts ...ListCfgOptFunc,
+) {
+	lc, err := NewListCfg(io.Discard, dirs, errs, opts...)
+	if err != nil {
+		errs.AddError("Bad listing configuration", err)
+		return
+	}
+	lc.List()
+}
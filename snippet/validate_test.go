@@ -0,0 +1,57 @@
+package snippet
+
+import (
+	"io"
+	"testing"
+
+	"github.com/nickwells/errutil.mod/errutil"
+)
+
+func TestValidateAll(t *testing.T) {
+	dirGroups := [][]string{
+		{GoodSnippets},
+		{BadSnippets},
+		{TestSnippets},
+	}
+
+	for _, workers := range []int{0, 1, 2, 8} {
+		results := ValidateAllN(workers, dirGroups)
+		if len(results) != len(dirGroups) {
+			t.Fatalf("workers: %d: expected %d results, got %d",
+				workers, len(dirGroups), len(results))
+		}
+
+		for i, dirs := range dirGroups {
+			expErrs := errutil.NewErrMap()
+			lc, err := NewListCfg(nil, dirs, expErrs)
+			if err != nil {
+				t.Fatalf("cannot make the ListCfg: %s", err)
+			}
+			lc.SetStdW(io.Discard)
+			lc.List()
+
+			if err := results[i].Matches(*expErrs); err != nil {
+				t.Logf("workers: %d, group: %d", workers, i)
+				t.Errorf("\t: unexpected errors: %s", err)
+			}
+		}
+	}
+}
+
+func TestValidateAllBadOption(t *testing.T) {
+	badOpt := SetSortBy("nonesuch")
+	_, optErr := NewListCfg(nil, nil, nil, badOpt)
+	if optErr == nil {
+		t.Fatal("the bad option should have been reported")
+	}
+
+	results := ValidateAll([][]string{{GoodSnippets}, {TestSnippets}}, badOpt)
+	for i, em := range results {
+		err := em.Matches(errutil.ErrMap{
+			"Bad listing configuration": []error{optErr},
+		})
+		if err != nil {
+			t.Errorf("group %d: unexpected errors: %s", i, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package snippet
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// DfltWatchInterval is the default time between the checks for changes to
+// the snippet files made by Watch
+const DfltWatchInterval = time.Second
+
+// fileState records the details of a file used to tell if it has changed
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// dirsState returns the state of every file found under the directories,
+// keyed by the pathname of the file. Any directory or file which cannot be
+// read is left out; a missing directory simply has no files.
+func dirsState(fsys fs.FS, dirs []string) map[string]fileState {
+	state := map[string]fileState{}
+
+	var addDir func(dir string)
+	addDir = func(dir string) {
+		dirEntries, err := readDir(fsys, dir)
+		if err != nil {
+			return
+		}
+		for _, de := range dirEntries {
+			name := filepath.Join(dir, de.Name())
+			if fsys != nil {
+				name = path.Join(dir, de.Name())
+			}
+			if de.IsDir() {
+				addDir(name)
+				continue
+			}
+			info, err := de.Info()
+			if err != nil {
+				continue
+			}
+			state[name] = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	for _, dir := range dirs {
+		addDir(dir)
+	}
+
+	return state
+}
+
+// sameState returns true if the two states record the same files with the
+// same details
+func sameState(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, fsA := range a {
+		fsB, ok := b[name]
+		if !ok || !fsA.modTime.Equal(fsB.modTime) || fsA.size != fsB.size {
+			return false
+		}
+	}
+	return true
+}
+
+// watch checks the files under the directories every interval and calls
+// onChange each time they differ from the previous check. If the interval
+// is not positive DfltWatchInterval is used. It returns the context's
+// error once the context is done.
+func watch(ctx context.Context, fsys fs.FS, dirs []string,
+	interval time.Duration, onChange func(),
+) error {
+	if interval <= 0 {
+		interval = DfltWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	prev := dirsState(fsys, dirs)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			cur := dirsState(fsys, dirs)
+			if !sameState(prev, cur) {
+				prev = cur
+				onChange()
+			}
+		}
+	}
+}
+
+// Watch checks the snippet directories for changes and calls onChange
+// whenever a file in them is created, modified or deleted. The files are
+// checked by comparing their modification times and sizes every watch
+// interval (see SetWatchInterval) and onChange is called at most once for
+// all the changes found by a check. It is called from the goroutine
+// running Watch and no further checks are made until it returns; it would
+// typically list the snippets again. Watch returns the context's error
+// once the context is cancelled and it is not otherwise stopped.
+func (lc *ListCfg) Watch(ctx context.Context, onChange func()) error {
+	return watch(ctx, lc.fsys, lc.dirs, lc.watchInterval, onChange)
+}
+
+// SetWatchInterval sets the time between the checks for changes made by
+// Watch. If the interval is not positive DfltWatchInterval is used.
+func (c *Cache) SetWatchInterval(interval time.Duration) {
+	c.watchInterval = interval
+}
+
+// Watch checks the snippet directories that the snippets in the cache
+// were searched for in and calls onChange whenever a file in them is
+// created, modified or deleted. The directories are those recorded when
+// Watch is called. The files are checked as for ListCfg.Watch and, as
+// there, Watch returns the context's error once the context is cancelled.
+// Note that the cache is not changed; onChange would typically load the
+// snippets into a new Cache.
+func (c *Cache) Watch(ctx context.Context, onChange func()) error {
+	return watch(ctx, c.fsys, copySlice(c.dirs), c.watchInterval, onChange)
+}
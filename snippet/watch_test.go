@@ -0,0 +1,89 @@
+package snippet
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/nickwells/errutil.mod/errutil"
+)
+
+func TestDirsState(t *testing.T) {
+	fsys := fstest.MapFS{
+		"snips/a":     {Data: []byte("a()\n")},
+		"snips/sub/b": {Data: []byte("b()\n")},
+	}
+	before := dirsState(fsys, []string{"snips", "nonesuch"})
+	if len(before) != 2 {
+		t.Errorf("expected 2 files, found %d: %v", len(before), before)
+	}
+	if _, ok := before["snips/sub/b"]; !ok {
+		t.Errorf("the file in the sub-directory was not found: %v", before)
+	}
+	if !sameState(before, dirsState(fsys, []string{"snips"})) {
+		t.Error("the state of unchanged files should be the same")
+	}
+
+	fsys["snips/a"] = &fstest.MapFile{Data: []byte("a(1)\n")}
+	if sameState(before, dirsState(fsys, []string{"snips"})) {
+		t.Error("a changed file should change the state")
+	}
+
+	delete(fsys, "snips/sub/b")
+	fsys["snips/a"] = &fstest.MapFile{Data: []byte("a()\n")}
+	if sameState(before, dirsState(fsys, []string{"snips"})) {
+		t.Error("a deleted file should change the state")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	lc, err := NewListCfg(os.Stdout, []string{dir}, errutil.NewErrMap(),
+		SetWatchInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal("cannot create the ListCfg: ", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changed := make(chan bool, 10)
+	done := make(chan error)
+	go func() {
+		done <- lc.Watch(ctx, func() { changed <- true })
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	err = os.WriteFile(filepath.Join(dir, "new"), []byte("x()\n"), 0o644)
+	if err != nil {
+		t.Fatal("cannot write the snippet file: ", err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Error("the creation of a snippet file was not reported")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Watch should return the context error, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Watch did not return when the context was cancelled")
+	}
+
+	lc, err = NewListCfg(os.Stdout, nil, errutil.NewErrMap(),
+		SetWatchInterval(0))
+	if err != nil {
+		t.Fatal("a zero watch interval should be allowed: ", err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := lc.Watch(ctx, func() {}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Watch should return the context error, got: %v", err)
+	}
+}